@@ -3,15 +3,55 @@ package main
 import (
 	"flag"
 	"github.com/kubespace/agent/pkg/config"
+	"github.com/kubespace/agent/pkg/container"
 	"github.com/kubespace/agent/pkg/core"
+	"github.com/kubespace/agent/pkg/websocket"
 	"k8s.io/klog"
 	"os"
+	"strconv"
+	"time"
 )
 
 var (
 	kubeConfigFile = flag.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information.")
 	agentToken     = flag.String("token", LookupEnvOrString("TOKEN", "local"), "Agent token to connect to server.")
-	serverUrl      = flag.String("server-url", LookupEnvOrString("SERVER_URL", "kubespace"), "Server url agent to connect.")
+	serverUrl      = flag.String("server-url", LookupEnvOrString("SERVER_URL", "kubespace"), "Server url agent to connect, an ordered comma separated list for failover, or srv://<name> to resolve by DNS SRV records.")
+
+	serverTLS          = flag.Bool("server-tls", LookupEnvOrBool("SERVER_TLS", false), "Use wss/https to connect server.")
+	serverCAFile       = flag.String("server-ca-file", LookupEnvOrString("SERVER_CA_FILE", ""), "CA bundle file to verify server certificate, system roots are used if empty.")
+	clientCertFile     = flag.String("client-cert-file", LookupEnvOrString("CLIENT_CERT_FILE", ""), "Client certificate file for mutual TLS authentication to server.")
+	clientKeyFile      = flag.String("client-key-file", LookupEnvOrString("CLIENT_KEY_FILE", ""), "Client key file for mutual TLS authentication to server.")
+	serverPinnedKeys   = flag.String("server-pinned-keys", LookupEnvOrString("SERVER_PINNED_KEYS", ""), "Comma separated sha256 pins (base64, e.g. sha256//xxx) of server certificate public keys.")
+	insecureSkipVerify = flag.Bool("insecure-skip-verify", LookupEnvOrBool("INSECURE_SKIP_VERIFY", false), "Skip server certificate verification, only for testing.")
+
+	pingInterval         = flag.Duration("ping-interval", LookupEnvOrDuration("PING_INTERVAL", websocket.DefaultPingInterval), "Interval of ping messages sent to server.")
+	pongTimeout          = flag.Duration("pong-timeout", LookupEnvOrDuration("PONG_TIMEOUT", websocket.DefaultPongTimeout), "Reconnect if nothing is received from server within this duration.")
+	reconnectMinBackoff  = flag.Duration("reconnect-min-backoff", LookupEnvOrDuration("RECONNECT_MIN_BACKOFF", websocket.DefaultReconnectMinBackoff), "Initial backoff between reconnect attempts.")
+	reconnectMaxBackoff  = flag.Duration("reconnect-max-backoff", LookupEnvOrDuration("RECONNECT_MAX_BACKOFF", websocket.DefaultReconnectMaxBackoff), "Maximum backoff between reconnect attempts.")
+	primaryProbeInterval = flag.Duration("primary-probe-interval", LookupEnvOrDuration("PRIMARY_PROBE_INTERVAL", websocket.DefaultPrimaryProbeInterval), "Interval to probe the primary server endpoint while connected to a fallback one.")
+
+	proxyUrl      = flag.String("proxy-url", LookupEnvOrString("PROXY_URL", ""), "Proxy (http or socks5) for outbound server connections, HTTPS_PROXY/HTTP_PROXY/NO_PROXY env are used if empty.")
+	noProxy       = flag.String("no-proxy", LookupEnvOrString("NO_PROXY", ""), "Comma separated hosts, domains or CIDRs connected without proxy.")
+	proxyUsername = flag.String("proxy-username", LookupEnvOrString("PROXY_USERNAME", ""), "Username for proxy authentication, the password is read from env PROXY_PASSWORD.")
+
+	compression     = flag.Bool("compression", LookupEnvOrBool("COMPRESSION", true), "Negotiate permessage-deflate compression with server.")
+	compactEncoding = flag.Bool("compact-encoding", LookupEnvOrBool("COMPACT_ENCODING", true), "Offer MessagePack encoding of responses to server.")
+	maxMessageSize  = flag.Int("max-message-size", LookupEnvOrInt("MAX_MESSAGE_SIZE", websocket.DefaultMaxMessageSize), "Responses larger than this size in bytes are split into chunks.")
+
+	outboxMaxMessages = flag.Int("outbox-max-messages", LookupEnvOrInt("OUTBOX_MAX_MESSAGES", websocket.DefaultOutboxMaxMessages), "Maximum number of messages spooled while disconnected from server.")
+	outboxMaxBytes    = flag.Int("outbox-max-bytes", LookupEnvOrInt("OUTBOX_MAX_BYTES", websocket.DefaultOutboxMaxBytes), "Maximum total size in bytes of messages spooled while disconnected from server.")
+	outboxMaxAge      = flag.Duration("outbox-max-age", LookupEnvOrDuration("OUTBOX_MAX_AGE", websocket.DefaultOutboxMaxAge), "Spooled messages older than this are dropped.")
+	outboxDir         = flag.String("outbox-dir", LookupEnvOrString("OUTBOX_DIR", ""), "Directory to persist spooled messages, kept in memory only if empty.")
+
+	workers                    = flag.Int("workers", LookupEnvOrInt("WORKERS", container.DefaultWorkers), "Number of workers handling requests.")
+	interactiveWorkers         = flag.Int("interactive-workers", LookupEnvOrInt("INTERACTIVE_WORKERS", container.DefaultInteractiveWorkers), "Number of extra workers reserved for interactive exec and log requests.")
+	resourceConcurrency        = flag.String("resource-concurrency", LookupEnvOrString("RESOURCE_CONCURRENCY", ""), "Comma separated per resource concurrency limits, e.g. helm=2,cluster=4.")
+	defaultResourceConcurrency = flag.Int("default-resource-concurrency", LookupEnvOrInt("DEFAULT_RESOURCE_CONCURRENCY", 0), "Concurrency limit of resources not set in --resource-concurrency, 0 means unlimited.")
+	statsInterval              = flag.Duration("stats-interval", LookupEnvOrDuration("STATS_INTERVAL", container.DefaultStatsInterval), "Interval of request queue stats sent to server, 0 to disable.")
+	maxQueuedRequests          = flag.Int("max-queued-requests", LookupEnvOrInt("MAX_QUEUED_REQUESTS", container.DefaultMaxQueued), "Max requests waiting in queue, new requests are rejected as busy when exceeded.")
+
+	policyFile = flag.String("policy-file", LookupEnvOrString("POLICY_FILE", ""), "Agent local policy file (yaml) restricting namespaces, resources and actions requested by server.")
+	readOnly   = flag.Bool("read-only", LookupEnvOrBool("READ_ONLY", false), "Only allow read actions, regardless of the policy file.")
 )
 
 func LookupEnvOrString(key string, defaultVal string) string {
@@ -21,11 +61,85 @@ func LookupEnvOrString(key string, defaultVal string) string {
 	return defaultVal
 }
 
+func LookupEnvOrBool(key string, defaultVal bool) bool {
+	if val, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			klog.Errorf("parse env %s=%s error: %v", key, val, err)
+			return defaultVal
+		}
+		return b
+	}
+	return defaultVal
+}
+
+func LookupEnvOrInt(key string, defaultVal int) int {
+	if val, ok := os.LookupEnv(key); ok {
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			klog.Errorf("parse env %s=%s error: %v", key, val, err)
+			return defaultVal
+		}
+		return i
+	}
+	return defaultVal
+}
+
+func LookupEnvOrDuration(key string, defaultVal time.Duration) time.Duration {
+	if val, ok := os.LookupEnv(key); ok {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			klog.Errorf("parse env %s=%s error: %v", key, val, err)
+			return defaultVal
+		}
+		return d
+	}
+	return defaultVal
+}
+
 func createAgentOptions() *config.AgentOptions {
 	return &config.AgentOptions{
 		KubeConfigFile: *kubeConfigFile,
 		AgentToken:     *agentToken,
 		ServerUrl:      *serverUrl,
+
+		ServerTLS:          *serverTLS,
+		ServerCAFile:       *serverCAFile,
+		ClientCertFile:     *clientCertFile,
+		ClientKeyFile:      *clientKeyFile,
+		ServerPinnedKeys:   *serverPinnedKeys,
+		InsecureSkipVerify: *insecureSkipVerify,
+
+		PingInterval:        *pingInterval,
+		PongTimeout:         *pongTimeout,
+		ReconnectMinBackoff: *reconnectMinBackoff,
+		ReconnectMaxBackoff: *reconnectMaxBackoff,
+
+		PrimaryProbeInterval: *primaryProbeInterval,
+
+		ProxyUrl:      *proxyUrl,
+		NoProxy:       *noProxy,
+		ProxyUsername: *proxyUsername,
+		ProxyPassword: os.Getenv("PROXY_PASSWORD"),
+
+		Compression:     *compression,
+		CompactEncoding: *compactEncoding,
+		MaxMessageSize:  *maxMessageSize,
+
+		OutboxMaxMessages: *outboxMaxMessages,
+		OutboxMaxBytes:    int64(*outboxMaxBytes),
+		OutboxMaxAge:      *outboxMaxAge,
+		OutboxDir:         *outboxDir,
+
+		Workers:                    *workers,
+		InteractiveWorkers:         *interactiveWorkers,
+		ResourceConcurrency:        *resourceConcurrency,
+		DefaultResourceConcurrency: *defaultResourceConcurrency,
+		StatsInterval:              *statsInterval,
+		MaxQueuedRequests:          *maxQueuedRequests,
+
+		PolicyFile: *policyFile,
+		ReadOnly:   *readOnly,
 	}
 }
 
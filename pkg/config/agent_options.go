@@ -1,7 +1,71 @@
 package config
 
+import "time"
+
 type AgentOptions struct {
 	KubeConfigFile string
 	AgentToken     string
 	ServerUrl      string
+
+	// ServerTLS 使用wss/https连接server
+	ServerTLS bool
+	// ServerCAFile 校验server证书的CA文件
+	ServerCAFile string
+	// ClientCertFile、ClientKeyFile mTLS客户端证书
+	ClientCertFile string
+	ClientKeyFile  string
+	// ServerPinnedKeys server证书公钥sha256摘要，多个以逗号分隔
+	ServerPinnedKeys string
+	// InsecureSkipVerify 不校验server证书
+	InsecureSkipVerify bool
+
+	// PingInterval 向server发送心跳的间隔
+	PingInterval time.Duration
+	// PongTimeout 超过该时间未收到server任何消息则断开重连
+	PongTimeout time.Duration
+	// ReconnectMinBackoff、ReconnectMaxBackoff 重连退避的最小、最大间隔
+	ReconnectMinBackoff time.Duration
+	ReconnectMaxBackoff time.Duration
+
+	// PrimaryProbeInterval 连接到备用server时探测主server是否恢复的间隔
+	PrimaryProbeInterval time.Duration
+
+	// ProxyUrl 访问server使用的代理，支持http、socks5，为空时读取HTTPS_PROXY等环境变量
+	ProxyUrl string
+	// NoProxy 不走代理的地址，逗号分隔
+	NoProxy string
+	// ProxyUsername、ProxyPassword 代理认证信息
+	ProxyUsername string
+	ProxyPassword string
+
+	// Compression 与server协商permessage-deflate压缩
+	Compression bool
+	// CompactEncoding 与server协商使用MessagePack编码响应
+	CompactEncoding bool
+	// MaxMessageSize 单条消息的最大字节数，超过后拆分发送
+	MaxMessageSize int
+
+	// OutboxMaxMessages、OutboxMaxBytes、OutboxMaxAge 断开期间缓存消息的数量、大小及时间限制
+	OutboxMaxMessages int
+	OutboxMaxBytes    int64
+	OutboxMaxAge      time.Duration
+	// OutboxDir 缓存消息持久化目录，为空时只保存在内存
+	OutboxDir string
+
+	// Workers、InteractiveWorkers 处理请求的协程数及只处理交互式请求的协程数
+	Workers            int
+	InteractiveWorkers int
+	// ResourceConcurrency 每个resource同时执行的最大请求数，格式为 helm=2,cluster=4
+	ResourceConcurrency string
+	// DefaultResourceConcurrency 未单独配置的resource同时执行的最大请求数，0为不限制
+	DefaultResourceConcurrency int
+	// StatsInterval 向server发送请求队列统计信息的间隔，0为不发送
+	StatsInterval time.Duration
+	// MaxQueuedRequests 队列中等待执行的最大请求数，超过后拒绝新的请求
+	MaxQueuedRequests int
+
+	// PolicyFile agent本地访问策略文件
+	PolicyFile string
+	// ReadOnly 只允许查询操作，优先于策略文件中的配置
+	ReadOnly bool
 }
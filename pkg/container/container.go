@@ -1,22 +1,40 @@
 package container
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/kubespace/agent/pkg/container/resource"
 	"github.com/kubespace/agent/pkg/kubernetes"
 	"github.com/kubespace/agent/pkg/ospserver"
+	"github.com/kubespace/agent/pkg/policy"
 	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
 	"github.com/kubespace/agent/pkg/websocket"
 	"k8s.io/klog"
-	"runtime"
+	"sync"
+	"time"
 )
 
+const (
+	// CancelAction 取消正在执行的请求，params中request_id为要取消的请求
+	CancelAction = "cancel"
+)
+
+type CancelParams struct {
+	RequestId string `json:"request_id"`
+}
+
 type Container struct {
 	KubeClient   *kubernetes.KubeClient
 	RequestChan  chan *utils.Request
 	ResponseChan chan *utils.TResponse
 	*ResourceActions
 	websocket.SendResponse
+	// running 正在执行的请求，用于取消请求
+	running      map[string]context.CancelFunc
+	runningMutex sync.Mutex
+	pool         *WorkerPool
 }
 
 func NewContainer(
@@ -24,24 +42,42 @@ func NewContainer(
 	requestChan chan *utils.Request,
 	responseChan chan *utils.TResponse,
 	sendResponse websocket.SendResponse,
-	ospServer *ospserver.OspServer) *Container {
+	ospServer *ospserver.OspServer,
+	poolOpts *PoolOptions,
+	agentPolicy *policy.Policy) *Container {
 
-	resourceActions := NewResourceActions(kubeClient, sendResponse, ospServer)
-	return &Container{
+	if agentPolicy == nil {
+		agentPolicy = &policy.Policy{}
+	}
+	resourceActions := NewResourceActions(kubeClient, sendResponse, ospServer, agentPolicy)
+	c := &Container{
 		KubeClient:      kubeClient,
 		RequestChan:     requestChan,
 		ResponseChan:    responseChan,
 		ResourceActions: resourceActions,
 		SendResponse:    sendResponse,
+		running:         make(map[string]context.CancelFunc),
 	}
+	c.Use(Logging, Recovery, Policy(agentPolicy, resource.NewScopeFunc(kubeClient)), Impersonation(kubeClient))
+	c.pool = NewWorkerPool(poolOpts, c.handleRequest)
+	return c
 }
 
 func (c *Container) Run() {
+	c.pool.Start()
+	if c.pool.opts.StatsInterval > 0 {
+		go c.publishStats(c.pool.opts.StatsInterval)
+	}
 	for {
 		select {
 		case req, ok := <-c.RequestChan:
 			if ok {
-				go c.handleRequest(req)
+				if req.Action == CancelAction {
+					go c.handleCancel(req)
+				} else if err := c.pool.Submit(req); err != nil {
+					klog.Warningf("reject request %s resource %s action %s: %v", req.RequestId, req.Resource, req.Action, err)
+					go c.SendResponse(utils.NewBusyResponse(err.Error()), req.RequestId, utils.RequestType)
+				}
 			}
 		}
 	}
@@ -54,29 +90,97 @@ func (c *Container) handleRequest(request *utils.Request) {
 	c.SendResponse(resp, request.RequestId, utils.RequestType)
 }
 
-func (c *Container) doRequest(request *utils.Request) (resp *utils.Response) {
-	defer func() {
-		if err := recover(); err != nil {
-			klog.Error("do request error: ", err)
-			var buf [4096]byte
-			n := runtime.Stack(buf[:], false)
-			klog.Errorf("==> %s\n", string(buf[:n]))
-			msg := fmt.Sprintf("%s", err)
-			resp = &utils.Response{Code: "UnknownError", Msg: msg}
+func (c *Container) handleCancel(request *utils.Request) {
+	params := &CancelParams{}
+	jsonParams, _ := json.Marshal(request.Params)
+	json.Unmarshal(jsonParams, params)
+	resp := &utils.Response{Code: code.Success}
+	if params.RequestId == "" {
+		resp = utils.NewParamsErrorResponse("params not found request id")
+	} else if c.pool.Remove(params.RequestId) {
+		// 请求还在队列中未执行，直接从队列移除并响应
+		klog.Infof("cancel queued request %s", params.RequestId)
+		cancelledResp := &utils.Response{Code: code.Cancelled, Msg: "request cancelled", Error: utils.NewError(context.Canceled)}
+		c.SendResponse(cancelledResp, params.RequestId, utils.RequestType)
+	} else {
+		c.runningMutex.Lock()
+		cancel, ok := c.running[params.RequestId]
+		c.runningMutex.Unlock()
+		if ok {
+			// 请求正在执行，handler感知到ctx取消后才会返回，最终结果通过原请求的响应返回
+			klog.Infof("cancel request %s", params.RequestId)
+			cancel()
+			resp.Msg = "cancel requested"
+		} else {
+			resp = utils.NewParamsErrorResponse(fmt.Sprintf("request %s not found or finished", params.RequestId))
 		}
-	}()
+	}
+	c.SendResponse(resp, request.RequestId, utils.RequestType)
+}
+
+// publishStats 定期向server发送请求队列统计信息，server据此判断agent是否过载
+func (c *Container) publishStats(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.SendResponse(c.pool.Stats(), "", utils.StatsType)
+	}
+}
+
+// requestContext 为请求创建可取消的context，请求带有timeout时设置超时时间
+func (c *Container) requestContext(request *utils.Request) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if request.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(request.Timeout)*time.Second)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	if request.RequestId == "" {
+		return ctx, cancel
+	}
+	c.runningMutex.Lock()
+	c.running[request.RequestId] = cancel
+	c.runningMutex.Unlock()
+	return ctx, func() {
+		c.runningMutex.Lock()
+		delete(c.running, request.RequestId)
+		c.runningMutex.Unlock()
+		cancel()
+	}
+}
+
+// doRequest 请求被取消或超时后，handler通过ctx感知并中止对apiserver的调用，
+// 等待handler返回后才释放worker，响应反映请求的实际结果：handler已经执行成功时仍返回成功
+func (c *Container) doRequest(request *utils.Request) *utils.Response {
+	ctx, cancel := c.requestContext(request)
+	defer cancel()
+
+	resp := c.callHandler(ctx, request)
+	if err := ctx.Err(); err != nil && (resp == nil || !resp.IsSuccess()) {
+		msg := ""
+		if resp != nil && resp.Msg != "" {
+			msg = ": " + resp.Msg
+		}
+		if err == context.DeadlineExceeded {
+			resp = &utils.Response{Code: code.Timeout, Msg: fmt.Sprintf("request timeout after %d seconds%s", request.Timeout, msg), Error: utils.NewError(err)}
+		} else {
+			resp = &utils.Response{Code: code.Cancelled, Msg: "request cancelled" + msg, Error: utils.NewError(err)}
+		}
+		klog.Infof("request %s resource %s action %s: %s", request.RequestId, request.Resource, request.Action, resp.Msg)
+	}
+	return resp
+}
+
+func (c *Container) callHandler(ctx context.Context, request *utils.Request) *utils.Response {
 	resource := request.Resource
 	action := request.Action
-	params := request.Params
 	handler := c.GetRequestHandler(resource, action)
-	resp = &utils.Response{}
 	if handler == nil {
 		msg := fmt.Sprintf("resource %s action %s not found", resource, action)
 		klog.Error(msg)
-		resp = &utils.Response{Code: "ActionError", Msg: msg}
-	} else {
-		jsonParams, _ := json.Marshal(params)
-		resp = handler(jsonParams)
+		return &utils.Response{Code: "ActionError", Msg: msg}
 	}
-	return
+	jsonParams, _ := json.Marshal(request.Params)
+	return handler(utils.WithRequest(ctx, request), jsonParams)
 }
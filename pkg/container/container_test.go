@@ -0,0 +1,97 @@
+package container
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+)
+
+func TestDoRequestCancel(t *testing.T) {
+	cases := []struct {
+		name    string
+		timeout int
+		cancel  bool
+		// handler 收到ctx取消后的返回
+		handler  Handler
+		wantCode string
+	}{
+		{
+			name:   "handler honours ctx",
+			cancel: true,
+			handler: func(ctx context.Context, _ interface{}) *utils.Response {
+				<-ctx.Done()
+				return utils.NewErrorResponse(code.GetError, ctx.Err())
+			},
+			wantCode: code.Cancelled,
+		},
+		{
+			name:    "timeout",
+			timeout: 1,
+			handler: func(ctx context.Context, _ interface{}) *utils.Response {
+				<-ctx.Done()
+				return utils.NewErrorResponse(code.GetError, ctx.Err())
+			},
+			wantCode: code.Timeout,
+		},
+		{
+			name:   "handler finished after cancel",
+			cancel: true,
+			handler: func(ctx context.Context, _ interface{}) *utils.Response {
+				<-ctx.Done()
+				return &utils.Response{Code: code.Success}
+			},
+			wantCode: code.Success,
+		},
+		{
+			name: "not cancelled",
+			handler: func(ctx context.Context, _ interface{}) *utils.Response {
+				return &utils.Response{Code: code.Success}
+			},
+			wantCode: code.Success,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c0 := &Container{
+				ResourceActions: &ResourceActions{ResourceActionHandler: map[string]ActionHandler{
+					"test": {"run": c.handler},
+				}},
+				running: make(map[string]context.CancelFunc),
+			}
+			request := &utils.Request{RequestId: "1", Resource: "test", Action: "run", Timeout: c.timeout}
+			done := make(chan *utils.Response, 1)
+			go func() {
+				done <- c0.doRequest(request)
+			}()
+			if c.cancel {
+				// 等待请求开始执行后取消
+				for {
+					c0.runningMutex.Lock()
+					cancel, ok := c0.running[request.RequestId]
+					c0.runningMutex.Unlock()
+					if ok {
+						cancel()
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			select {
+			case resp := <-done:
+				if resp.Code != c.wantCode {
+					t.Errorf("doRequest() code = %s, want %s, msg %s", resp.Code, c.wantCode, resp.Msg)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("doRequest() not returned")
+			}
+			c0.runningMutex.Lock()
+			defer c0.runningMutex.Unlock()
+			if len(c0.running) != 0 {
+				t.Errorf("running requests not cleaned: %v", c0.running)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kubespace/agent/pkg/kubernetes"
+	"github.com/kubespace/agent/pkg/policy"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Middleware 包装Handler，用于日志、耗时、panic恢复、鉴权、限流、审计等所有resource通用的处理
+type Middleware func(Handler) Handler
+
+// Chain 按顺序组合中间件，第一个中间件在最外层
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Recovery 捕获handler中的panic并返回UnknownError
+func Recovery(next Handler) Handler {
+	return func(ctx context.Context, params interface{}) (resp *utils.Response) {
+		defer func() {
+			if err := recover(); err != nil {
+				klog.Error("do request error: ", err)
+				var buf [4096]byte
+				n := runtime.Stack(buf[:], false)
+				klog.Errorf("==> %s\n", string(buf[:n]))
+				msg := fmt.Sprintf("%s", err)
+				resp = &utils.Response{Code: "UnknownError", Msg: msg}
+			}
+		}()
+		return next(ctx, params)
+	}
+}
+
+// Logging 记录请求的处理结果及耗时
+func Logging(next Handler) Handler {
+	return func(ctx context.Context, params interface{}) *utils.Response {
+		start := time.Now()
+		resp := next(ctx, params)
+		request := utils.RequestFrom(ctx)
+		if request == nil {
+			return resp
+		}
+		elapsed := time.Since(start)
+		if resp != nil && !resp.IsSuccess() {
+			klog.Infof("request %s resource %s action %s failed in %v: %s %s",
+				request.RequestId, request.Resource, request.Action, elapsed, resp.Code, resp.Msg)
+		} else {
+			klog.V(2).Infof("request %s resource %s action %s finished in %v",
+				request.RequestId, request.Resource, request.Action, elapsed)
+		}
+		return resp
+	}
+}
+
+// Policy 按agent本地策略检查请求，不允许时返回Forbidden
+func Policy(agentPolicy *policy.Policy, namespaced policy.ScopeFunc) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, params interface{}) *utils.Response {
+			request := utils.RequestFrom(ctx)
+			if request == nil {
+				return next(ctx, params)
+			}
+			data, _ := params.([]byte)
+			namespaces, err := policy.RequestNamespaces(data, namespaced)
+			if err != nil {
+				return utils.NewParamsErrorResponse("read yaml error: " + err.Error())
+			}
+			if err = agentPolicy.Check(request.Resource, request.Action, policy.IsDryRun(data), namespaces); err != nil {
+				klog.Warningf("request %s resource %s action %s user %s forbidden: %v",
+					request.RequestId, request.Resource, request.Action, request.User, err)
+				return utils.NewForbiddenResponse(err.Error())
+			}
+			return next(ctx, params)
+		}
+	}
+}
+
+// informerResources 从informer缓存读取的resource，模拟用户读取时需先通过SubjectAccessReview鉴权
+var informerResources = map[string]schema.GroupResource{
+	"pod":                     {Resource: "pods"},
+	"namespace":               {Resource: "namespaces"},
+	"node":                    {Resource: "nodes"},
+	"event":                   {Resource: "events"},
+	"configMap":               {Resource: "configmaps"},
+	"persistentVolume":        {Resource: "persistentvolumes"},
+	"persistentVolumeClaim":   {Resource: "persistentvolumeclaims"},
+	"service":                 {Resource: "services"},
+	"endpoints":               {Resource: "endpoints"},
+	"serviceaccount":          {Resource: "serviceaccounts"},
+	"secret":                  {Resource: "secrets"},
+	"deployment":              {Group: "apps", Resource: "deployments"},
+	"statefulset":             {Group: "apps", Resource: "statefulsets"},
+	"daemonset":               {Group: "apps", Resource: "daemonsets"},
+	"job":                     {Group: "batch", Resource: "jobs"},
+	"cronjob":                 {Group: "batch", Resource: "cronjobs"},
+	"horizontalPodAutoscaler": {Group: "autoscaling", Resource: "horizontalpodautoscalers"},
+	"ingress":                 {Group: "networking.k8s.io", Resource: "ingresses"},
+	"networkpolicy":           {Group: "networking.k8s.io", Resource: "networkpolicies"},
+	"storageClass":            {Group: "storage.k8s.io", Resource: "storageclasses"},
+	"rolebinding":             {Group: "rbac.authorization.k8s.io", Resource: "rolebindings"},
+	"role":                    {Group: "rbac.authorization.k8s.io", Resource: "roles"},
+}
+
+// clusterResources 集群概览读取的informer缓存
+var clusterResources = []schema.GroupResource{
+	{Resource: "nodes"},
+	{Resource: "namespaces"},
+	{Resource: "pods"},
+	{Group: "apps", Resource: "deployments"},
+	{Group: "apps", Resource: "statefulsets"},
+	{Group: "apps", Resource: "daemonsets"},
+	{Resource: "services"},
+	{Group: "networking.k8s.io", Resource: "ingresses"},
+	{Group: "storage.k8s.io", Resource: "storageclasses"},
+	{Resource: "persistentvolumes"},
+	{Resource: "persistentvolumeclaims"},
+}
+
+// workspaceResources 工作空间概览在namespace内读取的informer缓存
+var workspaceResources = []schema.GroupResource{
+	{Resource: "services"},
+	{Group: "networking.k8s.io", Resource: "ingresses"},
+	{Resource: "persistentvolumeclaims"},
+	{Resource: "configmaps"},
+	{Resource: "secrets"},
+}
+
+type objectParams struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Workspace uint   `json:"workspace"`
+}
+
+// accessAttributes 返回以agent身份读取informer缓存的请求需要用户具有的权限
+func accessAttributes(request *utils.Request, params interface{}) []*authorizationv1.ResourceAttributes {
+	object := &objectParams{}
+	if data, ok := params.([]byte); ok {
+		json.Unmarshal(data, object)
+	}
+	var attrs []*authorizationv1.ResourceAttributes
+	switch {
+	case request.Resource == "watch":
+		// watch广播所有informer的事件，需有全部资源在集群范围的watch权限
+		for _, gr := range informerResources {
+			attrs = append(attrs, &authorizationv1.ResourceAttributes{Verb: "watch", Group: gr.Group, Resource: gr.Resource})
+		}
+		sort.Slice(attrs, func(i, j int) bool {
+			return attrs[i].Group+"/"+attrs[i].Resource < attrs[j].Group+"/"+attrs[j].Resource
+		})
+	case request.Resource == "cluster" && request.Action == GET:
+		resources, namespace := clusterResources, ""
+		if object.Workspace != 0 {
+			resources, namespace = workspaceResources, object.Namespace
+		}
+		for _, gr := range resources {
+			attrs = append(attrs, &authorizationv1.ResourceAttributes{Verb: "list", Group: gr.Group, Resource: gr.Resource, Namespace: namespace})
+		}
+	case request.Action == LIST || request.Action == GET:
+		gr, ok := informerResources[request.Resource]
+		if !ok {
+			return nil
+		}
+		verb := "list"
+		if request.Action == GET && object.Name != "" {
+			verb = "get"
+		}
+		attrs = append(attrs, &authorizationv1.ResourceAttributes{
+			Verb:      verb,
+			Group:     gr.Group,
+			Resource:  gr.Resource,
+			Namespace: object.Namespace,
+			Name:      object.Name,
+		})
+	}
+	return attrs
+}
+
+// Impersonation 请求带有用户时，handler通过ctx获取以该用户身份访问apiserver的客户端，
+// 以agent身份读取informer缓存的请求先通过SubjectAccessReview鉴权
+func Impersonation(kubeClient *kubernetes.KubeClient) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, params interface{}) *utils.Response {
+			request := utils.RequestFrom(ctx)
+			if request == nil || request.User == "" {
+				return next(ctx, params)
+			}
+			client, err := kubeClient.ForUser(request.User, request.Groups)
+			if err != nil {
+				return utils.NewErrorResponse(code.ImpersonateError, err)
+			}
+			for _, attrs := range accessAttributes(request, params) {
+				if err := client.Authorize(ctx, attrs); err != nil {
+					if _, ok := err.(*kubernetes.ForbiddenError); ok {
+						return utils.NewForbiddenResponse(err.Error())
+					}
+					return utils.NewErrorResponse(code.ImpersonateError, err)
+				}
+			}
+			return next(kubernetes.WithClient(ctx, client), params)
+		}
+	}
+}
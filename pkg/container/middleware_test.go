@@ -0,0 +1,200 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+)
+
+// recordMiddleware 记录中间件执行的顺序
+func recordMiddleware(name string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, params interface{}) *utils.Response {
+			*calls = append(*calls, name+" before")
+			resp := next(ctx, params)
+			*calls = append(*calls, name+" after")
+			return resp
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	cases := []struct {
+		name        string
+		middlewares []string
+		want        []string
+	}{
+		{name: "no middleware", want: []string{"handler"}},
+		{name: "single", middlewares: []string{"a"}, want: []string{"a before", "handler", "a after"}},
+		{
+			name:        "first is outermost",
+			middlewares: []string{"a", "b", "c"},
+			want:        []string{"a before", "b before", "c before", "handler", "c after", "b after", "a after"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls []string
+			var middlewares []Middleware
+			for _, name := range c.middlewares {
+				middlewares = append(middlewares, recordMiddleware(name, &calls))
+			}
+			handler := func(ctx context.Context, params interface{}) *utils.Response {
+				calls = append(calls, "handler")
+				return &utils.Response{Code: code.Success}
+			}
+			resp := Chain(handler, middlewares...)(context.Background(), nil)
+			if !resp.IsSuccess() {
+				t.Errorf("response code = %s, want %s", resp.Code, code.Success)
+			}
+			if fmt.Sprint(calls) != fmt.Sprint(c.want) {
+				t.Errorf("calls = %v, want %v", calls, c.want)
+			}
+		})
+	}
+}
+
+func TestRecoveryAndLogging(t *testing.T) {
+	cases := []struct {
+		name     string
+		request  *utils.Request
+		handler  Handler
+		wantCode string
+		wantMsg  string
+	}{
+		{
+			name:    "success",
+			request: &utils.Request{RequestId: "1", Resource: "pod", Action: LIST},
+			handler: func(ctx context.Context, params interface{}) *utils.Response {
+				return &utils.Response{Code: code.Success}
+			},
+			wantCode: code.Success,
+		},
+		{
+			name:    "error response passes through",
+			request: &utils.Request{RequestId: "2", Resource: "pod", Action: GET},
+			handler: func(ctx context.Context, params interface{}) *utils.Response {
+				return &utils.Response{Code: code.GetError, Msg: "not found"}
+			},
+			wantCode: code.GetError,
+			wantMsg:  "not found",
+		},
+		{
+			name:    "panic recovered",
+			request: &utils.Request{RequestId: "3", Resource: "pod", Action: GET},
+			handler: func(ctx context.Context, params interface{}) *utils.Response {
+				panic("boom")
+			},
+			wantCode: "UnknownError",
+			wantMsg:  "boom",
+		},
+		{
+			name: "panic without request",
+			handler: func(ctx context.Context, params interface{}) *utils.Response {
+				var m map[string]string
+				m["a"] = "b"
+				return nil
+			},
+			wantCode: "UnknownError",
+			wantMsg:  "assignment to entry in nil map",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.request != nil {
+				ctx = utils.WithRequest(ctx, c.request)
+			}
+			resp := Chain(c.handler, Logging, Recovery)(ctx, nil)
+			if resp.Code != c.wantCode || resp.Msg != c.wantMsg {
+				t.Errorf("response = %s %q, want %s %q", resp.Code, resp.Msg, c.wantCode, c.wantMsg)
+			}
+		})
+	}
+}
+
+func TestGetRequestHandlerMiddlewares(t *testing.T) {
+	var calls []string
+	r := &ResourceActions{ResourceActionHandler: map[string]ActionHandler{
+		"pod": {LIST: func(ctx context.Context, params interface{}) *utils.Response {
+			calls = append(calls, "handler")
+			return &utils.Response{Code: code.Success}
+		}},
+	}}
+	r.Use(recordMiddleware("a", &calls))
+	r.Use(recordMiddleware("b", &calls))
+
+	if handler := r.GetRequestHandler("pod", "unknown"); handler != nil {
+		t.Error("GetRequestHandler() for unknown action is not nil")
+	}
+	if handler := r.GetRequestHandler("unknown", LIST); handler != nil {
+		t.Error("GetRequestHandler() for unknown resource is not nil")
+	}
+	r.GetRequestHandler("pod", LIST)(context.Background(), nil)
+	want := []string{"a before", "b before", "handler", "b after", "a after"}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestAccessAttributes(t *testing.T) {
+	cases := []struct {
+		name    string
+		request *utils.Request
+		params  map[string]interface{}
+		// want 期望的权限，格式为verb group/resource namespace/name
+		want []string
+	}{
+		{name: "list informer resource", request: &utils.Request{Resource: "pod", Action: LIST}, params: map[string]interface{}{"namespace": "dev"}, want: []string{"list /pods dev/"}},
+		{name: "get by name", request: &utils.Request{Resource: "deployment", Action: GET}, params: map[string]interface{}{"namespace": "dev", "name": "web"}, want: []string{"get apps/deployments dev/web"}},
+		{name: "not informer resource", request: &utils.Request{Resource: "crd", Action: LIST}},
+		{name: "write action", request: &utils.Request{Resource: "pod", Action: DELETE}},
+		{
+			name:    "cluster overview",
+			request: &utils.Request{Resource: "cluster", Action: GET},
+			want: []string{
+				"list /nodes /", "list /namespaces /", "list /pods /", "list apps/deployments /", "list apps/statefulsets /",
+				"list apps/daemonsets /", "list /services /", "list networking.k8s.io/ingresses /",
+				"list storage.k8s.io/storageclasses /", "list /persistentvolumes /", "list /persistentvolumeclaims /",
+			},
+		},
+		{
+			name:    "workspace overview",
+			request: &utils.Request{Resource: "cluster", Action: GET},
+			params:  map[string]interface{}{"workspace": 1, "namespace": "dev"},
+			want: []string{
+				"list /services dev/", "list networking.k8s.io/ingresses dev/", "list /persistentvolumeclaims dev/",
+				"list /configmaps dev/", "list /secrets dev/",
+			},
+		},
+		{name: "cluster apply", request: &utils.Request{Resource: "cluster", Action: APPLY}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			params, _ := json.Marshal(c.params)
+			var got []string
+			for _, a := range accessAttributes(c.request, params) {
+				got = append(got, fmt.Sprintf("%s %s/%s %s/%s", a.Verb, a.Group, a.Resource, a.Namespace, a.Name))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("accessAttributes() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAccessAttributesWatch(t *testing.T) {
+	attrs := accessAttributes(&utils.Request{Resource: "watch", Action: GET}, []byte(`{"action":"open"}`))
+	if len(attrs) != len(informerResources) {
+		t.Fatalf("watch attributes = %d, want %d", len(attrs), len(informerResources))
+	}
+	for _, a := range attrs {
+		if a.Verb != "watch" || a.Namespace != "" {
+			t.Errorf("watch attribute = %+v, want cluster wide watch", a)
+		}
+	}
+}
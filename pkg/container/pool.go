@@ -0,0 +1,270 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kubespace/agent/pkg/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Priority int
+
+// 优先级从高到低，队列中高优先级的请求总是先执行
+const (
+	// PriorityInteractive exec输入、日志等交互式流控制
+	PriorityInteractive Priority = iota
+	PriorityRead
+	PriorityWrite
+	// PriorityBulk helm安装升级、多文档yaml apply等耗时较长的批量操作
+	PriorityBulk
+	priorityNum
+)
+
+var priorityNames = [priorityNum]string{"interactive", "read", "write", "bulk"}
+
+func (p Priority) String() string {
+	return priorityNames[p]
+}
+
+var actionPriority = map[string]Priority{
+	EXEC:     PriorityInteractive,
+	STDIN:    PriorityInteractive,
+	OPENLOG:  PriorityInteractive,
+	CLOSELOG: PriorityInteractive,
+	WATCH:    PriorityInteractive,
+	LIST:     PriorityRead,
+	GET:      PriorityRead,
+	STATUS:   PriorityRead,
+	LISTOBJS: PriorityRead,
+}
+
+var bulkResources = map[string]bool{
+	"helm":    true,
+	"cluster": true,
+}
+
+// RequestPriority 根据resource及action确定请求的优先级
+func RequestPriority(request *utils.Request) Priority {
+	if p, ok := actionPriority[request.Action]; ok {
+		return p
+	}
+	if bulkResources[request.Resource] {
+		return PriorityBulk
+	}
+	return PriorityWrite
+}
+
+const (
+	DefaultWorkers            = 64
+	DefaultInteractiveWorkers = 4
+	DefaultStatsInterval      = 30 * time.Second
+	DefaultMaxQueued          = 1000
+)
+
+type PoolOptions struct {
+	// Workers 处理请求的协程数
+	Workers int
+	// InteractiveWorkers 只处理交互式请求的协程数，保证其他请求占满时交互式请求仍能及时处理
+	InteractiveWorkers int
+	// ResourceLimits 每个resource同时执行的最大请求数，0为不限制
+	ResourceLimits map[string]int
+	// DefaultResourceLimit 未单独配置的resource同时执行的最大请求数，0为不限制
+	DefaultResourceLimit int
+	// StatsInterval 向server发送队列统计信息的间隔，0为不发送
+	StatsInterval time.Duration
+	// MaxQueued 队列中等待执行的最大请求数，超过后拒绝新的请求
+	MaxQueued int
+}
+
+// ParseResourceLimits 解析resource并发限制，格式为 helm=2,cluster=4
+func ParseResourceLimits(limits string) (map[string]int, error) {
+	res := make(map[string]int)
+	for _, item := range strings.Split(limits, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid resource concurrency %q", item)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid resource concurrency %q", item)
+		}
+		res[strings.TrimSpace(kv[0])] = limit
+	}
+	return res, nil
+}
+
+// ErrQueueFull 队列中等待的请求数达到MaxQueued
+var ErrQueueFull = errors.New("request queue is full")
+
+type workItem struct {
+	request  *utils.Request
+	priority Priority
+	enqueued time.Time
+}
+
+type PoolStats struct {
+	Workers          int            `json:"workers"`
+	Running          int            `json:"running"`
+	Queued           int            `json:"queued"`
+	QueuedByPriority map[string]int `json:"queued_by_priority"`
+	RunningResources map[string]int `json:"running_resources"`
+	// OldestQueued 队列中等待最久的请求已经等待的时间，单位毫秒
+	OldestQueued int64 `json:"oldest_queued_ms"`
+}
+
+// WorkerPool 固定数量协程按优先级处理请求，并限制每个resource的并发数
+type WorkerPool struct {
+	opts    PoolOptions
+	handle  func(*utils.Request)
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queues  [priorityNum][]*workItem
+	running map[string]int
+	active  int
+}
+
+func NewWorkerPool(opts *PoolOptions, handle func(*utils.Request)) *WorkerPool {
+	p := &WorkerPool{handle: handle, running: make(map[string]int)}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Workers <= 0 {
+		p.opts.Workers = DefaultWorkers
+	}
+	if p.opts.InteractiveWorkers < 0 {
+		p.opts.InteractiveWorkers = 0
+	}
+	if p.opts.MaxQueued <= 0 {
+		p.opts.MaxQueued = DefaultMaxQueued
+	}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+func (p *WorkerPool) Start() {
+	for i := 0; i < p.opts.Workers; i++ {
+		go p.worker(false)
+	}
+	for i := 0; i < p.opts.InteractiveWorkers; i++ {
+		go p.worker(true)
+	}
+}
+
+// Submit 将请求加入队列，队列已满时返回ErrQueueFull
+func (p *WorkerPool) Submit(request *utils.Request) error {
+	priority := RequestPriority(request)
+	p.mutex.Lock()
+	if p.queued() >= p.opts.MaxQueued {
+		p.mutex.Unlock()
+		return ErrQueueFull
+	}
+	p.queues[priority] = append(p.queues[priority], &workItem{request: request, priority: priority, enqueued: time.Now()})
+	p.mutex.Unlock()
+	p.cond.Broadcast()
+	return nil
+}
+
+func (p *WorkerPool) queued() int {
+	n := 0
+	for _, queue := range p.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// Remove 从队列中移除还未执行的请求，请求已经开始执行时返回false
+func (p *WorkerPool) Remove(requestId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for priority, queue := range p.queues {
+		for i, item := range queue {
+			if item.request.RequestId == requestId {
+				p.queues[priority] = append(queue[:i:i], queue[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *WorkerPool) worker(interactiveOnly bool) {
+	for {
+		item := p.next(interactiveOnly)
+		p.handle(item.request)
+		p.mutex.Lock()
+		p.active -= 1
+		p.running[item.request.Resource] -= 1
+		if p.running[item.request.Resource] <= 0 {
+			delete(p.running, item.request.Resource)
+		}
+		p.mutex.Unlock()
+		p.cond.Broadcast()
+	}
+}
+
+// next 取出优先级最高且resource未达到并发限制的请求，没有时等待
+func (p *WorkerPool) next(interactiveOnly bool) *workItem {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for {
+		maxPriority := priorityNum
+		if interactiveOnly {
+			maxPriority = PriorityInteractive + 1
+		}
+		for priority := PriorityInteractive; priority < maxPriority; priority++ {
+			queue := p.queues[priority]
+			for i, item := range queue {
+				// 交互式请求不受resource并发限制
+				if priority != PriorityInteractive && p.resourceFull(item.request.Resource) {
+					continue
+				}
+				p.queues[priority] = append(queue[:i:i], queue[i+1:]...)
+				p.active += 1
+				p.running[item.request.Resource] += 1
+				return item
+			}
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *WorkerPool) resourceFull(resource string) bool {
+	limit, ok := p.opts.ResourceLimits[resource]
+	if !ok {
+		limit = p.opts.DefaultResourceLimit
+	}
+	return limit > 0 && p.running[resource] >= limit
+}
+
+func (p *WorkerPool) Stats() *PoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stats := &PoolStats{
+		Workers:          p.opts.Workers + p.opts.InteractiveWorkers,
+		Running:          p.active,
+		QueuedByPriority: make(map[string]int),
+		RunningResources: make(map[string]int),
+	}
+	var oldest time.Time
+	for priority, queue := range p.queues {
+		stats.QueuedByPriority[Priority(priority).String()] = len(queue)
+		stats.Queued += len(queue)
+		if len(queue) > 0 && (oldest.IsZero() || queue[0].enqueued.Before(oldest)) {
+			oldest = queue[0].enqueued
+		}
+	}
+	if !oldest.IsZero() {
+		stats.OldestQueued = time.Since(oldest).Milliseconds()
+	}
+	for resource, n := range p.running {
+		stats.RunningResources[resource] = n
+	}
+	return stats
+}
@@ -0,0 +1,211 @@
+package container
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubespace/agent/pkg/utils"
+)
+
+func TestRequestPriority(t *testing.T) {
+	cases := []struct {
+		resource string
+		action   string
+		want     Priority
+	}{
+		{resource: "pod", action: EXEC, want: PriorityInteractive},
+		{resource: "pod", action: STDIN, want: PriorityInteractive},
+		{resource: "pod", action: OPENLOG, want: PriorityInteractive},
+		{resource: "watch", action: WATCH, want: PriorityInteractive},
+		{resource: "deployment", action: LIST, want: PriorityRead},
+		{resource: "watch", action: GET, want: PriorityRead},
+		{resource: "helm", action: GET, want: PriorityRead},
+		{resource: "deployment", action: DELETE, want: PriorityWrite},
+		{resource: "helm", action: "create", want: PriorityBulk},
+		{resource: "cluster", action: "apply", want: PriorityBulk},
+	}
+	for _, c := range cases {
+		t.Run(c.resource+"/"+c.action, func(t *testing.T) {
+			if got := RequestPriority(&utils.Request{Resource: c.resource, Action: c.action}); got != c.want {
+				t.Errorf("RequestPriority() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseResourceLimits(t *testing.T) {
+	cases := []struct {
+		limits  string
+		want    map[string]int
+		wantErr bool
+	}{
+		{limits: "", want: map[string]int{}},
+		{limits: "helm=2, cluster=4", want: map[string]int{"helm": 2, "cluster": 4}},
+		{limits: "helm", wantErr: true},
+		{limits: "helm=-1", wantErr: true},
+		{limits: "helm=a", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.limits, func(t *testing.T) {
+			got, err := ParseResourceLimits(c.limits)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("ParseResourceLimits() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if c.wantErr {
+				return
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("ParseResourceLimits() = %v, want %v", got, c.want)
+			}
+			for k, v := range c.want {
+				if got[k] != v {
+					t.Errorf("ParseResourceLimits()[%s] = %d, want %d", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkerPoolSubmit(t *testing.T) {
+	cases := []struct {
+		name      string
+		maxQueued int
+		submit    int
+		wantErrs  int
+	}{
+		{name: "under limit", maxQueued: 3, submit: 2},
+		{name: "at limit", maxQueued: 3, submit: 3},
+		{name: "over limit", maxQueued: 3, submit: 5, wantErrs: 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// 未启动worker，请求都留在队列中
+			p := NewWorkerPool(&PoolOptions{MaxQueued: c.maxQueued}, func(*utils.Request) {})
+			errs := 0
+			for i := 0; i < c.submit; i++ {
+				if err := p.Submit(&utils.Request{Resource: "pod", Action: LIST}); err != nil {
+					if err != ErrQueueFull {
+						t.Fatalf("Submit() error = %v, want %v", err, ErrQueueFull)
+					}
+					errs += 1
+				}
+			}
+			if errs != c.wantErrs {
+				t.Errorf("rejected %d requests, want %d", errs, c.wantErrs)
+			}
+			if queued := p.Stats().Queued; queued != c.submit-c.wantErrs {
+				t.Errorf("queued = %d, want %d", queued, c.submit-c.wantErrs)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolRemove(t *testing.T) {
+	p := NewWorkerPool(nil, func(*utils.Request) {})
+	p.Submit(&utils.Request{RequestId: "1", Resource: "pod", Action: LIST})
+	p.Submit(&utils.Request{RequestId: "2", Resource: "pod", Action: DELETE})
+	cases := []struct {
+		requestId string
+		want      bool
+	}{
+		{requestId: "2", want: true},
+		{requestId: "2", want: false},
+		{requestId: "3", want: false},
+		{requestId: "1", want: true},
+	}
+	for _, c := range cases {
+		if got := p.Remove(c.requestId); got != c.want {
+			t.Errorf("Remove(%s) = %v, want %v", c.requestId, got, c.want)
+		}
+	}
+	if queued := p.Stats().Queued; queued != 0 {
+		t.Errorf("queued = %d, want 0", queued)
+	}
+}
+
+// runPool 单个worker依次处理请求，返回请求的执行顺序
+func runPool(t *testing.T, opts *PoolOptions, requests []*utils.Request) []string {
+	var mutex sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	wg.Add(len(requests))
+	p := NewWorkerPool(opts, func(request *utils.Request) {
+		mutex.Lock()
+		order = append(order, request.RequestId)
+		mutex.Unlock()
+		wg.Done()
+	})
+	for _, request := range requests {
+		if err := p.Submit(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Start()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("requests not finished, handled %v", order)
+	}
+	return order
+}
+
+func TestWorkerPoolPriority(t *testing.T) {
+	order := runPool(t, &PoolOptions{Workers: 1}, []*utils.Request{
+		{RequestId: "bulk", Resource: "helm", Action: "create"},
+		{RequestId: "write", Resource: "pod", Action: DELETE},
+		{RequestId: "read", Resource: "pod", Action: LIST},
+		{RequestId: "exec", Resource: "pod", Action: EXEC},
+	})
+	want := []string{"exec", "read", "write", "bulk"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestWorkerPoolResourceLimit(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 4)
+	p := NewWorkerPool(&PoolOptions{Workers: 4, ResourceLimits: map[string]int{"helm": 1}}, func(request *utils.Request) {
+		started <- request.RequestId
+		<-release
+	})
+	p.Start()
+	p.Submit(&utils.Request{RequestId: "1", Resource: "helm", Action: "create"})
+	p.Submit(&utils.Request{RequestId: "2", Resource: "helm", Action: "create"})
+	p.Submit(&utils.Request{RequestId: "3", Resource: "pod", Action: DELETE})
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-started:
+			got[id] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("requests not started, started %v", got)
+		}
+	}
+	if !got["3"] || len(got) != 2 {
+		t.Fatalf("started = %v, want one helm request and pod request", got)
+	}
+	select {
+	case id := <-started:
+		t.Fatalf("request %s started over helm concurrency limit", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stats := p.Stats(); stats.RunningResources["helm"] != 1 || stats.Queued != 1 {
+		t.Errorf("stats = %+v, want one running and one queued helm request", stats)
+	}
+	close(release)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued helm request not started after release")
+	}
+}
@@ -1,8 +1,10 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/kubespace/agent/pkg/kubernetes"
+	"github.com/kubespace/agent/pkg/policy"
 	"github.com/kubespace/agent/pkg/utils"
 	"github.com/kubespace/agent/pkg/utils/code"
 	corev1 "k8s.io/api/core/v1"
@@ -17,12 +19,14 @@ type Cluster struct {
 	*DynamicResource
 }
 
-func NewCluster(kubeClient *kubernetes.KubeClient, watch *WatchResource) *Cluster {
+func NewCluster(kubeClient *kubernetes.KubeClient, watch *WatchResource, agentPolicy *policy.Policy) *Cluster {
 	c := &Cluster{
 		watch:           watch,
 		KubeClient:      kubeClient,
 		DynamicResource: NewDynamicResource(kubeClient, nil),
 	}
+	c.DynamicResource.redactSecrets = agentPolicy.DenySecretReveal
+	c.DynamicResource.allowClusterWide = agentPolicy.AllowsClusterWide()
 	//c.DoWatch()
 	return c
 }
@@ -62,23 +66,23 @@ type ClusterQueryParams struct {
 	Output    string `json:"output"`
 }
 
-func (c *Cluster) Get(requestParams interface{}) *utils.Response {
+func (c *Cluster) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &ClusterQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Workspace != 0 {
-		return c.WorkspaceOverview(requestParams)
+		return c.WorkspaceOverview(ctx, requestParams)
 	}
 	var bc = BuildCluster{}
-	version, err := c.ClientSet.Discovery().ServerVersion()
+	version, err := c.Client(ctx).ClientSet.Discovery().ServerVersion()
 	if err != nil {
 		klog.Errorf("get version error: %s", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.ClusterVersion = version.GitVersion
 
 	nodes, err := c.KubeClient.NodeInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.NodeNum = len(nodes)
 	var cpu resource.Quantity
@@ -91,12 +95,12 @@ func (c *Cluster) Get(requestParams interface{}) *utils.Response {
 	bc.ClusterMemory = memory.String()
 	namespaces, err := c.KubeClient.NamespaceInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.NamespaceNum = len(namespaces)
 	pods, err := c.KubeClient.PodInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.PodNum = len(pods)
 	for _, p := range pods {
@@ -112,45 +116,45 @@ func (c *Cluster) Get(requestParams interface{}) *utils.Response {
 	}
 	deployments, err := c.KubeClient.DeploymentInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.DeploymentNum = len(deployments)
 	statefulsets, err := c.KubeClient.StatefulSetInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.StatefulSetNum = len(statefulsets)
 	daemonsets, err := c.KubeClient.DaemonSetInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.DaemonSetNum = len(daemonsets)
 	services, err := c.KubeClient.ServiceInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.ServiceNum = len(services)
 	if kubernetes.VersionGreaterThan19(c.KubeClient.Version) {
 		ingresses, err := c.KubeClient.NewIngressInformer().Lister().List(labels.Everything())
 		if err != nil {
-			return &utils.Response{Code: code.ListError, Msg: err.Error()}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
 		bc.IngressNum = len(ingresses)
 	} else {
 		ingresses, err := c.KubeClient.IngressInformer().Lister().List(labels.Everything())
 		if err != nil {
-			return &utils.Response{Code: code.ListError, Msg: err.Error()}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
 		bc.IngressNum = len(ingresses)
 	}
 	sc, err := c.KubeClient.StorageClassInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.StorageClassNum = len(sc)
 	pv, err := c.KubeClient.PersistentVolumeInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.PVNum = len(pv)
 	for _, p := range pv {
@@ -166,62 +170,62 @@ func (c *Cluster) Get(requestParams interface{}) *utils.Response {
 	}
 	pvc, err := c.KubeClient.PersistentVolumeClaimInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.PVCNum = len(pvc)
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: bc}
 }
 
-func (c *Cluster) WorkspaceOverview(requestParams interface{}) *utils.Response {
+func (c *Cluster) WorkspaceOverview(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &ClusterQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "参数namespace为空"}
+		return utils.NewParamsErrorResponse("参数namespace为空")
 	}
 	namespace := queryParams.Namespace
 	workspaceLabels := labels.Set(map[string]string{"kubespace.cn/belong-to": "project"}).AsSelector()
 
 	var bc = BuildCluster{}
-	version, err := c.ClientSet.Discovery().ServerVersion()
+	version, err := c.Client(ctx).ClientSet.Discovery().ServerVersion()
 	if err != nil {
 		klog.Errorf("get version error: %s", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.ClusterVersion = version.GitVersion
 
 	services, err := c.KubeClient.ServiceInformer().Lister().Services(namespace).List(workspaceLabels)
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.ServiceNum = len(services)
 	if kubernetes.VersionGreaterThan19(c.KubeClient.Version) {
 		ingresses, err := c.KubeClient.NewIngressInformer().Lister().Ingresses(namespace).List(workspaceLabels)
 		if err != nil {
-			return &utils.Response{Code: code.ListError, Msg: err.Error()}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
 		bc.IngressNum = len(ingresses)
 	} else {
 		ingresses, err := c.KubeClient.IngressInformer().Lister().Ingresses(namespace).List(workspaceLabels)
 		if err != nil {
-			return &utils.Response{Code: code.ListError, Msg: err.Error()}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
 		bc.IngressNum = len(ingresses)
 	}
 
 	pvc, err := c.KubeClient.PersistentVolumeClaimInformer().Lister().PersistentVolumeClaims(namespace).List(workspaceLabels)
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.PVCNum = len(pvc)
 
 	cm, err := c.KubeClient.ConfigMapInformer().Lister().ConfigMaps(namespace).List(workspaceLabels)
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.ConfigMapNum = len(cm)
 	secret, err := c.KubeClient.SecretInformer().Lister().Secrets(namespace).List(workspaceLabels)
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	bc.SecretNum = len(secret)
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: bc}
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -84,7 +85,14 @@ func NewConfigMap(kubeClient *kubernetes.KubeClient, sendResponse websocket.Send
 	}
 }
 
-func (c *ConfigMap) List(requestParams interface{}) *utils.Response {
+func (c *ConfigMap) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return c.ListTable(ctx, requestParams)
+	}
 	queryParams := &ConfigMapQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	var selector labels.Selector
@@ -95,30 +103,26 @@ func (c *ConfigMap) List(requestParams interface{}) *utils.Response {
 	}
 	configMapList, err := c.KubeClient.ConfigMapInformer().Lister().ConfigMaps(queryParams.Namespace).List(selector)
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var configMapResource []*BuildConfigMap
 	for _, cm := range configMapList {
-		configMapResource = append(configMapResource, c.ToBuildConfigMap(cm))
+		query.Add(cm, c.ToBuildConfigMap(cm))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: configMapResource}
+	return query.Response()
 }
 
-func (c *ConfigMap) Get(requestParams interface{}) *utils.Response {
+func (c *ConfigMap) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &ConfigMapQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	configMap, err := c.KubeClient.ConfigMapInformer().Lister().ConfigMaps(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -134,7 +138,7 @@ func (c *ConfigMap) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, configMap)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		klog.Info(d)
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
@@ -142,18 +146,18 @@ func (c *ConfigMap) Get(requestParams interface{}) *utils.Response {
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: configMap}
 }
 
-func (c *ConfigMap) UpdateObj(updateParams interface{}) *utils.Response {
+func (c *ConfigMap) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &ConfigMapUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "ConfigMap name is blank"}
+		return utils.NewParamsErrorResponse("ConfigMap name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if len(params.Data) == 0 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Data is blank"}
+		return utils.NewParamsErrorResponse("Data is blank")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -164,17 +168,17 @@ func (c *ConfigMap) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		result.Data = params.Data
-		_, updateErr := c.ClientSet.CoreV1().ConfigMaps(params.Namespace).Update(c.context, result, metav1.UpdateOptions{})
+		_, updateErr := c.Client(ctx).ClientSet.CoreV1().ConfigMaps(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
-func (c *ConfigMap) Create(createParams interface{}) *utils.Response {
+func (c *ConfigMap) Create(ctx context.Context, createParams interface{}) *utils.Response {
 
 	params := &ConfigMapUpdateParams{}
 	json.Unmarshal(createParams.([]byte), params)
@@ -186,7 +190,7 @@ func (c *ConfigMap) Create(createParams interface{}) *utils.Response {
 	configMap.Data = params.Data
 	configMap.Namespace = params.Namespace
 
-	cm, err := c.ClientSet.CoreV1().ConfigMaps(params.Namespace).Create(c.context, &configMap, metav1.CreateOptions{})
+	cm, err := c.Client(ctx).ClientSet.CoreV1().ConfigMaps(params.Namespace).Create(ctx, &configMap, metav1.CreateOptions{})
 	if err != nil {
 		klog.Errorf("Create ConfigMap failed: %v", err)
 	}
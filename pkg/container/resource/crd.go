@@ -34,7 +34,6 @@ var CRDGVR = &schema.GroupVersionResource{
 type Crd struct {
 	*kubernetes.KubeClient
 	*DynamicResource
-	context context.Context
 }
 
 func NewCrd(kubeClient *kubernetes.KubeClient) *Crd {
@@ -45,7 +44,6 @@ func NewCrd(kubeClient *kubernetes.KubeClient) *Crd {
 	n := &Crd{
 		KubeClient:      kubeClient,
 		DynamicResource: NewDynamicResource(kubeClient, crdGvr),
-		context:         context.Background(),
 	}
 	return n
 }
@@ -55,21 +53,28 @@ type CrdQueryParams struct {
 	Output string `json:"output"`
 }
 
-func (c *Crd) List(requestParams interface{}) *utils.Response {
+func (c *Crd) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return c.ListTable(ctx, requestParams)
+	}
 	if kubernetes.VersionGreaterThan16(c.Version) {
-		crds, err := c.KubeClient.ApiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().List(c.context, metav1.ListOptions{})
+		crds, err := c.Client(ctx).ApiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
 		if err != nil {
-			return &utils.Response{Code: code.ListError, Msg: err.Error()}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
-		var crdsList []map[string]interface{}
-		for _, crd := range crds.Items {
+		for i := range crds.Items {
+			crd := &crds.Items[i]
 			version := ""
 			for _, ver := range crd.Spec.Versions {
 				if ver.Storage {
 					version = ver.Name
 				}
 			}
-			crdsList = append(crdsList, map[string]interface{}{
+			query.Add(crd, map[string]interface{}{
 				"name":        crd.Name,
 				"scope":       crd.Spec.Scope,
 				"version":     version,
@@ -78,21 +83,21 @@ func (c *Crd) List(requestParams interface{}) *utils.Response {
 				"create_time": crd.CreationTimestamp,
 			})
 		}
-		return &utils.Response{Code: code.Success, Msg: "Success", Data: crdsList}
+		return query.Response()
 	} else {
-		crds, err := c.KubeClient.ApiExtensionsClientSet.ApiextensionsV1beta1().CustomResourceDefinitions().List(c.context, metav1.ListOptions{})
+		crds, err := c.Client(ctx).ApiExtensionsClientSet.ApiextensionsV1beta1().CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
 		if err != nil {
-			return &utils.Response{Code: code.ListError, Msg: err.Error()}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
-		var crdsList []map[string]interface{}
-		for _, crd := range crds.Items {
+		for i := range crds.Items {
+			crd := &crds.Items[i]
 			version := crd.Spec.Version
 			for _, ver := range crd.Spec.Versions {
 				if ver.Storage {
 					version = ver.Name
 				}
 			}
-			crdsList = append(crdsList, map[string]interface{}{
+			query.Add(crd, map[string]interface{}{
 				"name":        crd.Name,
 				"scope":       crd.Spec.Scope,
 				"version":     version,
@@ -100,25 +105,25 @@ func (c *Crd) List(requestParams interface{}) *utils.Response {
 				"create_time": crd.CreationTimestamp,
 			})
 		}
-		return &utils.Response{Code: code.Success, Msg: "Success", Data: crdsList}
+		return query.Response()
 	}
 }
 
-func (c *Crd) Get(requestParams interface{}) *utils.Response {
+func (c *Crd) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &CrdQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	var crd runtime.Object
 	var err error
 	if kubernetes.VersionGreaterThan16(c.KubeClient.Version) {
-		crd, err = c.KubeClient.ApiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Get(c.context, queryParams.Name, metav1.GetOptions{})
+		crd, err = c.Client(ctx).ApiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, queryParams.Name, metav1.GetOptions{})
 	} else {
-		crd, err = c.KubeClient.ApiExtensionsClientSet.ApiextensionsV1beta1().CustomResourceDefinitions().Get(c.context, queryParams.Name, metav1.GetOptions{})
+		crd, err = c.Client(ctx).ApiExtensionsClientSet.ApiextensionsV1beta1().CustomResourceDefinitions().Get(ctx, queryParams.Name, metav1.GetOptions{})
 	}
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -138,7 +143,7 @@ func (c *Crd) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, crd)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
@@ -149,14 +154,12 @@ func (c *Crd) Get(requestParams interface{}) *utils.Response {
 type Cr struct {
 	*kubernetes.KubeClient
 	*DynamicResource
-	context context.Context
 }
 
 func NewCr(kubeClient *kubernetes.KubeClient) *Cr {
 	n := &Cr{
 		KubeClient:      kubeClient,
-		DynamicResource: nil,
-		context:         context.Background(),
+		DynamicResource: NewDynamicResource(kubeClient, nil),
 	}
 	return n
 }
@@ -168,61 +171,69 @@ type CRRequest struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	Output    string `json:"output"`
+	DryRun    bool   `json:"dry_run"`
 }
 
-func (c *Cr) ListCR(requestParams interface{}) *utils.Response {
+func (c *Cr) ListCR(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
 	queryParams := &CRRequest{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Group == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR group is blank"}
+		return utils.NewParamsErrorResponse("CR group is blank")
 	}
 	if queryParams.Resource == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR resource is blank"}
+		return utils.NewParamsErrorResponse("CR resource is blank")
 	}
 	if queryParams.Version == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR version is blank"}
+		return utils.NewParamsErrorResponse("CR version is blank")
 	}
-	dynClient, err := dynamic.NewForConfig(c.Config)
+	dynClient, err := dynamic.NewForConfig(c.Client(ctx).Config)
 	if err != nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "New for client error: " + err.Error()}
+		return utils.NewParamsErrorResponse("New for client error: " + err.Error())
 	}
 	gvr := schema.GroupVersionResource{
 		Group:    queryParams.Group,
 		Version:  queryParams.Version,
 		Resource: queryParams.Resource,
 	}
+	if query.Output == OutputTable {
+		return c.listTable(ctx, requestParams, &gvr)
+	}
 	crdClient := dynClient.Resource(gvr)
-	crs, err := crdClient.List(c.context, metav1.ListOptions{})
+	crs, err := crdClient.List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var crList []map[string]interface{}
-	for _, cr := range crs.Items {
-		crList = append(crList, map[string]interface{}{
+	for i := range crs.Items {
+		cr := &crs.Items[i]
+		query.Add(cr, map[string]interface{}{
 			"name":        cr.GetName(),
 			"namespace":   cr.GetNamespace(),
 			"create_time": cr.GetCreationTimestamp(),
 		})
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: crList}
+	return query.Response()
 }
 
-func (c *Cr) GetCR(requestParams interface{}) *utils.Response {
+func (c *Cr) GetCR(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &CRRequest{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	klog.Infof("%+v", queryParams)
 	if queryParams.Group == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR group is blank"}
+		return utils.NewParamsErrorResponse("CR group is blank")
 	}
 	if queryParams.Resource == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR resource is blank"}
+		return utils.NewParamsErrorResponse("CR resource is blank")
 	}
 	if queryParams.Version == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR version is blank"}
+		return utils.NewParamsErrorResponse("CR version is blank")
 	}
-	dynClient, err := dynamic.NewForConfig(c.Config)
+	dynClient, err := dynamic.NewForConfig(c.Client(ctx).Config)
 	if err != nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "New for client error: " + err.Error()}
+		return utils.NewParamsErrorResponse("New for client error: " + err.Error())
 	}
 	gvr := schema.GroupVersionResource{
 		Group:    queryParams.Group,
@@ -232,17 +243,17 @@ func (c *Cr) GetCR(requestParams interface{}) *utils.Response {
 	crdClient := dynClient.Resource(gvr)
 	var cr *unstructured.Unstructured
 	if queryParams.Namespace == "" {
-		cr, err = crdClient.Get(c.context, queryParams.Name, metav1.GetOptions{})
+		cr, err = crdClient.Get(ctx, queryParams.Name, metav1.GetOptions{})
 	} else {
-		cr, err = crdClient.Namespace(queryParams.Namespace).Get(c.context, queryParams.Name, metav1.GetOptions{})
+		cr, err = crdClient.Namespace(queryParams.Namespace).Get(ctx, queryParams.Name, metav1.GetOptions{})
 	}
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		y, err := yaml.Marshal(cr)
 		if err != nil {
-			return &utils.Response{Code: code.MarshalError, Msg: err.Error()}
+			return utils.NewErrorResponse(code.MarshalError, err)
 		}
 		return &utils.Response{Code: code.Success, Data: y}
 	}
@@ -250,22 +261,54 @@ func (c *Cr) GetCR(requestParams interface{}) *utils.Response {
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: cr}
 }
 
-func (c *Cr) DeleteCrs(requestParams interface{}) *utils.Response {
+type CRPatchParams struct {
+	CRRequest
+	PatchParams
+}
+
+func (c *Cr) PatchCR(ctx context.Context, requestParams interface{}) *utils.Response {
+	params := &CRPatchParams{}
+	json.Unmarshal(requestParams.([]byte), params)
+	if params.Group == "" {
+		return utils.NewParamsErrorResponse("CR group is blank")
+	}
+	if params.Resource == "" {
+		return utils.NewParamsErrorResponse("CR resource is blank")
+	}
+	if params.Version == "" {
+		return utils.NewParamsErrorResponse("CR version is blank")
+	}
+	if params.Name == "" {
+		return utils.NewParamsErrorResponse("CR name is blank")
+	}
+	gvr := schema.GroupVersionResource{
+		Group:    params.Group,
+		Version:  params.Version,
+		Resource: params.Resource,
+	}
+	var client dynamic.ResourceInterface = c.Client(ctx).DynamicClient.Resource(gvr)
+	if params.Namespace != "" {
+		client = c.Client(ctx).DynamicClient.Resource(gvr).Namespace(params.Namespace)
+	}
+	return patchObject(ctx, client, params.Name, &params.PatchParams, params.DryRun, c.redactSecrets)
+}
+
+func (c *Cr) DeleteCrs(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &CRRequest{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	klog.Infof("%+v", queryParams)
 	if queryParams.Group == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR group is blank"}
+		return utils.NewParamsErrorResponse("CR group is blank")
 	}
 	if queryParams.Resource == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR resource is blank"}
+		return utils.NewParamsErrorResponse("CR resource is blank")
 	}
 	if queryParams.Version == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CR version is blank"}
+		return utils.NewParamsErrorResponse("CR version is blank")
 	}
-	dynClient, err := dynamic.NewForConfig(c.Config)
+	dynClient, err := dynamic.NewForConfig(c.Client(ctx).Config)
 	if err != nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "New for client error: " + err.Error()}
+		return utils.NewParamsErrorResponse("New for client error: " + err.Error())
 	}
 	gvr := schema.GroupVersionResource{
 		Group:    queryParams.Group,
@@ -274,12 +317,12 @@ func (c *Cr) DeleteCrs(requestParams interface{}) *utils.Response {
 	}
 	crdClient := dynClient.Resource(gvr)
 	if queryParams.Namespace == "" {
-		err = crdClient.Delete(c.context, queryParams.Name, metav1.DeleteOptions{})
+		err = crdClient.Delete(ctx, queryParams.Name, metav1.DeleteOptions{DryRun: dryRunOption(queryParams.DryRun)})
 	} else {
-		err = crdClient.Namespace(queryParams.Namespace).Delete(c.context, queryParams.Name, metav1.DeleteOptions{})
+		err = crdClient.Namespace(queryParams.Namespace).Delete(ctx, queryParams.Name, metav1.DeleteOptions{DryRun: dryRunOption(queryParams.DryRun)})
 	}
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 
 	return &utils.Response{Code: code.Success, Msg: "Success"}
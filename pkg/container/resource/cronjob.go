@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -92,19 +93,24 @@ type CronJobUpdateParams struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	Replicas  int32  `json:"replicas"`
+	// DryRun 只由apiserver校验并返回更新后的对象，不持久化
+	DryRun bool `json:"dry_run"`
 }
 
-func (c *CronJob) List(requestParams interface{}) *utils.Response {
+func (c *CronJob) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return c.ListTable(ctx, requestParams)
+	}
 	queryParams := &CronJobQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := c.KubeClient.InformerRegistry.CronJobInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var cronjobs []*BuildCronJob
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -115,23 +121,23 @@ func (c *CronJob) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		cronjobs = append(cronjobs, c.ToBuildCronJob(ds))
+		query.Add(ds, c.ToBuildCronJob(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: cronjobs}
+	return query.Response()
 }
 
-func (c *CronJob) Get(requestParams interface{}) *utils.Response {
+func (c *CronJob) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &CronJobQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CronJob name is blank"}
+		return utils.NewParamsErrorResponse("CronJob name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	cronjob, err := c.KubeClient.InformerRegistry.CronJobInformer().Lister().CronJobs(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -148,25 +154,26 @@ func (c *CronJob) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, cronjob)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: cronjob}
 }
 
-func (c *CronJob) UpdateObj(updateParams interface{}) *utils.Response {
+func (c *CronJob) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &CronJobUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "CronJob name is blank"}
+		return utils.NewParamsErrorResponse("CronJob name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
+	var updated interface{}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
 		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
@@ -174,14 +181,17 @@ func (c *CronJob) UpdateObj(updateParams interface{}) *utils.Response {
 		if getErr != nil {
 			panic(fmt.Errorf("failed to get latest version of CronJob: %v", getErr))
 		}
+		// informer缓存中的对象不能修改
+		result = result.DeepCopy()
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := c.ClientSet.BatchV1beta1().CronJobs(params.Namespace).Update(c.context, result, metav1.UpdateOptions{})
+		obj, updateErr := c.Client(ctx).ClientSet.BatchV1beta1().CronJobs(params.Namespace).Update(ctx, result, metav1.UpdateOptions{DryRun: dryRunOption(params.DryRun)})
+		updated = obj
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success"}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: updated}
 }
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -100,17 +101,20 @@ type DaemonSetUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (d *DaemonSet) List(requestParams interface{}) *utils.Response {
+func (d *DaemonSet) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return d.ListTable(ctx, requestParams)
+	}
 	queryParams := &StatefulSetQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := d.KubeClient.InformerRegistry.DaemonSetInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var dss []*BuildDaemonSet
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -121,23 +125,23 @@ func (d *DaemonSet) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		dss = append(dss, d.ToBuildDaemonSet(ds))
+		query.Add(ds, d.ToBuildDaemonSet(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: dss}
+	return query.Response()
 }
 
-func (d *DaemonSet) Get(requestParams interface{}) *utils.Response {
+func (d *DaemonSet) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &StatefulSetQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "DaemonSet name is blank"}
+		return utils.NewParamsErrorResponse("DaemonSet name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	ds, err := d.KubeClient.InformerRegistry.DaemonSetInformer().Lister().DaemonSets(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -154,25 +158,26 @@ func (d *DaemonSet) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, ds)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: ds}
 }
 
-func (d *DaemonSet) UpdateObj(updateParams interface{}) *utils.Response {
+func (d *DaemonSet) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &StatefulSetUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "DaemonSet name is blank"}
+		return utils.NewParamsErrorResponse("DaemonSet name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
+	var updated interface{}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
 		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
@@ -180,14 +185,17 @@ func (d *DaemonSet) UpdateObj(updateParams interface{}) *utils.Response {
 		if getErr != nil {
 			panic(fmt.Errorf("failed to get latest version of DaemonSet: %v", getErr))
 		}
+		// informer缓存中的对象不能修改
+		result = result.DeepCopy()
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := d.ClientSet.AppsV1().DaemonSets(params.Namespace).Update(d.context, result, metav1.UpdateOptions{})
+		obj, updateErr := d.Client(ctx).ClientSet.AppsV1().DaemonSets(params.Namespace).Update(ctx, result, metav1.UpdateOptions{DryRun: dryRunOption(params.DryRun)})
+		updated = obj
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success"}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: updated}
 }
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -104,19 +105,24 @@ type DeploymentUpdateParams struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	Replicas  int32  `json:"replicas"`
+	// DryRun 只由apiserver校验并返回更新后的对象，不持久化
+	DryRun bool `json:"dry_run"`
 }
 
-func (d *Deployment) List(requestParams interface{}) *utils.Response {
+func (d *Deployment) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return d.ListTable(ctx, requestParams)
+	}
 	queryParams := &DeploymentQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	dpList, err := d.KubeClient.InformerRegistry.DeploymentInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var dps []*BuildDeployment
 	for _, dp := range dpList {
 		if queryParams.UID != "" && string(dp.UID) != queryParams.UID {
 			continue
@@ -127,23 +133,23 @@ func (d *Deployment) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(dp.Name, queryParams.Name) {
 			continue
 		}
-		dps = append(dps, d.ToBuildDeployment(dp))
+		query.Add(dp, d.ToBuildDeployment(dp))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: dps}
+	return query.Response()
 }
 
-func (d *Deployment) Get(requestParams interface{}) *utils.Response {
+func (d *Deployment) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &DeploymentQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Deployment name is blank"}
+		return utils.NewParamsErrorResponse("Deployment name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	dp, err := d.KubeClient.InformerRegistry.DeploymentInformer().Lister().Deployments(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -160,25 +166,26 @@ func (d *Deployment) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, dp)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: dp}
 }
 
-func (d *Deployment) UpdateObj(updateParams interface{}) *utils.Response {
+func (d *Deployment) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &DeploymentUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Deployment name is blank"}
+		return utils.NewParamsErrorResponse("Deployment name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
+	var updated interface{}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
 		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
@@ -186,14 +193,17 @@ func (d *Deployment) UpdateObj(updateParams interface{}) *utils.Response {
 		if getErr != nil {
 			panic(fmt.Errorf("failed to get latest version of Deployment: %v", getErr))
 		}
+		// informer缓存中的对象不能修改
+		result = result.DeepCopy()
 
 		result.Spec.Replicas = &params.Replicas
-		_, updateErr := d.ClientSet.AppsV1().Deployments(params.Namespace).Update(d.context, result, metav1.UpdateOptions{})
+		obj, updateErr := d.Client(ctx).ClientSet.AppsV1().Deployments(params.Namespace).Update(ctx, result, metav1.UpdateOptions{DryRun: dryRunOption(params.DryRun)})
+		updated = obj
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success"}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: updated}
 }
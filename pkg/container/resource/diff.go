@@ -0,0 +1,160 @@
+package resource
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"path"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DiffCreate    = "create"
+	DiffUpdate    = "update"
+	DiffUnchanged = "unchanged"
+	DiffFailed    = "failed"
+)
+
+// diffIgnoredFields diff时忽略的字段，由apiserver维护，与用户的修改无关
+var diffIgnoredFields = [][]string{
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "uid"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"status"},
+}
+
+type DiffResult struct {
+	Index     int    `json:"index"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Operation create为新建对象，update为修改已有对象，unchanged为没有变化
+	Operation string `json:"operation"`
+	// Diff 线上对象与apply后对象的unified diff
+	Diff  string       `json:"diff"`
+	Error *utils.Error `json:"error,omitempty"`
+	Msg   string       `json:"msg,omitempty"`
+}
+
+// DiffYaml 与kubectl diff相同，对每个对象执行server-side apply的dry run，返回与线上对象的差异
+func (d *DynamicResource) DiffYaml(ctx context.Context, diffParams interface{}) *utils.Response {
+	params := &ApplyParams{}
+	json.Unmarshal(diffParams.([]byte), params)
+
+	docs, err := readDocuments(params.YamlStr)
+	if err != nil {
+		return utils.NewParamsErrorResponse("read yaml error: " + err.Error())
+	}
+	var maskKey []byte
+	if d.redactSecrets {
+		// 每次diff使用随机的key计算secret值的摘要，只能看出值是否变化，无法通过摘要推测原值
+		maskKey = make([]byte, 32)
+		if _, err = rand.Read(maskKey); err != nil {
+			return &utils.Response{Code: code.ApplyError, Msg: "generate secret mask key error: " + err.Error()}
+		}
+	}
+	var results []*DiffResult
+	failed := false
+	for _, doc := range docs {
+		result := d.diffObject(ctx, doc.index, doc.data, maskKey)
+		if result.Operation == DiffFailed {
+			failed = true
+		}
+		results = append(results, result)
+	}
+	if failed {
+		return &utils.Response{Code: code.ApplyError, Msg: "diff some objects failed", Data: results}
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: results}
+}
+
+// diffObject maskKey不为空时secret的值替换为摘要后再比较
+func (d *DynamicResource) diffObject(ctx context.Context, index int, buf []byte, maskKey []byte) *DiffResult {
+	result := &DiffResult{Index: index}
+	obj, dr, err := d.buildDynamicResourceClient(ctx, buf)
+	result.Kind, result.Namespace, result.Name = obj.GetKind(), obj.GetNamespace(), obj.GetName()
+	if err != nil {
+		result.Operation, result.Msg, result.Error = DiffFailed, err.Error(), utils.NewError(err)
+		return result
+	}
+	live, err := dr.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			result.Operation, result.Msg, result.Error = DiffFailed, err.Error(), utils.NewError(err)
+			return result
+		}
+		live = nil
+	}
+	force := true
+	merged, err := dr.Patch(ctx, obj.GetName(), types.ApplyPatchType, buf, metav1.PatchOptions{
+		FieldManager: "kubespace",
+		Force:        &force,
+		DryRun:       []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		result.Operation, result.Msg, result.Error = DiffFailed, err.Error(), utils.NewError(err)
+		return result
+	}
+
+	if maskKey != nil {
+		live, merged = maskSecretData(live, maskKey), maskSecretData(merged, maskKey)
+	}
+	from, err := diffYaml(live)
+	if err != nil {
+		result.Operation, result.Msg = DiffFailed, err.Error()
+		return result
+	}
+	to, err := diffYaml(merged)
+	if err != nil {
+		result.Operation, result.Msg = DiffFailed, err.Error()
+		return result
+	}
+	objPath := path.Join(result.Kind, result.Namespace, result.Name)
+	result.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "live/" + objPath,
+		ToFile:   "merged/" + objPath,
+		Context:  3,
+	})
+	if err != nil {
+		result.Operation, result.Msg = DiffFailed, fmt.Sprintf("diff %s error: %s", objPath, err.Error())
+		return result
+	}
+	switch {
+	case live == nil:
+		result.Operation = DiffCreate
+	case result.Diff == "":
+		result.Operation = DiffUnchanged
+	default:
+		result.Operation = DiffUpdate
+	}
+	return result
+}
+
+// diffYaml 去掉忽略的字段后转为yaml，对象不存在时为空
+func diffYaml(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	obj = obj.DeepCopy()
+	for _, field := range diffIgnoredFields {
+		unstructured.RemoveNestedField(obj.Object, field...)
+	}
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
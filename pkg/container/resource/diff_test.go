@@ -0,0 +1,170 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestMaskSecretData(t *testing.T) {
+	key := []byte("key")
+	secret := func(password string) *unstructured.Unstructured {
+		obj := newTestObject("v1", "Secret", "default", "a", map[string]interface{}{
+			"data": map[string]interface{}{"password": password, "user": "YWRtaW4="},
+		})
+		obj.SetResourceVersion("1")
+		return obj
+	}
+	cases := []struct {
+		name string
+		from *unstructured.Unstructured
+		to   *unstructured.Unstructured
+		// wantDiff 摘要后是否仍能看出变化
+		wantDiff bool
+		// hidden 不能出现在diff结果中的值
+		hidden []string
+	}{
+		{name: "unchanged secret", from: secret("c2VjcmV0"), to: secret("c2VjcmV0"), hidden: []string{"c2VjcmV0", "YWRtaW4="}},
+		{name: "changed secret", from: secret("c2VjcmV0"), to: secret("bmV3"), wantDiff: true, hidden: []string{"c2VjcmV0", "bmV3", "YWRtaW4="}},
+		{name: "created secret", to: secret("c2VjcmV0"), wantDiff: true, hidden: []string{"c2VjcmV0"}},
+		{name: "other kind kept", from: newTestObject("v1", "ConfigMap", "default", "a", testSecretFields()), to: newTestObject("v1", "ConfigMap", "default", "a", testSecretFields())},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			from, err := diffYaml(maskSecretData(c.from, key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			to, err := diffYaml(maskSecretData(c.to, key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (from != to) != c.wantDiff {
+				t.Errorf("masked from %q to %q, want diff %v", from, to, c.wantDiff)
+			}
+			for _, v := range c.hidden {
+				if strings.Contains(from, v) || strings.Contains(to, v) {
+					t.Errorf("masked yaml reveals %q:\n%s\n%s", v, from, to)
+				}
+			}
+			if c.to != nil && c.to.GetKind() == "ConfigMap" && !strings.Contains(to, "c2VjcmV0") {
+				t.Errorf("non secret object masked: %s", to)
+			}
+		})
+	}
+}
+
+func TestMaskSecretDataKey(t *testing.T) {
+	secret := newTestObject("v1", "Secret", "default", "a", testSecretFields())
+	a, _ := diffYaml(maskSecretData(secret, []byte("a")))
+	b, _ := diffYaml(maskSecretData(secret, []byte("b")))
+	if a == b {
+		t.Errorf("mask does not depend on key")
+	}
+	if password, _, _ := unstructured.NestedString(secret.Object, "data", "password"); password != "c2VjcmV0" {
+		t.Errorf("original secret modified: %v", secret.Object)
+	}
+}
+
+// applyDryRunReactor fake dynamic client不支持apply patch，返回patch的内容加上apiserver维护的字段
+func applyDryRunReactor(action clienttesting.Action) (bool, runtime.Object, error) {
+	patch := action.(clienttesting.PatchAction)
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+		return true, nil, err
+	}
+	obj.SetResourceVersion("2")
+	obj.SetUID("uid")
+	unstructured.SetNestedField(obj.Object, "Ready", "status", "phase")
+	return true, obj, nil
+}
+
+func TestDiffYaml(t *testing.T) {
+	live := newTestObject("v1", "ConfigMap", "default", "a", testSecretFields())
+	live.SetResourceVersion("1")
+	cases := []struct {
+		name          string
+		yaml          string
+		redactSecrets bool
+		wantCode      string
+		wantOperation string
+		// wantDiff diff中应包含的内容，hidden 不能出现的内容
+		wantDiff []string
+		hidden   []string
+	}{
+		{
+			name:          "create",
+			yaml:          `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"b","namespace":"default"},"data":{"k":"v"}}`,
+			wantCode:      code.Success,
+			wantOperation: DiffCreate,
+			wantDiff:      []string{"--- live/ConfigMap/default/b", "+++ merged/ConfigMap/default/b", "+  k: v"},
+		},
+		{
+			name:          "update",
+			yaml:          `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"},"data":{"password":"bmV3"},"stringData":{"token":"secret"}}`,
+			wantCode:      code.Success,
+			wantOperation: DiffUpdate,
+			wantDiff:      []string{"-  password: c2VjcmV0", "+  password: bmV3"},
+			hidden:        []string{"resourceVersion", "uid", "status"},
+		},
+		{
+			name:          "unchanged ignores server fields",
+			yaml:          `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"},"data":{"password":"c2VjcmV0"},"stringData":{"token":"secret"}}`,
+			wantCode:      code.Success,
+			wantOperation: DiffUnchanged,
+		},
+		{
+			name:          "secret values masked",
+			yaml:          `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s","namespace":"default"},"data":{"password":"c2VjcmV0"}}`,
+			redactSecrets: true,
+			wantCode:      code.Success,
+			wantOperation: DiffCreate,
+			wantDiff:      []string{"password: <redacted hmac-sha256:"},
+			hidden:        []string{"c2VjcmV0"},
+		},
+		{
+			name:          "unknown kind",
+			yaml:          `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w"}}`,
+			wantCode:      code.ApplyError,
+			wantOperation: DiffFailed,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, client := newTestDynamicResource(nil, live.DeepCopy())
+			d.redactSecrets = c.redactSecrets
+			client.PrependReactor("patch", "*", applyDryRunReactor)
+			params, _ := json.Marshal(&ApplyParams{YamlStr: c.yaml})
+			resp := d.DiffYaml(context.Background(), params)
+			if resp.Code != c.wantCode {
+				t.Fatalf("DiffYaml() code = %s, want %s, msg %s", resp.Code, c.wantCode, resp.Msg)
+			}
+			results := resp.Data.([]*DiffResult)
+			if len(results) != 1 {
+				t.Fatalf("results = %d, want 1", len(results))
+			}
+			if results[0].Operation != c.wantOperation {
+				t.Errorf("operation = %s, want %s, msg %s", results[0].Operation, c.wantOperation, results[0].Msg)
+			}
+			for _, s := range c.wantDiff {
+				if !strings.Contains(results[0].Diff, s) {
+					t.Errorf("diff does not contain %q:\n%s", s, results[0].Diff)
+				}
+			}
+			for _, s := range c.hidden {
+				if strings.Contains(results[0].Diff, s) {
+					t.Errorf("diff contains %q:\n%s", s, results[0].Diff)
+				}
+			}
+			if c.wantOperation == DiffUnchanged && results[0].Diff != "" {
+				t.Errorf("unchanged diff = %q", results[0].Diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kubespace/agent/pkg/kubernetes"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/klog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryCacheTTL 缓存的API发现结果过期时间，新安装的CRD最迟在该时间后可见
+const DiscoveryCacheTTL = 5 * time.Minute
+
+// Discovery 返回集群实际提供的API组、版本及资源，server据此动态生成导航
+type Discovery struct {
+	*kubernetes.KubeClient
+	cached    discovery.CachedDiscoveryInterface
+	mutex     sync.Mutex
+	refreshed time.Time
+}
+
+func NewDiscovery(kubeClient *kubernetes.KubeClient) *Discovery {
+	return &Discovery{
+		KubeClient: kubeClient,
+		cached:     memory.NewMemCacheClient(kubeClient.DiscoveryClient),
+	}
+}
+
+type DiscoveryParams struct {
+	// Refresh 清除缓存重新获取
+	Refresh bool `json:"refresh"`
+}
+
+type DiscoveryGroup struct {
+	Name             string              `json:"name"`
+	PreferredVersion string              `json:"preferred_version"`
+	Versions         []string            `json:"versions"`
+	Resources        []DiscoveryResource `json:"resources"`
+}
+
+type DiscoveryResource struct {
+	Name         string   `json:"name"`
+	SingularName string   `json:"singular_name"`
+	Kind         string   `json:"kind"`
+	Group        string   `json:"group"`
+	Version      string   `json:"version"`
+	Namespaced   bool     `json:"namespaced"`
+	Verbs        []string `json:"verbs"`
+	ShortNames   []string `json:"short_names"`
+	Categories   []string `json:"categories"`
+	// Subresources 如status、scale、log、exec
+	Subresources []string `json:"subresources"`
+}
+
+type DiscoveryResult struct {
+	ServerVersion string           `json:"server_version"`
+	Groups        []DiscoveryGroup `json:"groups"`
+	// FailedGroups 获取失败的组，如聚合API服务不可用
+	FailedGroups map[string]string `json:"failed_groups,omitempty"`
+	CacheTime    time.Time         `json:"cache_time"`
+}
+
+func (d *Discovery) Get(ctx context.Context, requestParams interface{}) *utils.Response {
+	params := &DiscoveryParams{}
+	json.Unmarshal(requestParams.([]byte), params)
+
+	var client discovery.DiscoveryInterface = d.cached
+	cacheTime := time.Now()
+	if impersonated := d.Client(ctx); impersonated != d.KubeClient {
+		// 模拟用户时以该用户身份获取，不使用agent的缓存
+		client = impersonated.DiscoveryClient
+	} else {
+		d.mutex.Lock()
+		if params.Refresh || time.Since(d.refreshed) > DiscoveryCacheTTL {
+			d.cached.Invalidate()
+			d.refreshed = time.Now()
+		}
+		cacheTime = d.refreshed
+		d.mutex.Unlock()
+	}
+
+	groups, resourceLists, err := client.ServerGroupsAndResources()
+	result := &DiscoveryResult{CacheTime: cacheTime}
+	if err != nil {
+		groupErr, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+		if !ok {
+			return utils.NewErrorResponse(code.GetError, err)
+		}
+		// 部分组失败时仍返回其他组
+		result.FailedGroups = make(map[string]string)
+		for gv, e := range groupErr.Groups {
+			result.FailedGroups[gv.String()] = e.Error()
+		}
+		klog.Warningf("discovery failed groups: %v", result.FailedGroups)
+	}
+	if version, err := client.ServerVersion(); err == nil {
+		result.ServerVersion = version.GitVersion
+	}
+
+	resources := make(map[schema.GroupVersion][]DiscoveryResource)
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		resources[gv] = buildDiscoveryResources(gv, list.APIResources)
+	}
+	for _, group := range groups {
+		g := DiscoveryGroup{Name: group.Name, PreferredVersion: group.PreferredVersion.Version}
+		for _, v := range group.Versions {
+			g.Versions = append(g.Versions, v.Version)
+		}
+		// 只返回首选版本的资源，其他版本可通过versions访问
+		g.Resources = resources[schema.GroupVersion{Group: group.Name, Version: group.PreferredVersion.Version}]
+		result.Groups = append(result.Groups, g)
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: result}
+}
+
+func buildDiscoveryResources(gv schema.GroupVersion, apiResources []metav1.APIResource) []DiscoveryResource {
+	var res []DiscoveryResource
+	subresources := make(map[string][]string)
+	for _, r := range apiResources {
+		if parts := strings.SplitN(r.Name, "/", 2); len(parts) == 2 {
+			subresources[parts[0]] = append(subresources[parts[0]], parts[1])
+			continue
+		}
+		res = append(res, DiscoveryResource{
+			Name:         r.Name,
+			SingularName: r.SingularName,
+			Kind:         r.Kind,
+			Group:        gv.Group,
+			Version:      gv.Version,
+			Namespaced:   r.Namespaced,
+			Verbs:        r.Verbs,
+			ShortNames:   r.ShortNames,
+			Categories:   r.Categories,
+		})
+	}
+	for i := range res {
+		res[i].Subresources = subresources[res[i].Name]
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,141 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+)
+
+func TestBuildDiscoveryResources(t *testing.T) {
+	gv := schema.GroupVersion{Group: "apps", Version: "v1"}
+	cases := []struct {
+		name         string
+		apiResources []metav1.APIResource
+		want         []DiscoveryResource
+	}{
+		{name: "empty"},
+		{
+			name: "sorted by name",
+			apiResources: []metav1.APIResource{
+				{Name: "statefulsets", Kind: "StatefulSet", Namespaced: true, Verbs: []string{"get"}},
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}, Categories: []string{"all"}},
+			},
+			want: []DiscoveryResource{
+				{Name: "deployments", Kind: "Deployment", Group: "apps", Version: "v1", Namespaced: true, ShortNames: []string{"deploy"}, Categories: []string{"all"}},
+				{Name: "statefulsets", Kind: "StatefulSet", Group: "apps", Version: "v1", Namespaced: true, Verbs: []string{"get"}},
+			},
+		},
+		{
+			name: "subresources attached to parent",
+			apiResources: []metav1.APIResource{
+				{Name: "deployments/status", Kind: "Deployment"},
+				{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Namespaced: true},
+				{Name: "deployments/scale", Kind: "Scale"},
+			},
+			want: []DiscoveryResource{
+				{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Group: "apps", Version: "v1", Namespaced: true, Subresources: []string{"status", "scale"}},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := buildDiscoveryResources(gv, c.apiResources); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("buildDiscoveryResources() = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func newTestDiscovery() (*Discovery, *discoveryfake.FakeDiscovery) {
+	fake := newTestFakeDiscovery()
+	return &Discovery{cached: memory.NewMemCacheClient(fake)}, fake
+}
+
+func getDiscovery(t *testing.T, d *Discovery, refresh bool) map[string]DiscoveryGroup {
+	params, _ := json.Marshal(&DiscoveryParams{Refresh: refresh})
+	resp := d.Get(context.Background(), params)
+	if resp.Code != code.Success {
+		t.Fatalf("Get() = %s %s", resp.Code, resp.Msg)
+	}
+	result := resp.Data.(*DiscoveryResult)
+	if result.ServerVersion != "v1.23.5" {
+		t.Errorf("server version = %q, want v1.23.5", result.ServerVersion)
+	}
+	groups := make(map[string]DiscoveryGroup)
+	for _, g := range result.Groups {
+		groups[g.Name] = g
+	}
+	return groups
+}
+
+func TestDiscoveryGet(t *testing.T) {
+	d, _ := newTestDiscovery()
+	groups := getDiscovery(t, d, false)
+	cases := []struct {
+		group         string
+		wantPreferred string
+		wantVersions  []string
+		wantResources []string
+	}{
+		{group: "", wantPreferred: "v1", wantVersions: []string{"v1"}, wantResources: []string{"configmaps", "namespaces", "pods", "secrets"}},
+		{group: "autoscaling", wantPreferred: "v2", wantVersions: []string{"v2", "v1"}, wantResources: []string{"horizontalpodautoscalers"}},
+	}
+	for _, c := range cases {
+		t.Run(c.group, func(t *testing.T) {
+			g, ok := groups[c.group]
+			if !ok {
+				t.Fatalf("group %q not found in %v", c.group, groups)
+			}
+			if g.PreferredVersion != c.wantPreferred || !reflect.DeepEqual(g.Versions, c.wantVersions) {
+				t.Errorf("versions = %s %v, want %s %v", g.PreferredVersion, g.Versions, c.wantPreferred, c.wantVersions)
+			}
+			var names []string
+			for _, r := range g.Resources {
+				names = append(names, r.Name)
+				if r.Version != c.wantPreferred {
+					t.Errorf("resource %s version = %s, want preferred %s", r.Name, r.Version, c.wantPreferred)
+				}
+			}
+			if !reflect.DeepEqual(names, c.wantResources) {
+				t.Errorf("resources = %v, want %v", names, c.wantResources)
+			}
+		})
+	}
+	for _, r := range groups[""].Resources {
+		if r.Name == "pods" && !reflect.DeepEqual(r.Subresources, []string{"log"}) {
+			t.Errorf("pods subresources = %v, want [log]", r.Subresources)
+		}
+	}
+}
+
+func TestDiscoveryRefresh(t *testing.T) {
+	cases := []struct {
+		name    string
+		refresh bool
+		// wantNew 新增的组是否可见
+		wantNew bool
+	}{
+		{name: "cached", refresh: false},
+		{name: "refresh", refresh: true, wantNew: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, fake := newTestDiscovery()
+			getDiscovery(t, d, false)
+			fake.Resources = append(fake.Resources, &metav1.APIResourceList{
+				GroupVersion: "example.com/v1",
+				APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+			})
+			if _, ok := getDiscovery(t, d, c.refresh)["example.com"]; ok != c.wantNew {
+				t.Errorf("new group visible = %v, want %v", ok, c.wantNew)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// dryRunHandler get返回不存在，delete返回成功，其他修改请求返回固定的对象
+func dryRunHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Reason:   metav1.StatusReasonNotFound,
+			Code:     http.StatusNotFound,
+		})
+	case http.MethodDelete:
+		json.NewEncoder(w).Encode(&metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metav1.StatusSuccess})
+	default:
+		w.Write([]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"c","namespace":"dev","resourceVersion":"1"}}`))
+	}
+}
+
+// requestDryRun 返回修改请求的dryRun参数，delete的参数在body中
+func requestDryRun(r testRequest) []string {
+	if r.Method == http.MethodDelete {
+		opts := &metav1.DeleteOptions{}
+		json.Unmarshal(r.Body, opts)
+		return opts.DryRun
+	}
+	return r.Query["dryRun"]
+}
+
+func TestDryRunActions(t *testing.T) {
+	configMap := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"c","namespace":"dev"}}`
+	cases := []struct {
+		name   string
+		method string
+		call   func(d *DynamicResource, params []byte) string
+		params map[string]interface{}
+	}{
+		{
+			name:   "create yaml",
+			method: http.MethodPost,
+			call:   func(d *DynamicResource, params []byte) string { return d.CreateYaml(context.Background(), params).Code },
+			params: map[string]interface{}{"yaml": configMap},
+		},
+		{
+			name:   "apply yaml",
+			method: http.MethodPatch,
+			call:   func(d *DynamicResource, params []byte) string { return d.ApplyYaml(context.Background(), params).Code },
+			params: map[string]interface{}{"yaml": configMap},
+		},
+		{
+			name:   "update gvr yaml",
+			method: http.MethodPut,
+			call: func(d *DynamicResource, params []byte) string {
+				return d.UpdateGVRYaml(context.Background(), params).Code
+			},
+			params: map[string]interface{}{"yaml": configMap},
+		},
+		{
+			name:   "update yaml",
+			method: http.MethodPut,
+			call:   func(d *DynamicResource, params []byte) string { return d.UpdateYaml(context.Background(), params).Code },
+			params: map[string]interface{}{"yaml": configMap, "namespace": "dev"},
+		},
+		{
+			name:   "patch",
+			method: http.MethodPatch,
+			call:   func(d *DynamicResource, params []byte) string { return d.Patch(context.Background(), params).Code },
+			params: map[string]interface{}{"name": "c", "namespace": "dev", "patch_type": "merge", "patch": `{"data":{"a":"b"}}`},
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			call:   func(d *DynamicResource, params []byte) string { return d.Delete(context.Background(), params).Code },
+			params: map[string]interface{}{"resources": []map[string]string{{"name": "c", "namespace": "dev"}}},
+		},
+	}
+	for _, c := range cases {
+		for _, dryRun := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s dry_run=%v", c.name, dryRun), func(t *testing.T) {
+				d, s := newTestAPIServer(t, &schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, dryRunHandler)
+
+				params := map[string]interface{}{"dry_run": dryRun}
+				for k, v := range c.params {
+					params[k] = v
+				}
+				data, _ := json.Marshal(params)
+				if got := c.call(d, data); got != code.Success {
+					t.Fatalf("response code = %s, want %s", got, code.Success)
+				}
+				sent := false
+				for _, r := range s.Requests() {
+					if r.Method != c.method {
+						continue
+					}
+					sent = true
+					if got, want := requestDryRun(r), dryRunOption(dryRun); fmt.Sprint(got) != fmt.Sprint(want) {
+						t.Errorf("dryRun = %v, want %v", got, want)
+					}
+				}
+				if !sent {
+					t.Fatalf("no %s request sent to api server", c.method)
+				}
+			})
+		}
+	}
+}
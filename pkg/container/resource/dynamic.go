@@ -1,22 +1,20 @@
 package resource
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
+	"github.com/kubespace/agent/pkg/policy"
 	"github.com/kubespace/agent/pkg/utils"
 	"github.com/kubespace/agent/pkg/utils/code"
 	"github.com/pkg/errors"
-	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	runtimeYaml "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 	"k8s.io/apimachinery/pkg/types"
-	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/restmapper"
@@ -36,6 +34,10 @@ type DynamicResource struct {
 	decUnstructured runtime.Serializer
 	restMapper      *restmapper.DeferredDiscoveryRESTMapper
 	context         context.Context
+	// redactSecrets 返回的secret不包含data
+	redactSecrets bool
+	// allowClusterWide 是否允许不限定namespace的操作，如清理集群级别的对象，agent策略限制了namespace时为false
+	allowClusterWide bool
 }
 
 func NewDynamicResource(kubeClient *kubernetes.KubeClient, gv *schema.GroupVersionResource) *DynamicResource {
@@ -62,9 +64,18 @@ type DynamicDeleteResourceParams struct {
 
 type DynamicDeleteParams struct {
 	Resources []DynamicDeleteResourceParams `json:"resources"`
+	DryRun    bool                          `json:"dry_run"`
 }
 
-func (d *DynamicResource) Delete(deleteParams interface{}) *utils.Response {
+// dryRunOption dry_run时apiserver完成校验、默认值填充及准入控制，但不持久化
+func dryRunOption(dryRun bool) []string {
+	if dryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+func (d *DynamicResource) Delete(ctx context.Context, deleteParams interface{}) *utils.Response {
 	params := &DynamicDeleteParams{}
 	json.Unmarshal(deleteParams.([]byte), params)
 	if len(params.Resources) > 0 {
@@ -72,11 +83,12 @@ func (d *DynamicResource) Delete(deleteParams interface{}) *utils.Response {
 		deletePolicy := metav1.DeletePropagationForeground
 		deleteOptions := metav1.DeleteOptions{
 			PropagationPolicy: &deletePolicy,
+			DryRun:            dryRunOption(params.DryRun),
 		}
 		for _, r := range params.Resources {
-			if err := d.DynamicClient.Resource(*d.GroupVersionResource).Namespace(r.Namespace).Delete(d.context, r.Name, deleteOptions); err != nil {
+			if err := d.Client(ctx).DynamicClient.Resource(*d.GroupVersionResource).Namespace(r.Namespace).Delete(ctx, r.Name, deleteOptions); err != nil {
 				klog.Errorf("delete group %v namespace %s name %s error: %v", d.GroupVersionResource, r.Namespace, r.Name, err)
-				return &utils.Response{Code: code.DeleteError, Msg: fmt.Sprintf("Delete %s error: %s", r.Name, err.Error())}
+				return &utils.Response{Code: code.DeleteError, Msg: fmt.Sprintf("Delete %s error: %s", r.Name, err.Error()), Error: utils.NewError(err)}
 			}
 		}
 		//}()
@@ -84,11 +96,41 @@ func (d *DynamicResource) Delete(deleteParams interface{}) *utils.Response {
 	return &utils.Response{Code: code.Success}
 }
 
+type DynamicPatchParams struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	DryRun    bool   `json:"dry_run"`
+	PatchParams
+}
+
+// Patch 对资源执行json、merge、strategic或apply patch，可以指定status、scale等子资源
+func (d *DynamicResource) Patch(ctx context.Context, patchParams interface{}) *utils.Response {
+	params := &DynamicPatchParams{}
+	json.Unmarshal(patchParams.([]byte), params)
+	if params.Name == "" {
+		return utils.NewParamsErrorResponse("Name is blank")
+	}
+	mapping, err := d.restMapping(*d.GroupVersionResource)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	var client dynamic.ResourceInterface = d.Client(ctx).DynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if params.Namespace == "" {
+			return utils.NewParamsErrorResponse("Namespace is blank")
+		}
+		client = d.Client(ctx).DynamicClient.Resource(mapping.Resource).Namespace(params.Namespace)
+	}
+	return patchObject(ctx, client, params.Name, &params.PatchParams, params.DryRun, d.redactSecrets)
+}
+
 type DynamicUpdateParams struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	YamlStr   string `json:"yaml"`
 	Kind      string `json:"kind"`
+	DryRun    bool   `json:"dry_run"`
 }
 
 type DynamicListParams struct {
@@ -96,7 +138,16 @@ type DynamicListParams struct {
 	Labels    map[string]string `json:"labels"`
 }
 
-func (d *DynamicResource) ListObjects(listParams interface{}) *utils.Response {
+func (d *DynamicResource) ListObjects(ctx context.Context, listParams interface{}) *utils.Response {
+	return d.listObjects(ctx, listParams, false)
+}
+
+// listObjects redactData为true时返回的对象不包含data，用于secret
+func (d *DynamicResource) listObjects(ctx context.Context, listParams interface{}, redactData bool) *utils.Response {
+	query, err := parseListQuery(listParams)
+	if err != nil {
+		return listQueryError(err)
+	}
 	params := &DynamicListParams{}
 	json.Unmarshal(listParams.([]byte), params)
 	var selector labels.Selector
@@ -105,172 +156,271 @@ func (d *DynamicResource) ListObjects(listParams interface{}) *utils.Response {
 	} else {
 		selector = labels.Everything()
 	}
-	objs, err := d.DynamicClient.Resource(*d.GroupVersionResource).Namespace(params.Namespace).List(d.context, metav1.ListOptions{LabelSelector: selector.String()})
+	objs, err := d.Client(ctx).DynamicClient.Resource(*d.GroupVersionResource).Namespace(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
 
 	if err != nil {
 		klog.Error("list objects error: ", err)
-		return &utils.Response{Code: code.UpdateError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.UpdateError, err)
+	}
+	for i := range objs.Items {
+		if redactData {
+			query.Add(&objs.Items[i], removeSecretData(&objs.Items[i]))
+		} else {
+			query.Add(&objs.Items[i], objs.Items[i])
+		}
 	}
-	return &utils.Response{Code: code.Success, Data: objs.Items}
+	return query.Response()
 }
 
-func (d *DynamicResource) UpdateYaml(updateParams interface{}) *utils.Response {
+func (d *DynamicResource) UpdateYaml(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &DynamicUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	mapObj := make(map[string]interface{})
 	if err := yaml.Unmarshal([]byte(params.YamlStr), &mapObj); err != nil {
 		klog.Error("Parse yaml error: ", err)
-		return &utils.Response{Code: code.ParamsError, Msg: fmt.Sprintf("Parse yaml error: %s", err.Error())}
+		return utils.NewParamsErrorResponse(fmt.Sprintf("Parse yaml error: %s", err.Error()))
 	}
 	obj := &unstructured.Unstructured{Object: mapObj}
+	var updated *unstructured.Unstructured
 	var updateErr error
+	updateOptions := metav1.UpdateOptions{DryRun: dryRunOption(params.DryRun)}
 	if params.Namespace != "" {
-		_, updateErr = d.DynamicClient.Resource(*d.GroupVersionResource).Namespace(params.Namespace).Update(d.context, obj, metav1.UpdateOptions{})
+		updated, updateErr = d.Client(ctx).DynamicClient.Resource(*d.GroupVersionResource).Namespace(params.Namespace).Update(ctx, obj, updateOptions)
 	} else {
-		_, updateErr = d.DynamicClient.Resource(*d.GroupVersionResource).Update(d.context, obj, metav1.UpdateOptions{})
+		updated, updateErr = d.Client(ctx).DynamicClient.Resource(*d.GroupVersionResource).Update(ctx, obj, updateOptions)
 	}
 	if updateErr != nil {
 		klog.Error("Update error: ", updateErr)
-		return &utils.Response{Code: code.UpdateError, Msg: updateErr.Error()}
+		return utils.NewErrorResponse(code.UpdateError, updateErr)
 	}
-	return &utils.Response{Code: code.Success}
+	return &utils.Response{Code: code.Success, Data: d.redact(updated)}
+}
+
+// redact 配置了redactSecrets时去掉secret的data
+func (d *DynamicResource) redact(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if !d.redactSecrets {
+		return obj
+	}
+	return redactSecret(obj)
 }
 
 type ApplyParams struct {
 	YamlStr string `json:"yaml"`
+	// DryRun 只由apiserver校验并返回将要持久化的对象
+	DryRun bool `json:"dry_run"`
+	// Prune apply后删除带有ApplySet标签但不在本次yaml中的对象，只用于apply
+	Prune    bool   `json:"prune"`
+	ApplySet string `json:"apply_set"`
+	// PruneAllowlist 可清理的类型，格式为 group/version/Kind，为空时使用DefaultPruneAllowlist
+	PruneAllowlist []string `json:"prune_allowlist"`
 }
 
-func (d *DynamicResource) UpdateGVRYaml(applyParams interface{}) *utils.Response {
-	params := &ApplyParams{}
-	if s, ok := applyParams.(string); ok {
-		json.Unmarshal([]byte(s), params)
-	} else {
-		json.Unmarshal(applyParams.([]byte), params)
-	}
+const (
+	ObjectCreated    = "created"
+	ObjectConfigured = "configured"
+	ObjectUnchanged  = "unchanged"
+	ObjectFailed     = "failed"
+)
 
-	multidocReader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader([]byte(params.YamlStr))))
+// ObjectResult 多文档yaml中每个对象的处理结果
+type ObjectResult struct {
+	// Index 对象在yaml中的文档序号，从0开始
+	Index     int    `json:"index"`
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Operation created、configured、unchanged或failed
+	Operation       string       `json:"operation"`
+	Msg             string       `json:"msg,omitempty"`
+	Error           *utils.Error `json:"error,omitempty"`
+	ResourceVersion string       `json:"resource_version,omitempty"`
+	UID             types.UID    `json:"uid,omitempty"`
+	// Object 只在dry_run时返回，为将要持久化的对象，配置了redactSecrets时secret不包含data
+	Object *unstructured.Unstructured `json:"object,omitempty"`
+}
+
+func (r *ObjectResult) fail(err error) {
+	r.Operation = ObjectFailed
+	r.Msg = err.Error()
+	r.Error = utils.NewError(err)
+}
+
+// documentFunc 对单个对象执行创建或更新，data为对象原始的yaml
+type documentFunc func(ctx context.Context, dr dynamic.ResourceInterface, obj *unstructured.Unstructured, data []byte) (*unstructured.Unstructured, error)
+
+// processDocuments 按类型依赖顺序处理多文档yaml中的对象，Msg保留每个对象一行的文本结果，Data为每个对象的结构化结果。
+// compareLive为true时先查询线上对象，据此区分created、configured及unchanged；非dry_run时创建CRD后等待其可用
+func (d *DynamicResource) processDocuments(ctx context.Context, yamlStr, verb string, compareLive, dryRun bool, fn documentFunc) *utils.Response {
+	docs, err := readDocuments(yamlStr)
+	if err != nil {
+		return utils.NewParamsErrorResponse("read yaml error: " + err.Error())
+	}
 	var res []string
-	applyErr := false
-	for {
-		buf, err := multidocReader.Read()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return &utils.Response{Code: code.ParamsError, Msg: "read yaml error: " + err.Error()}
+	var results []*ObjectResult
+	// applyErr 第一个失败对象的错误
+	var applyErr error
+	failed := func(result *ObjectResult, line string, err error) {
+		if applyErr == nil {
+			applyErr = err
 		}
-		obj, dr, err := d.buildDynamicResourceClient(buf)
+		result.fail(err)
+		res = append(res, line)
+	}
+	for _, doc := range docs {
+		result := &ObjectResult{Index: doc.index}
+		results = append(results, result)
+		obj, dr, err := d.buildDynamicResourceClient(ctx, doc.data)
+		gvk := obj.GroupVersionKind()
+		result.Group, result.Version, result.Kind = gvk.Group, gvk.Version, gvk.Kind
+		result.Namespace, result.Name = obj.GetNamespace(), obj.GetName()
 		if err != nil {
-			applyErr = true
-			res = append(res, err.Error())
+			failed(result, err.Error(), err)
 			continue
 		}
-		//data, err := json.Marshal(obj)
 
-		// Create or Update
-		_, err = dr.Update(d.context, obj, metav1.UpdateOptions{
-			FieldManager: "kubespace",
-		})
+		var live *unstructured.Unstructured
+		if compareLive {
+			live, err = dr.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				live, err = nil, nil
+			}
+			if err != nil {
+				failed(result, obj.GetKind()+"/"+obj.GetName()+" error : "+err.Error(), err)
+				continue
+			}
+		}
+		out, err := fn(ctx, dr, obj, doc.data)
 		if err != nil {
-			applyErr = true
-			res = append(res, obj.GetKind()+"/"+obj.GetName()+" error : "+err.Error())
-		} else {
-			res = append(res, obj.GetKind()+"/"+obj.GetName()+" applied successful.")
+			failed(result, obj.GetKind()+"/"+obj.GetName()+" error : "+err.Error(), err)
+			continue
+		}
+		if dryRun {
+			result.Object = d.redact(out)
+		}
+		result.ResourceVersion, result.UID = out.GetResourceVersion(), out.GetUID()
+		result.Operation = ObjectConfigured
+		if live == nil {
+			result.Operation = ObjectCreated
+		} else if objectUnchanged(live, out) {
+			result.Operation = ObjectUnchanged
 		}
+		if isCRD(obj) && !dryRun {
+			if err = waitForCRDEstablished(ctx, dr, obj.GetName()); err != nil {
+				failed(result, obj.GetKind()+"/"+obj.GetName()+" error : "+err.Error(), err)
+				continue
+			}
+			// 新的CRD需要重新发现，之后的CR才能映射到资源
+			d.restMapper.Reset()
+		}
+		res = append(res, obj.GetKind()+"/"+obj.GetName()+" "+verb+" successful.")
 	}
-	if applyErr {
+	if applyErr != nil {
 		klog.Error(res)
-		return &utils.Response{Code: code.ApplyError, Msg: strings.Join(res, "\n")}
+		return &utils.Response{Code: code.ApplyError, Msg: strings.Join(res, "\n"), Data: results, Error: utils.NewError(applyErr)}
+	}
+	return &utils.Response{Code: code.Success, Msg: strings.Join(res, "\n"), Data: results}
+}
+
+// objectUnchanged 忽略apiserver维护的字段后比较对象是否变化
+func objectUnchanged(live, out *unstructured.Unstructured) bool {
+	from, err := diffYaml(live)
+	if err != nil {
+		return false
+	}
+	to, err := diffYaml(out)
+	if err != nil {
+		return false
 	}
-	return &utils.Response{Code: code.Success, Msg: strings.Join(res, "\n")}
+	return from == to
 }
 
-func (d *DynamicResource) ApplyYaml(applyParams interface{}) *utils.Response {
+func (d *DynamicResource) UpdateGVRYaml(ctx context.Context, applyParams interface{}) *utils.Response {
 	params := &ApplyParams{}
 	if s, ok := applyParams.(string); ok {
 		json.Unmarshal([]byte(s), params)
 	} else {
 		json.Unmarshal(applyParams.([]byte), params)
 	}
+	return d.processDocuments(ctx, params.YamlStr, "applied", true, params.DryRun,
+		func(ctx context.Context, dr dynamic.ResourceInterface, obj *unstructured.Unstructured, data []byte) (*unstructured.Unstructured, error) {
+			return dr.Update(ctx, obj, metav1.UpdateOptions{
+				FieldManager: "kubespace",
+				DryRun:       dryRunOption(params.DryRun),
+			})
+		})
+}
 
-	multidocReader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader([]byte(params.YamlStr))))
-	var res []string
-	applyErr := false
-	for {
-		buf, err := multidocReader.Read()
-		if err != nil {
-			if err == io.EOF {
-				break
+func (d *DynamicResource) ApplyYaml(ctx context.Context, applyParams interface{}) *utils.Response {
+	params := &ApplyParams{}
+	if s, ok := applyParams.(string); ok {
+		json.Unmarshal([]byte(s), params)
+	} else {
+		json.Unmarshal(applyParams.([]byte), params)
+	}
+	if params.Prune {
+		if err := validateApplySet(params.ApplySet); err != nil {
+			return utils.NewParamsErrorResponse(err.Error())
+		}
+		for _, item := range params.PruneAllowlist {
+			if _, err := parseAllowlistGVK(item); err != nil {
+				return utils.NewParamsErrorResponse(err.Error())
 			}
-			return &utils.Response{Code: code.ParamsError, Msg: "read yaml error: " + err.Error()}
 		}
-		obj, dr, err := d.buildDynamicResourceClient(buf)
-		if err != nil {
-			applyErr = true
-			res = append(res, err.Error())
-			continue
+	}
+	var pruneMappings []*meta.RESTMapping
+	if params.Prune {
+		var err error
+		if pruneMappings, err = d.pruneMappings(params); err != nil {
+			return utils.NewErrorResponse(code.ApplyError, err)
 		}
-		//data, err := json.Marshal(obj)
-
-		// Create or Update
-		force := true
-		_, err = dr.Patch(d.context, obj.GetName(), types.ApplyPatchType, buf, metav1.PatchOptions{
-			FieldManager: "kubespace",
-			Force:        &force,
-		})
-		if err != nil {
-			applyErr = true
-			res = append(res, obj.GetKind()+"/"+obj.GetName()+" error : "+err.Error())
-		} else {
-			res = append(res, obj.GetKind()+"/"+obj.GetName()+" applied successful.")
+		for _, mapping := range pruneMappings {
+			if mapping.Scope.Name() != meta.RESTScopeNameNamespace && !d.allowClusterWide {
+				return utils.NewForbiddenResponse(fmt.Sprintf("prune cluster scoped %s is denied by agent policy", mapping.GroupVersionKind.Kind))
+			}
 		}
 	}
-	if applyErr {
-		klog.Error(res)
-		return &utils.Response{Code: code.ApplyError, Msg: strings.Join(res, "\n")}
+	resp := d.processDocuments(ctx, params.YamlStr, "applied", true, params.DryRun,
+		func(ctx context.Context, dr dynamic.ResourceInterface, obj *unstructured.Unstructured, data []byte) (*unstructured.Unstructured, error) {
+			if params.Prune {
+				objLabels := obj.GetLabels()
+				if objLabels == nil {
+					objLabels = make(map[string]string)
+				}
+				objLabels[ApplySetLabel] = params.ApplySet
+				obj.SetLabels(objLabels)
+				var err error
+				if data, err = obj.MarshalJSON(); err != nil {
+					return nil, err
+				}
+			}
+			force := true
+			return dr.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+				FieldManager: "kubespace",
+				Force:        &force,
+				DryRun:       dryRunOption(params.DryRun),
+			})
+		})
+	if params.Prune {
+		return d.applyWithPrune(ctx, params, pruneMappings, resp)
 	}
-	return &utils.Response{Code: code.Success, Msg: strings.Join(res, "\n")}
+	return resp
 }
 
-func (d *DynamicResource) CreateYaml(applyParams interface{}) *utils.Response {
+func (d *DynamicResource) CreateYaml(ctx context.Context, applyParams interface{}) *utils.Response {
 	params := &ApplyParams{}
 	json.Unmarshal(applyParams.([]byte), params)
-	multidocReader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader([]byte(params.YamlStr))))
-	var res []string
-	applyErr := false
-	for {
-		buf, err := multidocReader.Read()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return &utils.Response{Code: code.ParamsError, Msg: "read yaml error: " + err.Error()}
-		}
-		obj, dr, err := d.buildDynamicResourceClient(buf)
-		if err != nil {
-			applyErr = true
-			res = append(res, err.Error())
-			continue
-		}
-
-		// Create or Update
-		_, err = dr.Create(d.context, obj, metav1.CreateOptions{
-			FieldManager: "ospagent",
+	return d.processDocuments(ctx, params.YamlStr, "created", false, params.DryRun,
+		func(ctx context.Context, dr dynamic.ResourceInterface, obj *unstructured.Unstructured, data []byte) (*unstructured.Unstructured, error) {
+			return dr.Create(ctx, obj, metav1.CreateOptions{
+				FieldManager: "ospagent",
+				DryRun:       dryRunOption(params.DryRun),
+			})
 		})
-		if err != nil {
-			applyErr = true
-			res = append(res, obj.GetKind()+"/"+obj.GetName()+" error : "+err.Error())
-		} else {
-			res = append(res, obj.GetKind()+"/"+obj.GetName()+" created successful.")
-		}
-	}
-	if applyErr {
-		return &utils.Response{Code: code.ApplyError, Msg: strings.Join(res, "\n")}
-	}
-	return &utils.Response{Code: code.Success, Msg: strings.Join(res, "\n")}
 }
 
-func (d *DynamicResource) buildDynamicResourceClient(data []byte) (obj *unstructured.Unstructured, dr dynamic.ResourceInterface, err error) {
+func (d *DynamicResource) buildDynamicResourceClient(ctx context.Context, data []byte) (obj *unstructured.Unstructured, dr dynamic.ResourceInterface, err error) {
 	// Decode YAML manifest into unstructured.Unstructured
 	obj = &unstructured.Unstructured{}
 	_, gvk, err := d.decUnstructured.Decode(data, nil, obj)
@@ -279,7 +429,7 @@ func (d *DynamicResource) buildDynamicResourceClient(data []byte) (obj *unstruct
 	}
 
 	// Find GVR
-	mapping, err := d.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	mapping, err := d.kindMapping(*gvk)
 	if err != nil {
 		return obj, dr, errors.Wrap(err, "Mapping kind with version failed")
 	}
@@ -287,13 +437,58 @@ func (d *DynamicResource) buildDynamicResourceClient(data []byte) (obj *unstruct
 	// Obtain REST interface for the GVR
 	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
 		if obj.GetNamespace() == "" {
-			obj.SetNamespace("default")
+			obj.SetNamespace(policy.DefaultNamespace)
 		}
 		// namespaced resources should specify the namespace
-		dr = d.DynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+		dr = d.Client(ctx).DynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace())
 	} else {
 		// for cluster-wide resources
-		dr = d.DynamicClient.Resource(mapping.Resource)
+		dr = d.Client(ctx).DynamicClient.Resource(mapping.Resource)
 	}
 	return obj, dr, nil
 }
+
+// kindMapping 解析Kind的RESTMapping，缓存中可能没有新创建的CRD，找不到时重新发现后再试一次
+func (d *DynamicResource) kindMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := d.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		d.restMapper.Reset()
+		mapping, err = d.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
+
+// NewScopeFunc 返回按discovery判断Kind是否为namespace级别资源的函数，策略检查时用于确定yaml中对象实际所在的namespace
+func NewScopeFunc(kubeClient *kubernetes.KubeClient) policy.ScopeFunc {
+	d := NewDynamicResource(kubeClient, nil)
+	return func(gvk schema.GroupVersionKind) (bool, error) {
+		mapping, err := d.kindMapping(gvk)
+		if err != nil {
+			return false, err
+		}
+		return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+	}
+}
+
+// restMapping 解析资源的RESTMapping，resource可以是复数、单数形式或Kind，找不到时刷新discovery缓存后重试
+func (d *DynamicResource) restMapping(partial schema.GroupVersionResource) (*meta.RESTMapping, error) {
+	mapping, err := d.resourceMapping(partial)
+	if meta.IsNoMatchError(err) {
+		d.restMapper.Reset()
+		mapping, err = d.resourceMapping(partial)
+	}
+	return mapping, err
+}
+
+func (d *DynamicResource) resourceMapping(partial schema.GroupVersionResource) (*meta.RESTMapping, error) {
+	gvk, err := d.restMapper.KindFor(partial)
+	if err != nil {
+		// resource也可以是Kind
+		kindMapping, kindErr := d.restMapper.RESTMapping(schema.GroupKind{Group: partial.Group, Kind: partial.Resource}, versions(partial.Version)...)
+		if kindErr == nil {
+			return kindMapping, nil
+		}
+		return nil, err
+	}
+	return d.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
@@ -0,0 +1,72 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+)
+
+const testManifest = `apiVersion: v1
+kind: Secret
+metadata:
+  name: s
+  namespace: dev
+data:
+  password: c2VjcmV0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: c
+data:
+  key: value
+`
+
+func TestCreateYamlObjectResults(t *testing.T) {
+	cases := []struct {
+		name          string
+		dryRun        bool
+		redactSecrets bool
+		wantObject    bool
+		wantData      map[string]bool
+	}{
+		{name: "applied objects omitted", wantObject: false},
+		{name: "dry run objects", dryRun: true, wantObject: true, wantData: map[string]bool{"Secret": true, "ConfigMap": true}},
+		{name: "dry run redacted secret", dryRun: true, redactSecrets: true, wantObject: true, wantData: map[string]bool{"Secret": false, "ConfigMap": true}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, _ := newTestDynamicResource(nil)
+			d.redactSecrets = c.redactSecrets
+			params, _ := json.Marshal(&ApplyParams{YamlStr: testManifest, DryRun: c.dryRun})
+			resp := d.CreateYaml(context.Background(), params)
+			if resp.Code != code.Success {
+				t.Fatalf("CreateYaml() = %s %s", resp.Code, resp.Msg)
+			}
+			results := resp.Data.([]*ObjectResult)
+			if len(results) != 2 {
+				t.Fatalf("results = %d, want 2", len(results))
+			}
+			for _, result := range results {
+				if result.Operation != ObjectCreated {
+					t.Errorf("%s operation = %s, want %s", result.Kind, result.Operation, ObjectCreated)
+				}
+				if (result.Object != nil) != c.wantObject {
+					t.Fatalf("%s object = %v, want object %v", result.Kind, result.Object, c.wantObject)
+				}
+				if result.Object == nil {
+					continue
+				}
+				if _, ok := result.Object.Object["data"]; ok != c.wantData[result.Kind] {
+					t.Errorf("%s object has data = %v, want %v", result.Kind, ok, c.wantData[result.Kind])
+				}
+			}
+			// 未指定namespace的对象创建到default
+			if results[1].Namespace != "default" {
+				t.Errorf("configmap namespace = %s, want default", results[1].Namespace)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -82,17 +83,20 @@ type EndpointsUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (e *Endpoints) List(requestParams interface{}) *utils.Response {
+func (e *Endpoints) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return e.ListTable(ctx, requestParams)
+	}
 	queryParams := &EndpointsQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := e.KubeClient.InformerRegistry.EndpointsInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var endpoints []*BuildEndpoints
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -103,23 +107,23 @@ func (e *Endpoints) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && ds.Name != queryParams.Name {
 			continue
 		}
-		endpoints = append(endpoints, e.ToBuildEndpoints(ds))
+		query.Add(ds, e.ToBuildEndpoints(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: endpoints}
+	return query.Response()
 }
 
-func (e *Endpoints) Get(requestParams interface{}) *utils.Response {
+func (e *Endpoints) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &EndpointsQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Endpoints name is blank"}
+		return utils.NewParamsErrorResponse("Endpoints name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	endpoints, err := e.KubeClient.InformerRegistry.EndpointsInformer().Lister().Endpoints(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -136,24 +140,24 @@ func (e *Endpoints) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, endpoints)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: endpoints}
 }
 
-func (e *Endpoints) UpdateObj(updateParams interface{}) *utils.Response {
+func (e *Endpoints) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &EndpointsUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Endpoints name is blank"}
+		return utils.NewParamsErrorResponse("Endpoints name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -164,12 +168,12 @@ func (e *Endpoints) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := e.ClientSet.CoreV1().Endpoints(params.Namespace).Update(e.context, result, metav1.UpdateOptions{})
+		_, updateErr := e.Client(ctx).ClientSet.CoreV1().Endpoints(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
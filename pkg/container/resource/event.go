@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -91,17 +92,20 @@ type EventQueryParams struct {
 	Output    string `json:"output"`
 }
 
-func (e *Event) List(requestParams interface{}) *utils.Response {
+func (e *Event) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return e.ListTable(ctx, requestParams)
+	}
 	queryParams := &EventQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	eventList, err := e.KubeClient.InformerRegistry.EventInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var events []*BuildEvent
 	for _, event := range eventList {
 		if queryParams.UID != "" && string(event.InvolvedObject.UID) != queryParams.UID {
 			continue
@@ -115,23 +119,23 @@ func (e *Event) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && event.InvolvedObject.Name != queryParams.Name {
 			continue
 		}
-		events = append(events, e.ToBuildEvent(event))
+		query.Add(event, e.ToBuildEvent(event))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: events}
+	return query.Response()
 }
 
-func (e *Event) Get(requestParams interface{}) *utils.Response {
+func (e *Event) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &EventQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Event name is blank"}
+		return utils.NewParamsErrorResponse("Event name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	event, err := e.KubeClient.InformerRegistry.EventInformer().Lister().Events(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -148,7 +152,7 @@ func (e *Event) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, event)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
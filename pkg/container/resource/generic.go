@@ -0,0 +1,433 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kubespace/agent/pkg/kubernetes"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	"github.com/kubespace/agent/pkg/websocket"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+	"sync"
+)
+
+// GenericResource 通过dynamic client访问集群提供的任意资源，不需要为每种资源单独实现handler
+type GenericResource struct {
+	*DynamicResource
+	websocket.SendResponse
+	// redactSecrets 查询及watch secret时不返回data
+	redactSecrets bool
+	watchSessions map[string]context.CancelFunc
+	watchMutex    sync.Mutex
+}
+
+func NewGenericResource(kubeClient *kubernetes.KubeClient, sendResponse websocket.SendResponse, redactSecrets bool) *GenericResource {
+	return &GenericResource{
+		DynamicResource: NewDynamicResource(kubeClient, nil),
+		SendResponse:    sendResponse,
+		redactSecrets:   redactSecrets,
+		watchSessions:   make(map[string]context.CancelFunc),
+	}
+}
+
+type GenericParams struct {
+	// Group、Version、Resource 要访问的资源，Resource为复数形式（如replicasets）或Kind，Version为空时使用首选版本
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// LabelSelector、FieldSelector 与kubectl的 -l、--field-selector 格式相同
+	LabelSelector string `json:"label_selector"`
+	FieldSelector string `json:"field_selector"`
+	Output        string `json:"output"`
+	// YamlStr create、update时的对象，yaml或json格式
+	YamlStr string `json:"yaml"`
+	DryRun  bool   `json:"dry_run"`
+	// Action、SessionId watch时open或close，事件以session_id推送
+	Action    string `json:"action"`
+	SessionId string `json:"session_id"`
+	PatchParams
+}
+
+var secretGR = schema.GroupResource{Resource: "secrets"}
+
+// mapping 解析请求的资源，得到资源的GVR及是否为namespace级别
+func (g *GenericResource) mapping(params *GenericParams) (*meta.RESTMapping, error) {
+	if params.Resource == "" {
+		return nil, fmt.Errorf("resource is blank")
+	}
+	return g.restMapping(schema.GroupVersionResource{Group: params.Group, Version: params.Version, Resource: params.Resource})
+}
+
+func versions(version string) []string {
+	if version == "" {
+		return nil
+	}
+	return []string{version}
+}
+
+// resourceClient 返回请求资源的client，namespace级别的资源在namespace为空时访问所有namespace
+func (g *GenericResource) resourceClient(ctx context.Context, params *GenericParams) (*meta.RESTMapping, dynamic.ResourceInterface, error) {
+	mapping, err := g.mapping(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	nri := g.Client(ctx).DynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && params.Namespace != "" {
+		return mapping, nri.Namespace(params.Namespace), nil
+	}
+	return mapping, nri, nil
+}
+
+func (g *GenericResource) parseParams(requestParams interface{}) (*GenericParams, error) {
+	params := &GenericParams{}
+	if err := json.Unmarshal(requestParams.([]byte), params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func (g *GenericResource) redact(mapping *meta.RESTMapping, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if !g.redactSecrets || mapping.Resource.GroupResource() != secretGR {
+		return obj
+	}
+	return removeSecretData(obj)
+}
+
+func (g *GenericResource) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	params, err := g.parseParams(requestParams)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	mapping, client, err := g.resourceClient(ctx, params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	if params.Output == OutputTable {
+		return g.listTable(ctx, requestParams, &mapping.Resource)
+	}
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	// field selector优先由api server处理，与watch一致
+	listOptions := metav1.ListOptions{LabelSelector: params.LabelSelector, FieldSelector: query.FieldSelector}
+	list, err := client.List(ctx, listOptions)
+	if err == nil {
+		query.serverFieldSelector()
+	} else if listOptions.FieldSelector != "" && apierrors.IsBadRequest(err) {
+		// api server只支持每种资源的少数字段，不支持时列出全部对象后在agent中过滤
+		klog.V(1).Infof("list %s with field selector %q error: %v, filter in agent", mapping.Resource.String(), listOptions.FieldSelector, err)
+		listOptions.FieldSelector = ""
+		list, err = client.List(ctx, listOptions)
+	}
+	if err != nil {
+		return utils.NewErrorResponse(code.ListError, err)
+	}
+	for i := range list.Items {
+		query.Add(&list.Items[i], g.redact(mapping, &list.Items[i]))
+	}
+	return query.Response()
+}
+
+func (g *GenericResource) Get(ctx context.Context, requestParams interface{}) *utils.Response {
+	params, err := g.parseParams(requestParams)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	if params.Name == "" {
+		return utils.NewParamsErrorResponse("Name is blank")
+	}
+	mapping, client, err := g.resourceClient(ctx, params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	obj, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+	if err != nil {
+		return utils.NewErrorResponse(code.GetError, err)
+	}
+	obj = g.redact(mapping, obj)
+	if params.Output == "yaml" {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return utils.NewErrorResponse(code.EncodeError, err)
+		}
+		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(data)}
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: obj}
+}
+
+// decodeObject 解析create、update的对象，对象中的namespace优先于参数
+func (g *GenericResource) decodeObject(params *GenericParams) (*unstructured.Unstructured, error) {
+	if params.YamlStr == "" {
+		return nil, fmt.Errorf("yaml is blank")
+	}
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(params.YamlStr), &obj.Object); err != nil {
+		return nil, fmt.Errorf("parse yaml error: %s", err.Error())
+	}
+	if obj.GetNamespace() != "" {
+		params.Namespace = obj.GetNamespace()
+	}
+	if params.Name == "" {
+		params.Name = obj.GetName()
+	}
+	return obj, nil
+}
+
+func (g *GenericResource) Create(ctx context.Context, requestParams interface{}) *utils.Response {
+	params, err := g.parseParams(requestParams)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	obj, err := g.decodeObject(params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	mapping, client, err := g.resourceClient(ctx, params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	created, err := client.Create(ctx, obj, metav1.CreateOptions{FieldManager: "kubespace", DryRun: dryRunOption(params.DryRun)})
+	if err != nil {
+		return utils.NewErrorResponse(code.ApplyError, err)
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: g.redact(mapping, created)}
+}
+
+func (g *GenericResource) Update(ctx context.Context, requestParams interface{}) *utils.Response {
+	params, err := g.parseParams(requestParams)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	obj, err := g.decodeObject(params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	mapping, client, err := g.resourceClient(ctx, params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	updated, err := client.Update(ctx, obj, metav1.UpdateOptions{FieldManager: "kubespace", DryRun: dryRunOption(params.DryRun)})
+	if err != nil {
+		return utils.NewErrorResponse(code.UpdateError, err)
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: g.redact(mapping, updated)}
+}
+
+func (g *GenericResource) Patch(ctx context.Context, requestParams interface{}) *utils.Response {
+	params, err := g.parseParams(requestParams)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	if params.Name == "" {
+		return utils.NewParamsErrorResponse("Name is blank")
+	}
+	_, client, err := g.resourceClient(ctx, params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	return patchObject(ctx, client, params.Name, &params.PatchParams, params.DryRun, g.redactSecrets)
+}
+
+func (g *GenericResource) Delete(ctx context.Context, requestParams interface{}) *utils.Response {
+	params, err := g.parseParams(requestParams)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	if params.Name == "" {
+		return utils.NewParamsErrorResponse("Name is blank")
+	}
+	_, client, err := g.resourceClient(ctx, params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	deletePolicy := metav1.DeletePropagationForeground
+	err = client.Delete(ctx, params.Name, metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+		DryRun:            dryRunOption(params.DryRun),
+	})
+	if err != nil {
+		return utils.NewErrorResponse(code.DeleteError, err)
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success"}
+}
+
+// Watch 打开或关闭资源的watch，事件以session_id推送，直到关闭或出错
+func (g *GenericResource) Watch(ctx context.Context, requestParams interface{}) *utils.Response {
+	params, err := g.parseParams(requestParams)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	if params.SessionId == "" {
+		return utils.NewParamsErrorResponse("Not found session id")
+	}
+	if params.Action == "close" {
+		g.closeWatch(params.SessionId)
+		return &utils.Response{Code: code.Success, Msg: "Success"}
+	}
+	if params.Action != "open" {
+		return utils.NewParamsErrorResponse("Action param is not valid")
+	}
+	mapping, client, err := g.resourceClient(ctx, params)
+	if err != nil {
+		return utils.NewParamsErrorResponse(err.Error())
+	}
+	listOptions := metav1.ListOptions{LabelSelector: params.LabelSelector, FieldSelector: params.FieldSelector}
+	// 先list得到resourceVersion，之后由RetryWatcher在连接断开时从该版本继续watch
+	list, err := client.List(ctx, listOptions)
+	if err != nil {
+		return utils.NewErrorResponse(code.ListError, err)
+	}
+	watcher, err := watchtools.NewRetryWatcher(list.GetResourceVersion(), &cache.ListWatch{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = listOptions.LabelSelector
+			options.FieldSelector = listOptions.FieldSelector
+			return client.Watch(g.context, options)
+		},
+	})
+	if err != nil {
+		return utils.NewErrorResponse(code.GetError, err)
+	}
+	watchCtx, cancel := context.WithCancel(g.context)
+	g.watchMutex.Lock()
+	if oldCancel, ok := g.watchSessions[params.SessionId]; ok {
+		oldCancel()
+	}
+	g.watchSessions[params.SessionId] = cancel
+	g.watchMutex.Unlock()
+	go g.watchProcess(watchCtx, params.SessionId, mapping, watcher)
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: map[string]interface{}{"resource_version": list.GetResourceVersion()}}
+}
+
+func (g *GenericResource) closeWatch(sessionId string) {
+	g.watchMutex.Lock()
+	defer g.watchMutex.Unlock()
+	if cancel, ok := g.watchSessions[sessionId]; ok {
+		klog.Info("close watch session ", sessionId)
+		cancel()
+		delete(g.watchSessions, sessionId)
+	}
+}
+
+var watchEvents = map[watch.EventType]string{
+	watch.Added:    utils.AddEvent,
+	watch.Modified: utils.UpdateEvent,
+	watch.Deleted:  utils.DeleteEvent,
+}
+
+func (g *GenericResource) watchProcess(ctx context.Context, sessionId string, mapping *meta.RESTMapping, watcher *watchtools.RetryWatcher) {
+	defer watcher.Stop()
+	defer g.closeWatch(sessionId)
+	resource := mapping.Resource.String()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-watcher.Done():
+			return
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			if event.Type == watch.Error {
+				resp := watchErrorResponse(resource, event.Object)
+				klog.Errorf("watch session %s: %s", sessionId, resp.Msg)
+				g.SendResponse(resp, sessionId, utils.WatchType)
+				return
+			}
+			eventType, ok := watchEvents[event.Type]
+			if !ok {
+				continue
+			}
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			resp := &utils.WatchResponse{Event: eventType, Obj: resource, Resource: g.redact(mapping, obj)}
+			g.SendResponse(resp, sessionId, utils.WatchType)
+		}
+	}
+}
+
+// watchErrorResponse watch错误事件的对象一般为Status，转换为带有结构化错误信息的响应
+func watchErrorResponse(resource string, obj runtime.Object) *utils.Response {
+	err := fmt.Errorf("watch %s error: %v", resource, obj)
+	if _, ok := obj.(*metav1.Status); ok {
+		err = fmt.Errorf("watch %s error: %w", resource, apierrors.FromObject(obj))
+	}
+	return utils.NewErrorResponse(code.WatchError, err)
+}
+
+type PatchParams struct {
+	// PatchType json、merge、strategic或apply
+	PatchType string `json:"patch_type"`
+	Patch     string `json:"patch"`
+	// Subresource 如status、scale，为空时修改对象本身
+	Subresource  string `json:"subresource"`
+	FieldManager string `json:"field_manager"`
+	// Force apply冲突时是否强制获取字段的所有权
+	Force bool `json:"force"`
+}
+
+var patchTypes = map[string]types.PatchType{
+	"json":      types.JSONPatchType,
+	"merge":     types.MergePatchType,
+	"strategic": types.StrategicMergePatchType,
+	"apply":     types.ApplyPatchType,
+}
+
+// patchObject 对对象执行patch，不需要先读取完整对象，避免read-modify-write冲突。
+// redactSecrets为true时返回的secret不包含data，避免通过空patch读取secret
+func patchObject(ctx context.Context, client dynamic.ResourceInterface, name string, params *PatchParams, dryRun, redactSecrets bool) *utils.Response {
+	patchType, ok := patchTypes[params.PatchType]
+	if !ok {
+		return utils.NewParamsErrorResponse(fmt.Sprintf("patch type %q is not valid, should be json, merge, strategic or apply", params.PatchType))
+	}
+	if params.Patch == "" {
+		return utils.NewParamsErrorResponse("Patch is blank")
+	}
+	data := []byte(params.Patch)
+	if patchType == types.ApplyPatchType || patchType == types.MergePatchType || patchType == types.StrategicMergePatchType {
+		// 这几种patch允许使用yaml
+		jsonData, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return utils.NewParamsErrorResponse("parse patch error: " + err.Error())
+		}
+		data = jsonData
+	}
+	fieldManager := params.FieldManager
+	if fieldManager == "" {
+		fieldManager = "kubespace"
+	}
+	options := metav1.PatchOptions{FieldManager: fieldManager, DryRun: dryRunOption(dryRun)}
+	if patchType == types.ApplyPatchType {
+		options.Force = &params.Force
+	}
+	var subresources []string
+	if params.Subresource != "" {
+		subresources = append(subresources, params.Subresource)
+	}
+	patched, err := client.Patch(ctx, name, patchType, data, options, subresources...)
+	if err != nil {
+		return utils.NewErrorResponse(code.UpdateError, err)
+	}
+	if redactSecrets {
+		patched = redactSecret(patched)
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: patched}
+}
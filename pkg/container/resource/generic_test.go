@@ -0,0 +1,246 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestPatchObjectTypes(t *testing.T) {
+	cases := []struct {
+		name     string
+		params   PatchParams
+		dryRun   bool
+		wantCode string
+		wantType types.PatchType
+		wantData string
+	}{
+		{name: "json", params: PatchParams{PatchType: "json", Patch: `[{"op":"add","path":"/data/a","value":"1"}]`}, wantCode: code.Success, wantType: types.JSONPatchType, wantData: `[{"op":"add","path":"/data/a","value":"1"}]`},
+		{name: "merge yaml", params: PatchParams{PatchType: "merge", Patch: "data:\n  a: \"1\"\n"}, wantCode: code.Success, wantType: types.MergePatchType, wantData: `{"data":{"a":"1"}}`},
+		{name: "strategic", params: PatchParams{PatchType: "strategic", Patch: `{"data":{"a":"1"}}`}, wantCode: code.Success, wantType: types.StrategicMergePatchType, wantData: `{"data":{"a":"1"}}`},
+		{name: "apply dry run", params: PatchParams{PatchType: "apply", Patch: `{"data":{"a":"1"}}`, Force: true}, dryRun: true, wantCode: code.Success, wantType: types.ApplyPatchType, wantData: `{"data":{"a":"1"}}`},
+		{name: "status subresource", params: PatchParams{PatchType: "merge", Patch: `{"status":{}}`, Subresource: "status"}, wantCode: code.Success, wantType: types.MergePatchType, wantData: `{"status":{}}`},
+		{name: "invalid type", params: PatchParams{PatchType: "replace", Patch: `{}`}, wantCode: code.ParamsError},
+		{name: "blank patch", params: PatchParams{PatchType: "merge"}, wantCode: code.ParamsError},
+		{name: "invalid yaml", params: PatchParams{PatchType: "merge", Patch: "a: [b"}, wantCode: code.ParamsError},
+	}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, client := newTestDynamicResource(nil)
+			var action clienttesting.PatchAction
+			client.PrependReactor("patch", "configmaps", func(a clienttesting.Action) (bool, runtime.Object, error) {
+				action = a.(clienttesting.PatchAction)
+				return true, newTestObject("v1", "ConfigMap", "default", "a", testSecretFields()), nil
+			})
+			resp := patchObject(context.Background(), client.Resource(gvr).Namespace("default"), "a", &c.params, c.dryRun, false)
+			if resp.Code != c.wantCode {
+				t.Fatalf("patchObject() code = %s, want %s, msg %s", resp.Code, c.wantCode, resp.Msg)
+			}
+			if c.wantCode != code.Success {
+				if action != nil {
+					t.Errorf("patchObject() sent patch on params error")
+				}
+				return
+			}
+			if action.GetPatchType() != c.wantType || string(action.GetPatch()) != c.wantData {
+				t.Errorf("patch = %s %s, want %s %s", action.GetPatchType(), action.GetPatch(), c.wantType, c.wantData)
+			}
+			if action.GetSubresource() != c.params.Subresource {
+				t.Errorf("subresource = %q, want %q", action.GetSubresource(), c.params.Subresource)
+			}
+		})
+	}
+}
+
+func TestPatchObjectRedactSecret(t *testing.T) {
+	secretGVR := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	cases := []struct {
+		name          string
+		redactSecrets bool
+		wantData      bool
+	}{
+		{name: "redacted", redactSecrets: true},
+		{name: "not redacted", wantData: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			secret := newTestObject("v1", "Secret", "default", "a", testSecretFields())
+			_, client := newTestDynamicResource(nil, secret)
+			// 空的merge patch不修改对象，只返回对象
+			resp := patchObject(context.Background(), client.Resource(secretGVR).Namespace("default"), "a",
+				&PatchParams{PatchType: "merge", Patch: "{}"}, false, c.redactSecrets)
+			if resp.Code != code.Success {
+				t.Fatalf("patchObject() = %s %s", resp.Code, resp.Msg)
+			}
+			patched := resp.Data.(*unstructured.Unstructured)
+			if _, ok := patched.Object["data"]; ok != c.wantData {
+				t.Errorf("patched secret has data = %v, want %v", ok, c.wantData)
+			}
+		})
+	}
+}
+
+func TestDynamicResourcePatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		gvr      schema.GroupVersionResource
+		params   string
+		wantCode string
+	}{
+		{name: "namespaced", gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, params: `{"name":"a","namespace":"default","patch_type":"merge","patch":"{}"}`, wantCode: code.Success},
+		{name: "namespace required", gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, params: `{"name":"a","patch_type":"merge","patch":"{}"}`, wantCode: code.ParamsError},
+		{name: "name required", gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, params: `{"namespace":"default","patch_type":"merge","patch":"{}"}`, wantCode: code.ParamsError},
+		{name: "cluster scoped", gvr: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, params: `{"name":"a","patch_type":"merge","patch":"{}"}`, wantCode: code.Success},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gvr := c.gvr
+			d, _ := newTestDynamicResource(&gvr,
+				newTestObject("v1", "ConfigMap", "default", "a", testSecretFields()),
+				newTestObject("v1", "Namespace", "", "a", nil))
+			resp := d.Patch(context.Background(), []byte(c.params))
+			if resp.Code != c.wantCode {
+				t.Errorf("Patch() code = %s, want %s, msg %s", resp.Code, c.wantCode, resp.Msg)
+			}
+		})
+	}
+}
+
+func TestGenericResourceRedactSecrets(t *testing.T) {
+	secretYaml := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"b","namespace":"default"},"data":{"password":"c2VjcmV0"}}`
+	cases := []struct {
+		name   string
+		handle func(g *GenericResource, params []byte) interface{}
+		params string
+		// items 返回的是列表
+		items bool
+	}{
+		{name: "get", handle: func(g *GenericResource, p []byte) interface{} { return g.Get(context.Background(), p) }, params: `{"resource":"secrets","namespace":"default","name":"a"}`},
+		{name: "get by kind", handle: func(g *GenericResource, p []byte) interface{} { return g.Get(context.Background(), p) }, params: `{"resource":"Secret","namespace":"default","name":"a"}`},
+		{name: "list", handle: func(g *GenericResource, p []byte) interface{} { return g.List(context.Background(), p) }, params: `{"resource":"secrets","namespace":"default"}`, items: true},
+		{name: "create", handle: func(g *GenericResource, p []byte) interface{} { return g.Create(context.Background(), p) }, params: `{"resource":"secrets","yaml":` + quote(secretYaml) + `}`},
+		{name: "update", handle: func(g *GenericResource, p []byte) interface{} { return g.Update(context.Background(), p) }, params: `{"resource":"secrets","yaml":` + quote(strings.Replace(secretYaml, `"name":"b"`, `"name":"a"`, 1)) + `}`},
+		{name: "patch", handle: func(g *GenericResource, p []byte) interface{} { return g.Patch(context.Background(), p) }, params: `{"resource":"secrets","namespace":"default","name":"a","patch_type":"merge","patch":"{}"}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, _ := newTestDynamicResource(nil, newTestObject("v1", "Secret", "default", "a", testSecretFields()))
+			g := &GenericResource{DynamicResource: d, redactSecrets: true}
+			data, err := json.Marshal(c.handle(g, []byte(c.params)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := map[string]interface{}{}
+			json.Unmarshal(data, &resp)
+			if resp["code"] != code.Success {
+				t.Fatalf("response = %s", data)
+			}
+			if strings.Contains(string(data), "c2VjcmV0") || strings.Contains(string(data), "bmV3") || strings.Contains(string(data), "password") {
+				t.Errorf("response reveals secret data: %s", data)
+			}
+			if c.items && !strings.Contains(string(data), `"name":"a"`) {
+				t.Errorf("list response missing secret: %s", data)
+			}
+		})
+	}
+}
+
+func TestWatchErrorResponse(t *testing.T) {
+	cases := []struct {
+		name       string
+		obj        runtime.Object
+		wantMsg    string
+		wantReason string
+		wantStatus int32
+	}{
+		{
+			name:       "status",
+			obj:        &metav1.Status{Status: metav1.StatusFailure, Message: "too old resource version", Reason: metav1.StatusReasonGone, Code: 410},
+			wantMsg:    "watch pods error: too old resource version",
+			wantReason: string(metav1.StatusReasonGone),
+			wantStatus: 410,
+		},
+		{
+			name:    "unknown object",
+			obj:     &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Pod"}},
+			wantMsg: "watch pods error: &{map[kind:Pod]}",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := watchErrorResponse("pods", c.obj)
+			if resp.Code != code.WatchError || resp.Msg != c.wantMsg {
+				t.Errorf("response = %s %q, want %s %q", resp.Code, resp.Msg, code.WatchError, c.wantMsg)
+			}
+			if resp.Error == nil || resp.Error.Reason != c.wantReason || resp.Error.Status != c.wantStatus {
+				t.Errorf("error = %+v, want reason %q status %d", resp.Error, c.wantReason, c.wantStatus)
+			}
+		})
+	}
+}
+
+func TestGenericResourceListFieldSelector(t *testing.T) {
+	cases := []struct {
+		name string
+		// supported api server是否支持该字段，支持时由fake返回过滤后的结果
+		supported bool
+		want      []string
+		// wantSelectors 每次list发送给api server的field selector
+		wantSelectors []string
+	}{
+		{name: "filtered by api server", supported: true, want: []string{"prod/cache"}, wantSelectors: []string{"status.phase=Running"}},
+		{name: "fallback to agent filter", want: []string{"dev/web", "prod/cache"}, wantSelectors: []string{"status.phase=Running", ""}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var objects []runtime.Object
+			for _, pod := range testPods {
+				objects = append(objects, pod.DeepCopy())
+			}
+			d, client := newTestDynamicResource(nil, objects...)
+			var selectors []string
+			client.PrependReactor("list", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				selector := action.(clienttesting.ListAction).GetListRestrictions().Fields.String()
+				selectors = append(selectors, selector)
+				if selector == "" {
+					return false, nil, nil
+				}
+				if !c.supported {
+					return true, nil, apierrors.NewBadRequest("field label not supported: status.phase")
+				}
+				// 只返回一个对象，用于区分api server与agent的过滤结果
+				list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}
+				list.Items = append(list.Items, *testPods[3].DeepCopy())
+				return true, list, nil
+			})
+			g := &GenericResource{DynamicResource: d}
+			resp := g.List(context.Background(), []byte(`{"resource":"pods","field_selector":"status.phase=Running"}`))
+			if resp.Code != code.Success {
+				t.Fatalf("List() = %s %s", resp.Code, resp.Msg)
+			}
+			var names []string
+			for _, item := range resp.Data.([]interface{}) {
+				obj := item.(*unstructured.Unstructured)
+				names = append(names, obj.GetNamespace()+"/"+obj.GetName())
+			}
+			if fmt.Sprint(names) != fmt.Sprint(c.want) {
+				t.Errorf("listed objects = %v, want %v", names, c.want)
+			}
+			if fmt.Sprint(selectors) != fmt.Sprint(c.wantSelectors) {
+				t.Errorf("field selectors sent = %q, want %q", selectors, c.wantSelectors)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package resource
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/kubespace/agent/pkg/kubernetes"
 	"github.com/kubespace/agent/pkg/ospserver"
@@ -43,7 +44,7 @@ type Helm struct {
 }
 
 func newConfigFlags(kubeClient *kubernetes.KubeClient, namespace string) *genericclioptions.ConfigFlags {
-	var impersonateGroup []string
+	impersonateGroup := append([]string{}, kubeClient.Groups...)
 	insecure := true
 
 	// CertFile and KeyFile must be nil for the BearerToken to be used for authentication and authorization instead of the pod's service account.
@@ -54,6 +55,7 @@ func newConfigFlags(kubeClient *kubernetes.KubeClient, namespace string) *generi
 		APIServer:  utils.StringPtr(kubeClient.Config.Host),
 		//CAFile:           utils.StringPtr(kubeClient.Config.CAFile),
 		BearerToken:      utils.StringPtr(kubeClient.Config.BearerToken),
+		Impersonate:      utils.StringPtr(kubeClient.User),
 		ImpersonateGroup: &impersonateGroup,
 	}
 }
@@ -88,24 +90,38 @@ func (h *Helm) buildRelease(release *release.Release) map[string]interface{} {
 	}
 }
 
-func (h *Helm) List(requestParams interface{}) *utils.Response {
+// releaseObject 将release的名称、标签及首次部署时间转换为对象元数据，用于List的过滤及排序
+func releaseObject(r *release.Release) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{Name: r.Name, Namespace: r.Namespace, Labels: r.Labels},
+	}
+	if r.Info != nil {
+		obj.CreationTimestamp = metav1.NewTime(r.Info.FirstDeployed.Time)
+	}
+	return obj
+}
+
+func (h *Helm) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
 	actionConfig := new(action.Configuration)
-	clientGetter := newConfigFlags(h.KubeClient, "")
+	clientGetter := newConfigFlags(h.Client(ctx), "")
 	if err := actionConfig.Init(clientGetter, "", "", klog.Infof); err != nil {
 		klog.Errorf("%+v", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	client := action.NewList(actionConfig)
 	results, err := client.Run()
 	if err != nil {
 		klog.Errorf("list helm error: %s", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var res []map[string]interface{}
 	for _, r := range results {
-		res = append(res, h.buildRelease(r))
+		query.Add(releaseObject(r), h.buildRelease(r))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: res}
+	return query.Response()
 }
 
 type HelmGetParams struct {
@@ -117,32 +133,51 @@ type HelmGetParams struct {
 	Values        string `json:"values"`
 	GetOption     string `json:"get_option"`
 	WithWorkloads bool   `json:"with_workloads"`
+	// DryRun 只渲染chart并返回将要安装的manifest，不修改集群
+	DryRun bool `json:"dry_run"`
+}
+
+// buildDryRunRelease dry_run时返回渲染后的release
+func (h *Helm) buildDryRunRelease(rel *release.Release) map[string]interface{} {
+	if rel == nil {
+		return nil
+	}
+	data := map[string]interface{}{
+		"name":      rel.Name,
+		"namespace": rel.Namespace,
+		"version":   rel.Version,
+		"manifest":  rel.Manifest,
+	}
+	if rel.Info != nil {
+		data["notes"] = rel.Info.Notes
+	}
+	return data
 }
 
-func (h *Helm) Get(requestParams interface{}) *utils.Response {
+func (h *Helm) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &HelmGetParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	actionConfig := new(action.Configuration)
 
-	clientGetter := newConfigFlags(h.KubeClient, queryParams.Namespace)
+	clientGetter := newConfigFlags(h.Client(ctx), queryParams.Namespace)
 	if err := actionConfig.Init(clientGetter, queryParams.Namespace, "", klog.Infof); err != nil {
 		klog.Errorf("%+v", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	client := action.NewGet(actionConfig)
 	releaseDetail, err := client.Run(queryParams.Name)
 	if err != nil {
 		klog.Errorf("get releaseDetail error: %s", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	var data map[string]interface{}
-	status := h.GetReleaseRuntimeStatus(releaseDetail, queryParams.WithWorkloads)
+	status := h.GetReleaseRuntimeStatus(ctx, releaseDetail, queryParams.WithWorkloads)
 	data = map[string]interface{}{
 		"objects":       status.Workloads,
 		"name":          releaseDetail.Name,
@@ -158,28 +193,28 @@ func (h *Helm) Get(requestParams interface{}) *utils.Response {
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: data}
 }
 
-func (h *Helm) Create(requestParams interface{}) *utils.Response {
+func (h *Helm) Create(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &HelmGetParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if queryParams.ChartPath == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Chart path is blank"}
+		return utils.NewParamsErrorResponse("Chart path is blank")
 	}
 	chart, err := h.OspServer.GetAppChart(queryParams.ChartPath)
 	if err != nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "get chart error, " + err.Error()}
+		return utils.NewParamsErrorResponse("get chart error, " + err.Error())
 	}
 	actionConfig := new(action.Configuration)
 
-	clientGetter := newConfigFlags(h.KubeClient, queryParams.Namespace)
+	clientGetter := newConfigFlags(h.Client(ctx), queryParams.Namespace)
 	if err = actionConfig.Init(clientGetter, queryParams.Namespace, "", klog.Infof); err != nil {
 		klog.Errorf("init helm config error: %+v", err)
-		return &utils.Response{Code: code.ApplyError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ApplyError, err)
 	}
 	actionConfig.Releases.MaxHistory = 3
 	clientInstall := action.NewInstall(actionConfig)
@@ -189,12 +224,16 @@ func (h *Helm) Create(requestParams interface{}) *utils.Response {
 	}
 	clientInstall.ReleaseName = releaseName
 	clientInstall.Namespace = queryParams.Namespace
+	clientInstall.DryRun = queryParams.DryRun
 	values := make(map[string]interface{})
 	err = yaml.Unmarshal([]byte(queryParams.Values), &values)
 	if err != nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "values参数解析错误：" + err.Error()}
+		return utils.NewParamsErrorResponse("values参数解析错误：" + err.Error())
+	}
+	rel, err := clientInstall.Run(chart, values)
+	if err != nil && queryParams.DryRun {
+		return utils.NewErrorResponse(code.ApplyError, err)
 	}
-	_, err = clientInstall.Run(chart, values)
 	if err != nil {
 		klog.Errorf("install release error: %s", err)
 		clientUnInstall := action.NewUninstall(actionConfig)
@@ -202,97 +241,108 @@ func (h *Helm) Create(requestParams interface{}) *utils.Response {
 		if err1 != nil {
 			klog.Errorf("uninstall release error: %s", err)
 		}
-		return &utils.Response{Code: code.ApplyError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ApplyError, err)
+	}
+	if queryParams.DryRun {
+		return &utils.Response{Code: code.Success, Msg: "Success", Data: h.buildDryRunRelease(rel)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
-func (h *Helm) Update(requestParams interface{}) *utils.Response {
+func (h *Helm) Update(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &HelmGetParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	chart, err := h.OspServer.GetAppChart(queryParams.ChartPath)
 	if err != nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "get chart error, " + err.Error()}
+		return utils.NewParamsErrorResponse("get chart error, " + err.Error())
 	}
 
 	actionConfig := new(action.Configuration)
 
-	clientGetter := newConfigFlags(h.KubeClient, queryParams.Namespace)
+	clientGetter := newConfigFlags(h.Client(ctx), queryParams.Namespace)
 	if err = actionConfig.Init(clientGetter, queryParams.Namespace, "", klog.Infof); err != nil {
 		klog.Errorf("init helm config error: %+v", err)
-		return &utils.Response{Code: code.ApplyError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ApplyError, err)
 	}
 	actionConfig.Releases.MaxHistory = 3
 
 	clientInstall := action.NewUpgrade(actionConfig)
 	clientInstall.Namespace = queryParams.Namespace
+	clientInstall.DryRun = queryParams.DryRun
 	values := make(map[string]interface{})
 	err = yaml.Unmarshal([]byte(queryParams.Values), &values)
 	if err != nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "values参数解析错误：" + err.Error()}
+		return utils.NewParamsErrorResponse("values参数解析错误：" + err.Error())
 	}
-	_, err = clientInstall.Run(queryParams.Name, chart, values)
+	rel, err := clientInstall.Run(queryParams.Name, chart, values)
 	if err != nil {
 		klog.Errorf("install release error: %s", err)
-		return &utils.Response{Code: code.ApplyError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ApplyError, err)
+	}
+	if queryParams.DryRun {
+		return &utils.Response{Code: code.Success, Msg: "Success", Data: h.buildDryRunRelease(rel)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
-func (h *Helm) Delete(requestParams interface{}) *utils.Response {
+func (h *Helm) Delete(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &HelmGetParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	klog.Info(queryParams)
 
 	actionConfig := new(action.Configuration)
 
-	clientGetter := newConfigFlags(h.KubeClient, queryParams.Namespace)
+	clientGetter := newConfigFlags(h.Client(ctx), queryParams.Namespace)
 	if err := actionConfig.Init(clientGetter, queryParams.Namespace, "", klog.Infof); err != nil {
 		klog.Errorf("init helm config error: %+v", err)
-		return &utils.Response{Code: code.ApplyError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ApplyError, err)
 	}
 	clientInstall := action.NewUninstall(actionConfig)
-	_, err := clientInstall.Run(queryParams.Name)
+	clientInstall.DryRun = queryParams.DryRun
+	res, err := clientInstall.Run(queryParams.Name)
 	if err != nil {
 		klog.Errorf("uninstall release error: %s", err)
-		return &utils.Response{Code: code.ApplyError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ApplyError, err)
+	}
+	if queryParams.DryRun && res != nil {
+		return &utils.Response{Code: code.Success, Msg: "Success", Data: h.buildDryRunRelease(res.Release)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
-func (h *Helm) GetValues(requestParams interface{}) *utils.Response {
+func (h *Helm) GetValues(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &HelmGetParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	actionConfig := new(action.Configuration)
 
-	clientGetter := newConfigFlags(h.KubeClient, queryParams.Namespace)
+	clientGetter := newConfigFlags(h.Client(ctx), queryParams.Namespace)
 	if err := actionConfig.Init(clientGetter, queryParams.Namespace, "", klog.Infof); err != nil {
 		klog.Errorf("%+v", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	client := action.NewGet(actionConfig)
 	res, err := client.Run(queryParams.Name)
 	if err != nil {
 		klog.Errorf("get release error: %s", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	var values string
 	for _, f := range res.Chart.Raw {
@@ -326,24 +376,24 @@ type ReleaseRuntimeStatus struct {
 	Workloads []*unstructured.Unstructured
 }
 
-func (h *Helm) Status(reqParams interface{}) *utils.Response {
+func (h *Helm) Status(ctx context.Context, reqParams interface{}) *utils.Response {
 	params := &RuntimeStatusParams{}
 	json.Unmarshal(reqParams.([]byte), params)
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	actionConfig := new(action.Configuration)
 
-	clientGetter := newConfigFlags(h.KubeClient, params.Namespace)
+	clientGetter := newConfigFlags(h.Client(ctx), params.Namespace)
 	if err := actionConfig.Init(clientGetter, params.Namespace, "", klog.Infof); err != nil {
 		klog.Errorf("%+v", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	client := action.NewList(actionConfig)
 	releases, err := client.Run()
 	if err != nil {
 		klog.Errorf("list helm error: %s", err)
-		return &utils.Response{Code: code.ListError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	var res []*ReleaseRuntimeStatus
 
@@ -354,7 +404,7 @@ func (h *Helm) Status(reqParams interface{}) *utils.Response {
 			wg.Add(1)
 			go func(releaseDetail *release.Release) {
 				defer wg.Done()
-				status := h.GetReleaseRuntimeStatus(releaseDetail, params.WithWorkloads)
+				status := h.GetReleaseRuntimeStatus(ctx, releaseDetail, params.WithWorkloads)
 				res = append(res, status)
 			}(releaseDetail)
 		}
@@ -381,7 +431,7 @@ func (h *Helm) GetReleaseObjects(release *release.Release) []*unstructured.Unstr
 	return objects
 }
 
-func (h *Helm) GetReleaseRuntimeStatus(release *release.Release, withWorkloads bool) *ReleaseRuntimeStatus {
+func (h *Helm) GetReleaseRuntimeStatus(ctx context.Context, release *release.Release, withWorkloads bool) *ReleaseRuntimeStatus {
 	var workloads []*unstructured.Unstructured
 	status := &ReleaseRuntimeStatus{
 		Name:      release.Name,
@@ -392,8 +442,12 @@ func (h *Helm) GetReleaseRuntimeStatus(release *release.Release, withWorkloads b
 	objects := h.GetReleaseObjects(release)
 	namespace := release.Namespace
 	for i, object := range objects {
+		if ctx.Err() != nil {
+			klog.Warningf("get release %s runtime status aborted: %v", release.Name, ctx.Err())
+			break
+		}
 		if utils.Contains(WorkloadKinds, object.GetKind()) {
-			obj, err := h.DynamicClient.Resource(*WorkloadGVRMap[object.GetKind()]).Namespace(namespace).Get(h.context, object.GetName(), metav1.GetOptions{})
+			obj, err := h.Client(ctx).DynamicClient.Resource(*WorkloadGVRMap[object.GetKind()]).Namespace(namespace).Get(ctx, object.GetName(), metav1.GetOptions{})
 			if err != nil {
 				klog.Errorf("get namespace %s workload %s/%s error: %s", namespace, object.GetKind(), object.GetName(), err.Error())
 				if withWorkloads {
@@ -419,7 +473,7 @@ func (h *Helm) GetReleaseRuntimeStatus(release *release.Release, withWorkloads b
 				}
 				continue
 			}
-			pods, err := h.DynamicClient.Resource(*PodGVR).Namespace(namespace).List(h.context, metav1.ListOptions{
+			pods, err := h.Client(ctx).DynamicClient.Resource(*PodGVR).Namespace(namespace).List(ctx, metav1.ListOptions{
 				LabelSelector: labels.Set(podLabels).AsSelector().String(),
 			})
 			if err != nil {
@@ -440,7 +494,7 @@ func (h *Helm) GetReleaseRuntimeStatus(release *release.Release, withWorkloads b
 				}
 			}
 		} else if object.GetKind() == "Pod" {
-			pod, err := h.DynamicClient.Resource(*PodGVR).Namespace(namespace).Get(h.context, object.GetName(), metav1.GetOptions{})
+			pod, err := h.Client(ctx).DynamicClient.Resource(*PodGVR).Namespace(namespace).Get(ctx, object.GetName(), metav1.GetOptions{})
 			if err != nil {
 				klog.Warning("get release pods error: ", err)
 			} else {
@@ -456,7 +510,7 @@ func (h *Helm) GetReleaseRuntimeStatus(release *release.Release, withWorkloads b
 				}
 			}
 		} else if object.GetKind() == "CronJob" {
-			cronjob, err := h.DynamicClient.Resource(*CronJobGVR).Namespace(namespace).Get(h.context, object.GetName(), metav1.GetOptions{})
+			cronjob, err := h.Client(ctx).DynamicClient.Resource(*CronJobGVR).Namespace(namespace).Get(ctx, object.GetName(), metav1.GetOptions{})
 			if err != nil {
 				klog.Warning("get release cronjob error: ", err)
 			} else {
@@ -471,7 +525,7 @@ func (h *Helm) GetReleaseRuntimeStatus(release *release.Release, withWorkloads b
 				for _, job := range jobs {
 					for _, ref := range job.OwnerReferences {
 						if ref.UID == cronjob.GetUID() {
-							pods, err := h.DynamicClient.Resource(*PodGVR).Namespace(namespace).List(h.context, metav1.ListOptions{
+							pods, err := h.Client(ctx).DynamicClient.Resource(*PodGVR).Namespace(namespace).List(ctx, metav1.ListOptions{
 								LabelSelector: labels.Set(job.Labels).AsSelector().String(),
 							})
 							if err != nil {
@@ -496,7 +550,7 @@ func (h *Helm) GetReleaseRuntimeStatus(release *release.Release, withWorkloads b
 				}
 			}
 		} else if object.GetKind() == "Service" {
-			svc, err := h.DynamicClient.Resource(*ServiceGVR).Namespace(namespace).Get(h.context, object.GetName(), metav1.GetOptions{})
+			svc, err := h.Client(ctx).DynamicClient.Resource(*ServiceGVR).Namespace(namespace).Get(ctx, object.GetName(), metav1.GetOptions{})
 			if err != nil {
 				klog.Warning("get release service error: ", err)
 			} else {
@@ -523,7 +577,7 @@ func (h *Helm) GetPodStatus(status string, pod *v1.Pod) string {
 		return status
 	}
 	secondDuration := time.Now().Sub(pod.CreationTimestamp.Time).Seconds()
-	klog.Infof("%d", secondDuration)
+	klog.V(1).Infof("pod %s not ready for %.0f seconds", pod.Name, secondDuration)
 	if secondDuration > 600 {
 		return ReleaseStatusRunningFault
 	} else {
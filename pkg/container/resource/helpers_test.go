@@ -0,0 +1,178 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery/cached/memory"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// 本文件为resource包的测试共用的fake集群、测试对象及模拟的api server
+
+var testAPIResources = []*metav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "secrets", SingularName: "secret", Namespaced: true, Kind: "Secret"},
+			{Name: "configmaps", SingularName: "configmap", Namespaced: true, Kind: "ConfigMap"},
+			{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod"},
+			{Name: "pods/log", Kind: "Pod"},
+			{Name: "namespaces", SingularName: "namespace", Kind: "Namespace"},
+		},
+	},
+	{
+		GroupVersion: "rbac.authorization.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "clusterroles", SingularName: "clusterrole", Kind: "ClusterRole"},
+			{Name: "roles", SingularName: "role", Namespaced: true, Kind: "Role"},
+		},
+	},
+	{
+		GroupVersion: "apiextensions.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "customresourcedefinitions", SingularName: "customresourcedefinition", Kind: "CustomResourceDefinition"},
+		},
+	},
+	{
+		GroupVersion: "autoscaling/v2",
+		APIResources: []metav1.APIResource{{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true}},
+	},
+	{
+		GroupVersion: "autoscaling/v1",
+		APIResources: []metav1.APIResource{{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true}},
+	},
+}
+
+var testListKinds = map[schema.GroupVersionResource]string{
+	{Version: "v1", Resource: "secrets"}:                                                  "SecretList",
+	{Version: "v1", Resource: "configmaps"}:                                               "ConfigMapList",
+	{Version: "v1", Resource: "pods"}:                                                     "PodList",
+	{Version: "v1", Resource: "namespaces"}:                                               "NamespaceList",
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}:         "ClusterRoleList",
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "roles"}:                "RoleList",
+	{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}: "CustomResourceDefinitionList",
+}
+
+// newTestFakeDiscovery 返回提供testAPIResources的fake discovery
+func newTestFakeDiscovery() *discoveryfake.FakeDiscovery {
+	return &discoveryfake.FakeDiscovery{
+		Fake:               &clienttesting.Fake{Resources: testAPIResources},
+		FakedServerVersion: &version.Info{GitVersion: "v1.23.5"},
+	}
+}
+
+// newTestDynamicResource 使用fake dynamic client及fake discovery创建DynamicResource
+func newTestDynamicResource(gvr *schema.GroupVersionResource, objects ...runtime.Object) (*DynamicResource, *dynamicfake.FakeDynamicClient) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), testListKinds, objects...)
+	d := NewDynamicResource(&kubernetes.KubeClient{DynamicClient: client}, gvr)
+	d.restMapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(newTestFakeDiscovery()))
+	return d, client
+}
+
+// newTestObject 创建测试对象，fields为metadata以外的字段，如data、spec、status
+func newTestObject(apiVersion, kind, namespace, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: make(map[string]interface{})}
+	for k, v := range fields {
+		obj.Object[k] = v
+	}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+// testSecretFields 带有敏感数据的字段，用于检查secret是否脱敏
+func testSecretFields() map[string]interface{} {
+	return map[string]interface{}{
+		"data":       map[string]interface{}{"password": "c2VjcmV0"},
+		"stringData": map[string]interface{}{"token": "secret"},
+	}
+}
+
+// newTestPod 创建用于列表查询的pod，created为创建时间的秒数
+func newTestPod(namespace, name string, created int, labels map[string]interface{}, phase string, restarts int64) *unstructured.Unstructured {
+	pod := newTestObject("v1", "Pod", namespace, name, map[string]interface{}{
+		"spec":   map[string]interface{}{"nodeName": "node-" + namespace},
+		"status": map[string]interface{}{"phase": phase, "restarts": restarts},
+	})
+	unstructured.SetNestedField(pod.Object, labels, "metadata", "labels")
+	unstructured.SetNestedField(pod.Object, fmt.Sprintf("2022-01-01T00:00:%02dZ", created), "metadata", "creationTimestamp")
+	return pod
+}
+
+var testPods = []*unstructured.Unstructured{
+	newTestPod("dev", "web", 3, map[string]interface{}{"app": "web"}, "Running", 10),
+	newTestPod("dev", "db", 1, map[string]interface{}{"app": "db"}, "Pending", 2),
+	newTestPod("prod", "web", 2, map[string]interface{}{"app": "web", "tier": "front"}, "Failed", 9),
+	newTestPod("prod", "cache", 4, nil, "Running", 0),
+}
+
+// testRequest 模拟的api server收到的请求
+type testRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// testAPIServer 模拟的api server，记录收到的请求，响应由测试提供的handler返回
+type testAPIServer struct {
+	mutex    sync.Mutex
+	requests []testRequest
+}
+
+func (s *testAPIServer) Requests() []testRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]testRequest(nil), s.requests...)
+}
+
+// newTestAPIServer 启动模拟的api server，返回的DynamicResource的rest config及dynamic client都指向该server
+func newTestAPIServer(t *testing.T, gvr *schema.GroupVersionResource, handler http.HandlerFunc) (*DynamicResource, *testAPIServer) {
+	s := &testAPIServer{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		s.mutex.Lock()
+		s.requests = append(s.requests, testRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header, Body: body})
+		s.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	config := &rest.Config{Host: server.URL}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := newTestDynamicResource(gvr)
+	d.KubeClient.Config = config
+	d.KubeClient.DynamicClient = client
+	return d, s
+}
+
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -73,33 +74,36 @@ func NewHorizontalPodAutoscaler(kubeClient *kubernetes.KubeClient, sendResponse
 	}
 }
 
-func (h *HorizontalPodAutoscaler) List(requestParams interface{}) *utils.Response {
+func (h *HorizontalPodAutoscaler) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return h.ListTable(ctx, requestParams)
+	}
 	HpaList, err := h.KubeClient.InformerRegistry.HorizontalPodAutoscalerInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var HpaResource []*BuildHorizontalPodAutoscaler
 	for _, hp := range HpaList {
-		HpaResource = append(HpaResource, h.ToBuildHorizontalPodAutoscaler(hp))
+		query.Add(hp, h.ToBuildHorizontalPodAutoscaler(hp))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: HpaResource}
+	return query.Response()
 }
 
-func (h *HorizontalPodAutoscaler) Get(requestParams interface{}) *utils.Response {
+func (h *HorizontalPodAutoscaler) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &HorizontalPodAutoscalerQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	Hpa, err := h.KubeClient.InformerRegistry.HorizontalPodAutoscalerInformer().Lister().HorizontalPodAutoscalers(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -115,7 +119,7 @@ func (h *HorizontalPodAutoscaler) Get(requestParams interface{}) *utils.Response
 		d, e := runtime.Encode(encoder, Hpa)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		klog.Info(d)
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
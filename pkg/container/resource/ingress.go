@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -143,7 +144,14 @@ type IngressUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (i *Ingress) List(requestParams interface{}) *utils.Response {
+func (i *Ingress) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return i.ListTable(ctx, requestParams)
+	}
 	queryParams := &IngressQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	var selector labels.Selector
@@ -155,12 +163,8 @@ func (i *Ingress) List(requestParams interface{}) *utils.Response {
 	if kubernetes.VersionGreaterThan19(i.KubeClient.Version) {
 		list, err := i.KubeClient.NewIngressInformer().Lister().Ingresses(queryParams.Namespace).List(selector)
 		if err != nil {
-			return &utils.Response{
-				Code: code.ListError,
-				Msg:  err.Error(),
-			}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
-		var ingresss []*BuildNewIngress
 		for _, ds := range list {
 			if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 				continue
@@ -171,22 +175,19 @@ func (i *Ingress) List(requestParams interface{}) *utils.Response {
 			if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 				continue
 			}
-			ingresss = append(ingresss, i.ToBuildNewIngress(ds))
+			query.Add(ds, i.ToBuildNewIngress(ds))
 		}
+		ingresses, listMeta := query.Result()
 		data := map[string]interface{}{
-			"ingresses": ingresss,
+			"ingresses": ingresses,
 			"group":     "networking",
 		}
-		return &utils.Response{Code: code.Success, Msg: "Success", Data: data}
+		return &utils.Response{Code: code.Success, Msg: "Success", Data: data, List: listMeta}
 	} else {
 		list, err := i.KubeClient.IngressInformer().Lister().Ingresses(queryParams.Namespace).List(selector)
 		if err != nil {
-			return &utils.Response{
-				Code: code.ListError,
-				Msg:  err.Error(),
-			}
+			return utils.NewErrorResponse(code.ListError, err)
 		}
-		var ingresss []*BuildIngress
 		for _, ds := range list {
 			if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 				continue
@@ -197,24 +198,25 @@ func (i *Ingress) List(requestParams interface{}) *utils.Response {
 			if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 				continue
 			}
-			ingresss = append(ingresss, i.ToBuildIngress(ds))
+			query.Add(ds, i.ToBuildIngress(ds))
 		}
+		ingresses, listMeta := query.Result()
 		data := map[string]interface{}{
-			"ingresses": ingresss,
+			"ingresses": ingresses,
 			"group":     "extensions",
 		}
-		return &utils.Response{Code: code.Success, Msg: "Success", Data: data}
+		return &utils.Response{Code: code.Success, Msg: "Success", Data: data, List: listMeta}
 	}
 }
 
-func (i *Ingress) Get(requestParams interface{}) *utils.Response {
+func (i *Ingress) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &IngressQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Ingress name is blank"}
+		return utils.NewParamsErrorResponse("Ingress name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	var ingress runtime.Object
 	var err error
@@ -224,7 +226,7 @@ func (i *Ingress) Get(requestParams interface{}) *utils.Response {
 		ingress, err = i.KubeClient.InformerRegistry.IngressInformer().Lister().Ingresses(queryParams.Namespace).Get(queryParams.Name)
 	}
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -245,24 +247,24 @@ func (i *Ingress) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, ingress)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.GetError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: ingress}
 }
 
-func (i *Ingress) UpdateObj(updateParams interface{}) *utils.Response {
+func (i *Ingress) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &IngressUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Ingress name is blank"}
+		return utils.NewParamsErrorResponse("Ingress name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -273,12 +275,12 @@ func (i *Ingress) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := i.ClientSet.ExtensionsV1beta1().Ingresses(params.Namespace).Update(i.context, result, metav1.UpdateOptions{})
+		_, updateErr := i.Client(ctx).ClientSet.ExtensionsV1beta1().Ingresses(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -100,19 +101,24 @@ type JobUpdateParams struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	Replicas  int32  `json:"replicas"`
+	// DryRun 只由apiserver校验并返回更新后的对象，不持久化
+	DryRun bool `json:"dry_run"`
 }
 
-func (j *Job) List(requestParams interface{}) *utils.Response {
+func (j *Job) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return j.ListTable(ctx, requestParams)
+	}
 	queryParams := &JobQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := j.KubeClient.InformerRegistry.JobInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var jobs []*BuildJob
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -135,23 +141,23 @@ func (j *Job) List(requestParams interface{}) *utils.Response {
 				continue
 			}
 		}
-		jobs = append(jobs, j.ToBuildJob(ds))
+		query.Add(ds, j.ToBuildJob(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: jobs}
+	return query.Response()
 }
 
-func (j *Job) Get(requestParams interface{}) *utils.Response {
+func (j *Job) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &JobQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Job name is blank"}
+		return utils.NewParamsErrorResponse("Job name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	job, err := j.KubeClient.InformerRegistry.JobInformer().Lister().Jobs(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -168,25 +174,26 @@ func (j *Job) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, job)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: job}
 }
 
-func (j *Job) UpdateObj(updateParams interface{}) *utils.Response {
+func (j *Job) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &JobUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Job name is blank"}
+		return utils.NewParamsErrorResponse("Job name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
+	var updated interface{}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
 		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
@@ -194,14 +201,17 @@ func (j *Job) UpdateObj(updateParams interface{}) *utils.Response {
 		if getErr != nil {
 			panic(fmt.Errorf("failed to get latest version of Job: %v", getErr))
 		}
+		// informer缓存中的对象不能修改
+		result = result.DeepCopy()
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := j.ClientSet.BatchV1().Jobs(params.Namespace).Update(j.context, result, metav1.UpdateOptions{})
+		obj, updateErr := j.Client(ctx).ClientSet.BatchV1().Jobs(params.Namespace).Update(ctx, result, metav1.UpdateOptions{DryRun: dryRunOption(params.DryRun)})
+		updated = obj
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success"}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: updated}
 }
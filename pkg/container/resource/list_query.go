@@ -0,0 +1,285 @@
+package resource
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SortByName    = "name"
+	SortByCreated = "created"
+	SortByStatus  = "status"
+)
+
+// ListQuery 所有List请求通用的查询参数，在informer缓存或api返回的对象上过滤、排序及分页
+type ListQuery struct {
+	// LabelSelector 可以是kubectl -l格式的字符串，也可以是metav1.LabelSelector对象
+	LabelSelector listLabelSelector `json:"label_selector"`
+	// FieldSelector 与kubectl --field-selector格式相同，可以使用对象的任意字段，如spec.nodeName、status.phase
+	FieldSelector string `json:"field_selector"`
+	// SortBy 排序方式：name、created、status或对象的字段路径，为空时按namespace、name排序
+	SortBy   string `json:"sort_by"`
+	SortDesc bool   `json:"sort_desc"`
+	// Limit 每页返回的数量，0为不分页；Continue 上一页返回的continue
+	Limit    int    `json:"limit"`
+	Continue string `json:"continue"`
+	// Output 为table时返回api server生成的Table
+	Output string `json:"output"`
+
+	fieldSelector fields.Selector
+	after         *listKey
+	items         []*listItem
+}
+
+type listLabelSelector struct {
+	labels.Selector
+}
+
+func (s *listLabelSelector) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var err error
+	if len(data) > 0 && data[0] == '"' {
+		var expr string
+		if err = json.Unmarshal(data, &expr); err != nil {
+			return err
+		}
+		s.Selector, err = labels.Parse(expr)
+		return err
+	}
+	labelSelector := &metav1.LabelSelector{}
+	if err = json.Unmarshal(data, labelSelector); err != nil {
+		return err
+	}
+	s.Selector, err = metav1.LabelSelectorAsSelector(labelSelector)
+	return err
+}
+
+// listKey 对象的排序键，同时作为continue token的内容，下一页从大于该键的对象开始
+type listKey struct {
+	Value     string `json:"v,omitempty"`
+	Namespace string `json:"ns,omitempty"`
+	Name      string `json:"n,omitempty"`
+	SortBy    string `json:"s,omitempty"`
+	SortDesc  bool   `json:"d,omitempty"`
+}
+
+type listItem struct {
+	key  listKey
+	item interface{}
+}
+
+func parseListQuery(requestParams interface{}) (*ListQuery, error) {
+	q := &ListQuery{}
+	if data, ok := requestParams.([]byte); ok && len(data) > 0 {
+		if err := json.Unmarshal(data, q); err != nil {
+			return nil, fmt.Errorf("parse list query error: %s", err.Error())
+		}
+	}
+	if q.LabelSelector.Selector == nil {
+		q.LabelSelector.Selector = labels.Everything()
+	}
+	q.fieldSelector = fields.Everything()
+	if q.FieldSelector != "" {
+		var err error
+		if q.fieldSelector, err = fields.ParseSelector(q.FieldSelector); err != nil {
+			return nil, fmt.Errorf("field selector error: %s", err.Error())
+		}
+	}
+	if q.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+	if q.Continue != "" {
+		after, err := decodeContinue(q.Continue)
+		if err != nil {
+			return nil, err
+		}
+		if after.SortBy != q.SortBy || after.SortDesc != q.SortDesc {
+			return nil, fmt.Errorf("continue token does not match sort_by")
+		}
+		q.after = after
+	}
+	return q, nil
+}
+
+// serverFieldSelector 由api server处理field selector，之后不再在agent中过滤
+func (q *ListQuery) serverFieldSelector() string {
+	q.fieldSelector = fields.Everything()
+	return q.FieldSelector
+}
+
+func listQueryError(err error) *utils.Response {
+	return utils.NewParamsErrorResponse(err.Error())
+}
+
+func encodeContinue(key listKey) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeContinue(token string) (*listKey, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid continue token")
+	}
+	key := &listKey{}
+	if err = json.Unmarshal(data, key); err != nil {
+		return nil, fmt.Errorf("invalid continue token")
+	}
+	return key, nil
+}
+
+// Add 对象满足label、field selector时加入结果，item为返回给server的数据
+func (q *ListQuery) Add(obj runtime.Object, item interface{}) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		klog.Errorf("list query accessor error: %s", err.Error())
+		return
+	}
+	if !q.LabelSelector.Matches(labels.Set(accessor.GetLabels())) {
+		return
+	}
+	var content map[string]interface{}
+	fieldValue := func(path string) string {
+		if content == nil {
+			if content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+				klog.Errorf("convert %s/%s to unstructured error: %s", accessor.GetNamespace(), accessor.GetName(), err.Error())
+				content = map[string]interface{}{}
+			}
+		}
+		return nestedFieldString(content, path)
+	}
+	if !q.fieldSelector.Empty() {
+		set := fields.Set{}
+		for _, r := range q.fieldSelector.Requirements() {
+			set[r.Field] = fieldValue(r.Field)
+		}
+		if !q.fieldSelector.Matches(set) {
+			return
+		}
+	}
+	key := listKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}
+	switch q.SortBy {
+	case "":
+	case SortByName:
+		key.Value = accessor.GetName()
+	case SortByCreated:
+		key.Value = accessor.GetCreationTimestamp().UTC().Format(time.RFC3339)
+	case SortByStatus:
+		if key.Value = itemStatus(item); key.Value == "" {
+			key.Value = fieldValue("status.phase")
+		}
+	default:
+		key.Value = fieldValue(q.SortBy)
+	}
+	q.items = append(q.items, &listItem{key: key, item: item})
+}
+
+// Result 返回排序、分页后的数据及分页信息
+func (q *ListQuery) Result() ([]interface{}, *utils.ListMeta) {
+	sort.SliceStable(q.items, func(i, j int) bool {
+		return q.less(q.items[i].key, q.items[j].key)
+	})
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(q.items), func(i int) bool {
+			return q.less(*q.after, q.items[i].key)
+		})
+	}
+	end := len(q.items)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+	var items []interface{}
+	for _, i := range q.items[start:end] {
+		items = append(items, i.item)
+	}
+	listMeta := &utils.ListMeta{Total: len(q.items), Remaining: len(q.items) - end}
+	if end < len(q.items) {
+		key := q.items[end-1].key
+		key.SortBy, key.SortDesc = q.SortBy, q.SortDesc
+		listMeta.Continue = encodeContinue(key)
+	}
+	return items, listMeta
+}
+
+func (q *ListQuery) Response() *utils.Response {
+	items, listMeta := q.Result()
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: items, List: listMeta}
+}
+
+func (q *ListQuery) less(a, b listKey) bool {
+	if q.SortDesc {
+		a, b = b, a
+	}
+	if c := compareValue(a.Value, b.Value); c != 0 {
+		return c < 0
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// compareValue 两个值都是数字时按数值比较，否则按字符串比较
+func compareValue(a, b string) int {
+	if a == b {
+		return 0
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		if fa < fb {
+			return -1
+		}
+		if fa > fb {
+			return 1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func nestedFieldString(content map[string]interface{}, path string) string {
+	val, found, err := unstructured.NestedFieldNoCopy(content, strings.Split(path, ".")...)
+	if err != nil || !found || val == nil {
+		return ""
+	}
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprint(val)
+}
+
+// itemStatus 返回数据中的status字段，与页面上展示的状态一致
+func itemStatus(item interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	switch v.Kind() {
+	case reflect.Struct:
+		if f := v.FieldByName("Status"); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			if s := v.MapIndex(reflect.ValueOf("status").Convert(v.Type().Key())); s.IsValid() && s.CanInterface() {
+				return fmt.Sprint(s.Interface())
+			}
+		}
+	}
+	return ""
+}
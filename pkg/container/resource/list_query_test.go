@@ -0,0 +1,189 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type testListItem struct {
+	Name   string
+	Status string
+}
+
+func queryNames(t *testing.T, params map[string]interface{}) ([]string, *utils.ListMeta) {
+	data, _ := json.Marshal(params)
+	q, err := parseListQuery(data)
+	if err != nil {
+		t.Fatalf("parseListQuery() error = %v", err)
+	}
+	for _, pod := range testPods {
+		q.Add(pod, &testListItem{Name: pod.GetNamespace() + "/" + pod.GetName()})
+	}
+	items, listMeta := q.Result()
+	var names []string
+	for _, item := range items {
+		names = append(names, item.(*testListItem).Name)
+	}
+	return names, listMeta
+}
+
+func TestListQueryFilterAndSort(t *testing.T) {
+	cases := []struct {
+		name   string
+		params map[string]interface{}
+		want   []string
+	}{
+		{name: "default order", want: []string{"dev/db", "dev/web", "prod/cache", "prod/web"}},
+		{name: "label selector string", params: map[string]interface{}{"label_selector": "app=web,tier!=front"}, want: []string{"dev/web"}},
+		{
+			name:   "label selector object",
+			params: map[string]interface{}{"label_selector": map[string]interface{}{"matchExpressions": []map[string]interface{}{{"key": "app", "operator": "Exists"}}}},
+			want:   []string{"dev/db", "dev/web", "prod/web"},
+		},
+		{name: "field selector", params: map[string]interface{}{"field_selector": "status.phase=Running"}, want: []string{"dev/web", "prod/cache"}},
+		{name: "field selector on spec", params: map[string]interface{}{"field_selector": "spec.nodeName!=node-dev"}, want: []string{"prod/cache", "prod/web"}},
+		{name: "sort by name", params: map[string]interface{}{"sort_by": SortByName}, want: []string{"prod/cache", "dev/db", "dev/web", "prod/web"}},
+		{name: "sort by created desc", params: map[string]interface{}{"sort_by": SortByCreated, "sort_desc": true}, want: []string{"prod/cache", "dev/web", "prod/web", "dev/db"}},
+		{name: "sort by status", params: map[string]interface{}{"sort_by": SortByStatus}, want: []string{"prod/web", "dev/db", "dev/web", "prod/cache"}},
+		{name: "sort by numeric field", params: map[string]interface{}{"sort_by": "status.restarts"}, want: []string{"prod/cache", "dev/db", "prod/web", "dev/web"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got, _ := queryNames(t, c.params); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("items = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestListQueryPagination(t *testing.T) {
+	cases := []struct {
+		name   string
+		params map[string]interface{}
+		limit  int
+		want   [][]string
+	}{
+		{name: "no limit", want: [][]string{{"dev/db", "dev/web", "prod/cache", "prod/web"}}},
+		{name: "limit larger than total", limit: 10, want: [][]string{{"dev/db", "dev/web", "prod/cache", "prod/web"}}},
+		{name: "exact pages", limit: 2, want: [][]string{{"dev/db", "dev/web"}, {"prod/cache", "prod/web"}}},
+		{name: "last page shorter", limit: 3, want: [][]string{{"dev/db", "dev/web", "prod/cache"}, {"prod/web"}}},
+		{
+			name:   "sorted desc with equal values",
+			params: map[string]interface{}{"sort_by": SortByName, "sort_desc": true},
+			limit:  1,
+			want:   [][]string{{"prod/web"}, {"dev/web"}, {"dev/db"}, {"prod/cache"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			params := map[string]interface{}{"limit": c.limit}
+			for k, v := range c.params {
+				params[k] = v
+			}
+			var pages [][]string
+			returned := 0
+			for {
+				names, listMeta := queryNames(t, params)
+				pages = append(pages, names)
+				returned += len(names)
+				if listMeta.Total != len(testPods) || listMeta.Remaining != listMeta.Total-returned {
+					t.Errorf("page %d total = %d, remaining = %d", len(pages), listMeta.Total, listMeta.Remaining)
+				}
+				if listMeta.Continue == "" {
+					break
+				}
+				if len(pages) > len(testPods) {
+					t.Fatalf("too many pages: %v", pages)
+				}
+				params["continue"] = listMeta.Continue
+			}
+			if !reflect.DeepEqual(pages, c.want) {
+				t.Errorf("pages = %v, want %v", pages, c.want)
+			}
+		})
+	}
+}
+
+func TestParseListQueryError(t *testing.T) {
+	token := encodeContinue(listKey{Name: "a", SortBy: SortByName})
+	cases := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{name: "invalid label selector", params: map[string]interface{}{"label_selector": "app in (web"}},
+		{name: "invalid field selector", params: map[string]interface{}{"field_selector": "status.phase"}},
+		{name: "negative limit", params: map[string]interface{}{"limit": -1}},
+		{name: "invalid continue token", params: map[string]interface{}{"continue": "not a token"}},
+		{name: "continue token of another sort", params: map[string]interface{}{"continue": token, "sort_by": SortByCreated}},
+		{name: "continue token of another direction", params: map[string]interface{}{"continue": token, "sort_by": SortByName, "sort_desc": true}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, _ := json.Marshal(c.params)
+			if _, err := parseListQuery(data); err == nil {
+				t.Error("parseListQuery() error = nil")
+			}
+		})
+	}
+}
+
+func TestItemStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		item interface{}
+		want string
+	}{
+		{name: "struct pointer", item: &testListItem{Status: "Running"}, want: "Running"},
+		{name: "struct", item: testListItem{Status: "Failed"}, want: "Failed"},
+		{name: "map", item: map[string]interface{}{"status": "Bound"}, want: "Bound"},
+		{name: "map without status", item: map[string]interface{}{"name": "a"}},
+		{name: "non string status field", item: struct{ Status int }{Status: 1}},
+		{name: "nil", item: nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := itemStatus(c.item); got != c.want {
+				t.Errorf("itemStatus() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDynamicListObjectsPagination(t *testing.T) {
+	var objects []runtime.Object
+	for _, pod := range testPods {
+		objects = append(objects, pod.DeepCopy())
+	}
+	d, _ := newTestDynamicResource(&schema.GroupVersionResource{Version: "v1", Resource: "pods"}, objects...)
+	params := map[string]interface{}{"labels": map[string]string{"app": "web"}, "limit": 1}
+	var names []string
+	for i := 0; i < 3; i++ {
+		data, _ := json.Marshal(params)
+		resp := d.ListObjects(context.Background(), data)
+		if resp.Code != code.Success {
+			t.Fatalf("ListObjects() = %s %s", resp.Code, resp.Msg)
+		}
+		for _, item := range resp.Data.([]interface{}) {
+			obj := item.(unstructured.Unstructured)
+			names = append(names, obj.GetNamespace()+"/"+obj.GetName())
+		}
+		if resp.List.Total != 2 {
+			t.Errorf("total = %d, want 2", resp.List.Total)
+		}
+		if resp.List.Continue == "" {
+			break
+		}
+		params["continue"] = resp.List.Continue
+	}
+	if want := []string{"dev/web", "prod/web"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed objects = %v, want %v", names, want)
+	}
+}
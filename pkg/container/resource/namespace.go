@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -79,34 +80,37 @@ func (n *Namespace) ToBuildNamespace(ns *v1.Namespace) *BuildNamespace {
 	}
 }
 
-func (n *Namespace) List(requestParams interface{}) *utils.Response {
+func (n *Namespace) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return n.ListTable(ctx, requestParams)
+	}
 	queryParams := &NsQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	nsList, err := n.KubeClient.InformerRegistry.NamespaceInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var nsRes []*BuildNamespace
 	for _, ns := range nsList {
 		if queryParams.Name == "" || strings.Contains(ns.ObjectMeta.Name, queryParams.Name) {
-			nsRes = append(nsRes, n.ToBuildNamespace(ns))
+			query.Add(ns, n.ToBuildNamespace(ns))
 		}
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: nsRes}
+	return query.Response()
 }
 
-func (n *Namespace) Get(requestParams interface{}) *utils.Response {
+func (n *Namespace) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &NsQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Service name is blank"}
+		return utils.NewParamsErrorResponse("Service name is blank")
 	}
 	ns, err := n.KubeClient.InformerRegistry.NamespaceInformer().Lister().Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -123,7 +127,7 @@ func (n *Namespace) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, ns)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -83,17 +84,20 @@ type NetworkPolicyUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (n *NetworkPolicy) List(requestParams interface{}) *utils.Response {
+func (n *NetworkPolicy) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return n.ListTable(ctx, requestParams)
+	}
 	queryParams := &NetworkPolicyQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := n.KubeClient.InformerRegistry.NetworkPolicyInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var networkpolicies []*BuildNetworkPolicy
 	for _, np := range list {
 		if queryParams.UID != "" && string(np.UID) != queryParams.UID {
 			continue
@@ -104,23 +108,23 @@ func (n *NetworkPolicy) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(np.Name, queryParams.Name) {
 			continue
 		}
-		networkpolicies = append(networkpolicies, n.ToBuildNetworkPolicy(np))
+		query.Add(np, n.ToBuildNetworkPolicy(np))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: networkpolicies}
+	return query.Response()
 }
 
-func (n *NetworkPolicy) Get(requestParams interface{}) *utils.Response {
+func (n *NetworkPolicy) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &NetworkPolicyQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "NetworkPolicy name is blank"}
+		return utils.NewParamsErrorResponse("NetworkPolicy name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	networkpolicy, err := n.KubeClient.InformerRegistry.NetworkPolicyInformer().Lister().NetworkPolicies(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -137,24 +141,24 @@ func (n *NetworkPolicy) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, networkpolicy)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: networkpolicy}
 }
 
-func (n *NetworkPolicy) UpdateObj(updateParams interface{}) *utils.Response {
+func (n *NetworkPolicy) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &NetworkPolicyUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "NetworkPolicy name is blank"}
+		return utils.NewParamsErrorResponse("NetworkPolicy name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -165,12 +169,12 @@ func (n *NetworkPolicy) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		//result.Spec.Replicas = &paramn.Replicas
-		_, updateErr := n.ClientSet.NetworkingV1().NetworkPolicies(params.Namespace).Update(n.context, result, metav1.UpdateOptions{})
+		_, updateErr := n.Client(ctx).ClientSet.NetworkingV1().NetworkPolicies(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
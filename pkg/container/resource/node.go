@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -112,30 +113,33 @@ type NodeQueryParams struct {
 	Output string `json:"output"`
 }
 
-func (n *Node) List(requestParams interface{}) *utils.Response {
+func (n *Node) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return n.ListTable(ctx, requestParams)
+	}
 	nodeList, err := n.KubeClient.InformerRegistry.NodeInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var nodeResource []*BuildNode
 	for _, node := range nodeList {
-		nodeResource = append(nodeResource, n.ToBuildNode(node))
+		query.Add(node, n.ToBuildNode(node))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: nodeResource}
+	return query.Response()
 }
 
-func (n *Node) Get(requestParams interface{}) *utils.Response {
+func (n *Node) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &NodeQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	sc, err := n.KubeClient.NodeInformer().Lister().Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -151,7 +155,7 @@ func (n *Node) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, sc)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
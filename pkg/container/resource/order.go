@@ -0,0 +1,97 @@
+package resource
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"time"
+)
+
+const (
+	// crdEstablishedTimeout 创建CRD后等待其Established的最长时间
+	crdEstablishedTimeout = 30 * time.Second
+	crdEstablishedPoll    = 500 * time.Millisecond
+)
+
+type document struct {
+	// index 文档在yaml中的序号
+	index int
+	kind  string
+	data  []byte
+}
+
+// readDocuments 读取多文档yaml并按类型依赖排序，Namespace、CRD、RBAC、配置在工作负载之前，
+// 排序与helm安装顺序一致，未知类型（如CR）排在最后，同类型保持原有顺序
+func readDocuments(yamlStr string) ([]*document, error) {
+	multidocReader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader([]byte(yamlStr))))
+	var docs []*document
+	for index := 0; ; index++ {
+		buf, err := multidocReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		typeMeta := &metav1.TypeMeta{}
+		// 解析失败的文档在处理时返回错误
+		yaml.Unmarshal(buf, typeMeta)
+		docs = append(docs, &document{index: index, kind: typeMeta.Kind, data: buf})
+	}
+	order := make(map[string]int, len(releaseutil.InstallOrder))
+	for i, kind := range releaseutil.InstallOrder {
+		order[kind] = i
+	}
+	rank := func(kind string) int {
+		if i, ok := order[kind]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return rank(docs[i].kind) < rank(docs[j].kind)
+	})
+	return docs, nil
+}
+
+func isCRD(obj *unstructured.Unstructured) bool {
+	return obj.GetKind() == "CustomResourceDefinition" && obj.GroupVersionKind().Group == "apiextensions.k8s.io"
+}
+
+// waitForCRDEstablished 等待CRD可以使用，之后的CR才能找到对应的资源，查询CRD出现NotFound以外的错误时立即返回
+func waitForCRDEstablished(ctx context.Context, dr dynamic.ResourceInterface, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, crdEstablishedTimeout)
+	defer cancel()
+	err := wait.PollImmediateUntil(crdEstablishedPoll, func() (bool, error) {
+		crd, err := dr.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if ok && condition["type"] == "Established" && condition["status"] == "True" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, ctx.Done())
+	if err != nil {
+		return fmt.Errorf("wait for CustomResourceDefinition %s established error: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestReadDocuments(t *testing.T) {
+	cases := []struct {
+		name      string
+		yaml      string
+		wantKinds []string
+		wantIndex []int
+		wantErr   bool
+	}{
+		{name: "empty"},
+		{
+			name:      "install order",
+			yaml:      "kind: Deployment\n---\nkind: Foo\n---\nkind: Namespace\n---\nkind: CustomResourceDefinition\n---\nkind: ConfigMap\n",
+			wantKinds: []string{"Namespace", "ConfigMap", "CustomResourceDefinition", "Deployment", "Foo"},
+			wantIndex: []int{2, 4, 3, 0, 1},
+		},
+		{
+			name:      "same kind keeps order",
+			yaml:      "kind: ConfigMap\nmetadata:\n  name: b\n---\nkind: ConfigMap\nmetadata:\n  name: a\n",
+			wantKinds: []string{"ConfigMap", "ConfigMap"},
+			wantIndex: []int{0, 1},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			docs, err := readDocuments(c.yaml)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("readDocuments() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if len(docs) != len(c.wantKinds) {
+				t.Fatalf("readDocuments() = %d documents, want %d", len(docs), len(c.wantKinds))
+			}
+			for i, doc := range docs {
+				if doc.kind != c.wantKinds[i] || doc.index != c.wantIndex[i] {
+					t.Errorf("document %d = %s/%d, want %s/%d", i, doc.kind, doc.index, c.wantKinds[i], c.wantIndex[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWaitForCRDEstablished(t *testing.T) {
+	crdGVR := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	crd := func(established string) runtime.Object {
+		return newTestObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "foos.example.com", map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": "Established", "status": established},
+			}},
+		})
+	}
+	forbidden := apierrors.NewForbidden(crdGVR.GroupResource(), "foos.example.com", errors.New("denied"))
+	cases := []struct {
+		name    string
+		objects []runtime.Object
+		// getErr Get返回的错误
+		getErr     error
+		ctxTimeout time.Duration
+		wantErr    bool
+	}{
+		{name: "established", objects: []runtime.Object{crd("True")}},
+		{name: "forbidden returns immediately", getErr: forbidden, ctxTimeout: 5 * time.Second, wantErr: true},
+		{name: "not found until timeout", ctxTimeout: 100 * time.Millisecond, wantErr: true},
+		{name: "not established until timeout", objects: []runtime.Object{crd("False")}, ctxTimeout: 100 * time.Millisecond, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, client := newTestDynamicResource(nil, c.objects...)
+			if c.getErr != nil {
+				client.PrependReactor("get", "customresourcedefinitions", func(clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, c.getErr
+				})
+			}
+			ctx := context.Background()
+			if c.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.ctxTimeout)
+				defer cancel()
+			}
+			start := time.Now()
+			err := waitForCRDEstablished(ctx, client.Resource(crdGVR), "foos.example.com")
+			if (err != nil) != c.wantErr {
+				t.Fatalf("waitForCRDEstablished() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if c.getErr != nil {
+				if !apierrors.IsForbidden(err) {
+					t.Errorf("waitForCRDEstablished() error = %v, want forbidden", err)
+				}
+				if elapsed := time.Since(start); elapsed > time.Second {
+					t.Errorf("waitForCRDEstablished() returned after %v", elapsed)
+				}
+			}
+		})
+	}
+}
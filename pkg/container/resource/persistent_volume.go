@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -88,30 +89,33 @@ func (p *PersistentVolume) ToBuildPersistentVolume(pv *v1.PersistentVolume) *Bui
 	return pvData
 }
 
-func (p *PersistentVolume) List(requestParams interface{}) *utils.Response {
+func (p *PersistentVolume) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return p.ListTable(ctx, requestParams)
+	}
 	persistentVolumeList, err := p.KubeClient.InformerRegistry.PersistentVolumeInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var persistentVolumeResource []*BuildPersistentVolume
 	for _, pv := range persistentVolumeList {
-		persistentVolumeResource = append(persistentVolumeResource, p.ToBuildPersistentVolume(pv))
+		query.Add(pv, p.ToBuildPersistentVolume(pv))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: persistentVolumeResource}
+	return query.Response()
 }
 
-func (p *PersistentVolume) Get(requestParams interface{}) *utils.Response {
+func (p *PersistentVolume) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &ConfigMapQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	pv, err := p.KubeClient.PersistentVolumeInformer().Lister().Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -127,7 +131,7 @@ func (p *PersistentVolume) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, pv)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
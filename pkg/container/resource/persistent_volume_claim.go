@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -84,7 +85,14 @@ func (p *PersistentVolumeClaim) ToBuildPersistentVolumeClaim(pvc *v1.PersistentV
 	return pvcData
 }
 
-func (p *PersistentVolumeClaim) List(requestParams interface{}) *utils.Response {
+func (p *PersistentVolumeClaim) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return p.ListTable(ctx, requestParams)
+	}
 	queryParams := &QueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	var selector labels.Selector
@@ -95,30 +103,26 @@ func (p *PersistentVolumeClaim) List(requestParams interface{}) *utils.Response
 	}
 	persistentVolumeClaimList, err := p.KubeClient.PersistentVolumeClaimInformer().Lister().PersistentVolumeClaims(queryParams.Namespace).List(selector)
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var persistentVolumeClaimResource []*BuildPersistentVolumeClaim
 	for _, pvc := range persistentVolumeClaimList {
-		persistentVolumeClaimResource = append(persistentVolumeClaimResource, p.ToBuildPersistentVolumeClaim(pvc))
+		query.Add(pvc, p.ToBuildPersistentVolumeClaim(pvc))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: persistentVolumeClaimResource}
+	return query.Response()
 }
 
-func (p *PersistentVolumeClaim) Get(requestParams interface{}) *utils.Response {
+func (p *PersistentVolumeClaim) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &ConfigMapQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	pvc, err := p.KubeClient.InformerRegistry.PersistentVolumeClaimInformer().Lister().PersistentVolumeClaims(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -134,7 +138,7 @@ func (p *PersistentVolumeClaim) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, pvc)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		klog.Info(d)
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
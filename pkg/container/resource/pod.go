@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -11,7 +12,6 @@ import (
 	"io"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -63,11 +63,10 @@ func (p *Pod) DoWatch() {
 }
 
 type PodQueryParams struct {
-	Name          string                `json:"name"`
-	Namespace     string                `json:"namespace"`
-	Output        string                `json:"output"`
-	LabelSelector *metav1.LabelSelector `json:"label_selector"`
-	Names         []string              `json:"names"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Output    string   `json:"output"`
+	Names     []string `json:"names"`
 }
 
 type DeletePodParams struct {
@@ -171,27 +170,21 @@ func (p *Pod) ToBuildPod(pod *v1.Pod) *BuildPod {
 	}
 }
 
-func (p *Pod) List(requestParams interface{}) *utils.Response {
+func (p *Pod) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return p.ListTable(ctx, requestParams)
+	}
 	queryParams := &PodQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
-	labelSelector := labels.Everything()
-	if queryParams.LabelSelector != nil {
-		var err error
-		labelSelector, err = metav1.LabelSelectorAsSelector(queryParams.LabelSelector)
-		if err != nil {
-			klog.Errorf("label selector error: %v", err)
-			return &utils.Response{Code: code.ParamsError, Msg: err.Error()}
-		}
-	}
-	podList, err := p.KubeClient.InformerRegistry.PodInformer().Lister().Pods(queryParams.Namespace).List(labelSelector)
+	podList, err := p.KubeClient.InformerRegistry.PodInformer().Lister().Pods(queryParams.Namespace).List(query.LabelSelector)
 	//p.KubeClient.ClientSet.CoreV1().Pods(queryParams.Namespace).List(labelSelector)
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var podRes []*BuildPod
 	for _, pod := range podList {
 		if queryParams.Name != "" && !strings.Contains(pod.Name, queryParams.Name) {
 			continue
@@ -199,23 +192,23 @@ func (p *Pod) List(requestParams interface{}) *utils.Response {
 		if len(queryParams.Names) > 0 && !utils.Contains(queryParams.Names, pod.Name) {
 			continue
 		}
-		podRes = append(podRes, p.ToBuildPod(pod))
+		query.Add(pod, p.ToBuildPod(pod))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: podRes}
+	return query.Response()
 }
 
-func (p *Pod) Get(requestParams interface{}) *utils.Response {
+func (p *Pod) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &PodQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Pod name is blank"}
+		return utils.NewParamsErrorResponse("Pod name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	pod, err := p.KubeClient.PodInformer().Lister().Pods(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -232,7 +225,7 @@ func (p *Pod) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, pod)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
@@ -248,22 +241,22 @@ type PodExecParams struct {
 	Cols      string `json:"cols"`
 }
 
-func (p *Pod) Exec(requestParams interface{}) *utils.Response {
+func (p *Pod) Exec(ctx context.Context, requestParams interface{}) *utils.Response {
 	params := &PodExecParams{}
 	json.Unmarshal(requestParams.([]byte), params)
 	klog.Info(params)
-	go p.startProcess(params.Name, params.Namespace, params.Container, params.SessionId, params.Rows, params.Cols)
+	go p.startProcess(p.Client(ctx), params.Name, params.Namespace, params.Container, params.SessionId, params.Rows, params.Cols)
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
-func (p *Pod) startProcess(podName, namespace, container, sessionId, rows, cols string) {
+func (p *Pod) startProcess(client *kubernetes.KubeClient, podName, namespace, container, sessionId, rows, cols string) {
 	execCmd := []string{"/bin/sh", "-c",
 		fmt.Sprintf(`export LINES=%s; export COLUMNS=%s; 
 	 TERM=xterm-256color; export TERM;
 	 [ -x /bin/bash ] && ([ -x /usr/bin/script ] && /usr/bin/script -q -c \"/bin/bash\" /dev/null || exec /bin/bash) || exec /bin/sh`,
 			rows, cols)}
 	klog.Info(execCmd)
-	sshReq := p.ClientSet.CoreV1().RESTClient().Post().
+	sshReq := client.ClientSet.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(podName).
 		Namespace(namespace).
@@ -277,10 +270,10 @@ func (p *Pod) startProcess(podName, namespace, container, sessionId, rows, cols
 			TTY:       true,
 		}, scheme.ParameterCodec)
 
-	executor, err := remotecommand.NewSPDYExecutor(p.Config, "POST", sshReq.URL())
+	executor, err := remotecommand.NewSPDYExecutor(client.Config, "POST", sshReq.URL())
 	if err != nil {
 		klog.Error("exec pod container error", err)
-		p.SendResponse(base64.StdEncoding.EncodeToString([]byte(err.Error())), sessionId, utils.ExecType)
+		p.SendResponse([]byte(err.Error()), sessionId, utils.ExecType)
 		return
 	}
 
@@ -303,10 +296,10 @@ func (p *Pod) startProcess(podName, namespace, container, sessionId, rows, cols
 		Tty:               true,
 	}); err != nil {
 		klog.Errorf("exec pod container error session %s: %v", sessionId, err)
-		p.SendResponse(base64.StdEncoding.EncodeToString([]byte(err.Error())), sessionId, utils.ExecType)
+		p.SendResponse([]byte(err.Error()), sessionId, utils.ExecType)
 		return
 	}
-	p.SendResponse(base64.StdEncoding.EncodeToString([]byte(fmt.Sprint("\nConnection closed"))), sessionId, utils.ExecType)
+	p.SendResponse([]byte("\nConnection closed"), sessionId, utils.ExecType)
 	klog.Info("end stream session", sessionId)
 }
 
@@ -317,12 +310,12 @@ type StdInParams struct {
 	Height    uint16 `json:"height"`
 }
 
-func (p *Pod) ExecStdIn(requestParams interface{}) *utils.Response {
+func (p *Pod) ExecStdIn(ctx context.Context, requestParams interface{}) *utils.Response {
 	params := &StdInParams{}
 	json.Unmarshal(requestParams.([]byte), params)
 	handler := p.execSessions[params.SessionId]
 	if handler == nil {
-		return &utils.Response{Code: code.ParamsError, Msg: "Not found session id"}
+		return utils.NewParamsErrorResponse("Not found session id")
 	}
 	if params.Width > 0 && params.Height > 0 {
 		handler.resizeEvent <- remotecommand.TerminalSize{Width: params.Width, Height: params.Height}
@@ -380,7 +373,7 @@ type OpenPodLogParams struct {
 	SessionId string `json:"session_id"`
 }
 
-func (p *Pod) OpenLog(requestParams interface{}) *utils.Response {
+func (p *Pod) OpenLog(ctx context.Context, requestParams interface{}) *utils.Response {
 	params := &OpenPodLogParams{}
 	json.Unmarshal(requestParams.([]byte), params)
 	klog.V(1).Info(params)
@@ -391,7 +384,7 @@ func (p *Pod) OpenLog(requestParams interface{}) *utils.Response {
 		TailLines: &tailLines,
 		//Timestamps: true,
 	}
-	go p.logProcess(params.Namespace, params.Name, params.SessionId, podLogOpts)
+	go p.logProcess(p.Client(ctx), params.Namespace, params.Name, params.SessionId, podLogOpts)
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
@@ -399,7 +392,7 @@ type ClosePodLogParams struct {
 	SessionId string `json:"session_id"`
 }
 
-func (p *Pod) CloseLog(requestParams interface{}) *utils.Response {
+func (p *Pod) CloseLog(ctx context.Context, requestParams interface{}) *utils.Response {
 	params := &ClosePodLogParams{}
 	json.Unmarshal(requestParams.([]byte), params)
 	klog.V(1).Info(params)
@@ -425,13 +418,13 @@ func (l *logHandler) Write(p []byte) (size int, err error) {
 	return
 }
 
-func (p *Pod) logProcess(namespace, name, sessionId string, podLogOpts *v1.PodLogOptions) {
+func (p *Pod) logProcess(client *kubernetes.KubeClient, namespace, name, sessionId string, podLogOpts *v1.PodLogOptions) {
 
-	req := p.ClientSet.CoreV1().Pods(namespace).GetLogs(name, podLogOpts)
+	req := client.ClientSet.CoreV1().Pods(namespace).GetLogs(name, podLogOpts)
 	podLogs, err := req.Stream(p.context)
 	if err != nil {
 		klog.Errorf("open log stream session %s error: %v", sessionId, err)
-		p.SendResponse(base64.StdEncoding.EncodeToString([]byte(err.Error())), sessionId, utils.LogType)
+		p.SendResponse([]byte(err.Error()), sessionId, utils.LogType)
 		return
 	}
 	defer podLogs.Close()
@@ -450,7 +443,7 @@ func (p *Pod) logProcess(namespace, name, sessionId string, podLogOpts *v1.PodLo
 	_, err = io.Copy(handler, podLogs)
 	if err != nil {
 		klog.Errorf("copy log session %s error: %v", sessionId, err)
-		p.SendResponse(base64.StdEncoding.EncodeToString([]byte(err.Error())), sessionId, utils.LogType)
+		p.SendResponse([]byte(err.Error()), sessionId, utils.LogType)
 		return
 	}
 	klog.Info("end log session ", sessionId)
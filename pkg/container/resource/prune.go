@@ -0,0 +1,192 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"strings"
+)
+
+const (
+	// ApplySetLabel apply时添加到每个对象上的标签，值为apply set的ID，用于找出不再在manifest中的对象
+	ApplySetLabel = "kubespace.io/apply-set"
+
+	ObjectPruned = "pruned"
+)
+
+// DefaultPruneAllowlist 默认可清理的类型，格式为 group/version/Kind，core为空group。
+// 不包含Namespace、PersistentVolume等删除后影响范围较大的类型，以及Secret、Pod等常由控制器或其它工具创建的类型
+var DefaultPruneAllowlist = []string{
+	"core/v1/ConfigMap",
+	"core/v1/Endpoints",
+	"core/v1/PersistentVolumeClaim",
+	"core/v1/ReplicationController",
+	"core/v1/Service",
+	"core/v1/ServiceAccount",
+	"batch/v1/Job",
+	"batch/v1/CronJob",
+	"networking.k8s.io/v1/Ingress",
+	"apps/v1/DaemonSet",
+	"apps/v1/Deployment",
+	"apps/v1/ReplicaSet",
+	"apps/v1/StatefulSet",
+}
+
+func parseAllowlistGVK(s string) (schema.GroupVersionKind, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid prune allowlist item %q, should be group/version/Kind", s)
+	}
+	group := parts[0]
+	if group == "core" {
+		group = ""
+	}
+	return schema.GroupVersionKind{Group: group, Version: parts[1], Kind: parts[2]}, nil
+}
+
+func validateApplySet(applySet string) error {
+	if applySet == "" {
+		return fmt.Errorf("apply_set is required when prune is enabled")
+	}
+	if errs := validation.IsValidLabelValue(applySet); len(errs) > 0 {
+		return fmt.Errorf("invalid apply_set %q: %s", applySet, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func objectKey(gk schema.GroupKind, namespace, name string) string {
+	return gk.String() + "/" + namespace + "/" + name
+}
+
+// pruneMappings 解析可清理类型的RESTMapping，集群不支持的类型忽略
+func (d *DynamicResource) pruneMappings(params *ApplyParams) ([]*meta.RESTMapping, error) {
+	allowlist := params.PruneAllowlist
+	if len(allowlist) == 0 {
+		allowlist = DefaultPruneAllowlist
+	}
+	var mappings []*meta.RESTMapping
+	for _, item := range allowlist {
+		gvk, err := parseAllowlistGVK(item)
+		if err != nil {
+			return nil, err
+		}
+		mapping, err := d.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				// 集群不支持该类型
+				continue
+			}
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// prune 删除带有apply set标签但不在本次apply结果中的对象，namespace级别的类型只在本次apply涉及的namespace中清理
+func (d *DynamicResource) prune(ctx context.Context, params *ApplyParams, mappings []*meta.RESTMapping, applied []*ObjectResult) ([]*ObjectResult, error) {
+	appliedKeys := make(map[string]bool)
+	namespaces := make(map[string]bool)
+	for _, r := range applied {
+		gk := schema.GroupKind{Group: r.Group, Kind: r.Kind}
+		appliedKeys[objectKey(gk, r.Namespace, r.Name)] = true
+		if r.Namespace != "" {
+			namespaces[r.Namespace] = true
+		}
+	}
+	selector := metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: map[string]string{ApplySetLabel: params.ApplySet}})
+	deletePolicy := metav1.DeletePropagationForeground
+	deleteOptions := metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+		DryRun:            dryRunOption(params.DryRun),
+	}
+
+	var results []*ObjectResult
+	for _, mapping := range mappings {
+		gvk := mapping.GroupVersionKind
+		var clients []dynamic.ResourceInterface
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			for ns := range namespaces {
+				clients = append(clients, d.Client(ctx).DynamicClient.Resource(mapping.Resource).Namespace(ns))
+			}
+		} else {
+			clients = append(clients, d.Client(ctx).DynamicClient.Resource(mapping.Resource))
+		}
+		for _, client := range clients {
+			list, err := client.List(ctx, metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				if appliedKeys[objectKey(gvk.GroupKind(), obj.GetNamespace(), obj.GetName())] {
+					continue
+				}
+				result := &ObjectResult{
+					Index:           -1,
+					Group:           gvk.Group,
+					Version:         gvk.Version,
+					Kind:            gvk.Kind,
+					Namespace:       obj.GetNamespace(),
+					Name:            obj.GetName(),
+					Operation:       ObjectPruned,
+					ResourceVersion: obj.GetResourceVersion(),
+					UID:             obj.GetUID(),
+				}
+				var objClient dynamic.ResourceInterface = client
+				if obj.GetNamespace() != "" {
+					objClient = d.Client(ctx).DynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+				}
+				if err = objClient.Delete(ctx, obj.GetName(), deleteOptions); err != nil && !apierrors.IsNotFound(err) {
+					result.fail(err)
+				}
+				results = append(results, result)
+			}
+		}
+	}
+	return results, nil
+}
+
+// applyWithPrune apply成功后清理不再在manifest中的对象，任何对象apply失败时不清理
+func (d *DynamicResource) applyWithPrune(ctx context.Context, params *ApplyParams, mappings []*meta.RESTMapping, resp *utils.Response) *utils.Response {
+	if !resp.IsSuccess() {
+		return resp
+	}
+	applied, _ := resp.Data.([]*ObjectResult)
+	pruned, err := d.prune(ctx, params, mappings, applied)
+	if err != nil {
+		resp.Code = code.ApplyError
+		resp.Msg = strings.TrimPrefix(resp.Msg+"\nprune error : "+err.Error(), "\n")
+		resp.Error = utils.NewError(err)
+		return resp
+	}
+	var lines []string
+	pruneFailed := false
+	for _, r := range pruned {
+		if r.Operation == ObjectFailed {
+			lines = append(lines, r.Kind+"/"+r.Name+" prune error : "+r.Msg)
+			if !pruneFailed {
+				pruneFailed = true
+				resp.Error = r.Error
+			}
+		} else {
+			lines = append(lines, r.Kind+"/"+r.Name+" pruned successful.")
+		}
+		applied = append(applied, r)
+	}
+	resp.Data = applied
+	if len(lines) > 0 {
+		resp.Msg = strings.TrimPrefix(resp.Msg+"\n"+strings.Join(lines, "\n"), "\n")
+	}
+	if pruneFailed {
+		resp.Code = code.ApplyError
+	}
+	return resp
+}
@@ -0,0 +1,146 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseAllowlistGVK(t *testing.T) {
+	cases := []struct {
+		item    string
+		want    schema.GroupVersionKind
+		wantErr bool
+	}{
+		{item: "core/v1/ConfigMap", want: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}},
+		{item: "apps/v1/Deployment", want: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		{item: "v1/ConfigMap", wantErr: true},
+		{item: "apps//Deployment", wantErr: true},
+		{item: "apps/v1/", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.item, func(t *testing.T) {
+			got, err := parseAllowlistGVK(c.item)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("parseAllowlistGVK() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if got != c.want {
+				t.Errorf("parseAllowlistGVK() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDefaultPruneAllowlist(t *testing.T) {
+	for _, item := range DefaultPruneAllowlist {
+		gvk, err := parseAllowlistGVK(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch gvk.Kind {
+		case "Secret", "Pod", "Namespace", "PersistentVolume":
+			t.Errorf("default prune allowlist contains %s", item)
+		}
+	}
+}
+
+func TestValidateApplySet(t *testing.T) {
+	cases := []struct {
+		applySet string
+		wantErr  bool
+	}{
+		{applySet: "", wantErr: true},
+		{applySet: "app-1"},
+		{applySet: "bad value", wantErr: true},
+	}
+	for _, c := range cases {
+		if err := validateApplySet(c.applySet); (err != nil) != c.wantErr {
+			t.Errorf("validateApplySet(%q) error = %v, wantErr %v", c.applySet, err, c.wantErr)
+		}
+	}
+}
+
+func TestApplyPruneClusterScoped(t *testing.T) {
+	cases := []struct {
+		name             string
+		allowlist        []string
+		allowClusterWide bool
+		wantCode         string
+	}{
+		{name: "cluster scoped denied", allowlist: []string{"rbac.authorization.k8s.io/v1/ClusterRole"}, wantCode: code.Forbidden},
+		{name: "namespace denied", allowlist: []string{"core/v1/ConfigMap", "core/v1/Namespace"}, wantCode: code.Forbidden},
+		{name: "invalid item", allowlist: []string{"ClusterRole"}, wantCode: code.ParamsError},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, client := newTestDynamicResource(nil)
+			d.allowClusterWide = c.allowClusterWide
+			params, _ := json.Marshal(&ApplyParams{YamlStr: testManifest, Prune: true, ApplySet: "app", PruneAllowlist: c.allowlist})
+			resp := d.ApplyYaml(context.Background(), params)
+			if resp.Code != c.wantCode {
+				t.Errorf("ApplyYaml() code = %s, want %s, msg %s", resp.Code, c.wantCode, resp.Msg)
+			}
+			// 拒绝时不能apply任何对象
+			if actions := client.Actions(); len(actions) != 0 {
+				t.Errorf("ApplyYaml() sent requests %v", actions)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	labeled := func(kind, namespace, name, applySet string) runtime.Object {
+		obj := newTestObject("v1", kind, namespace, name, nil)
+		obj.SetLabels(map[string]string{ApplySetLabel: applySet})
+		return obj
+	}
+	objects := []runtime.Object{
+		labeled("ConfigMap", "dev", "kept", "app"),
+		labeled("ConfigMap", "dev", "removed", "app"),
+		labeled("ConfigMap", "dev", "other-set", "other"),
+		labeled("ConfigMap", "prod", "other-namespace", "app"),
+		labeled("Secret", "dev", "secret", "app"),
+	}
+	cases := []struct {
+		name       string
+		allowlist  []string
+		wantPruned []string
+	}{
+		{name: "default allowlist", wantPruned: []string{"removed"}},
+		{name: "secret allowlisted", allowlist: []string{"core/v1/Secret"}, wantPruned: []string{"secret"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, client := newTestDynamicResource(nil, objects...)
+			params := &ApplyParams{Prune: true, ApplySet: "app", PruneAllowlist: c.allowlist}
+			mappings, err := d.pruneMappings(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			applied := []*ObjectResult{{Kind: "ConfigMap", Namespace: "dev", Name: "kept"}}
+			pruned, err := d.prune(context.Background(), params, mappings, applied)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, r := range pruned {
+				if r.Operation != ObjectPruned {
+					t.Errorf("%s operation = %s, msg %s", r.Name, r.Operation, r.Msg)
+				}
+				names = append(names, r.Name)
+			}
+			if len(names) != len(c.wantPruned) || (len(names) > 0 && names[0] != c.wantPruned[0]) {
+				t.Fatalf("pruned = %v, want %v", names, c.wantPruned)
+			}
+			gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+			if _, err = client.Resource(gvr).Namespace("dev").Get(context.Background(), "kept", metav1.GetOptions{}); err != nil {
+				t.Errorf("applied object deleted: %v", err)
+			}
+		})
+	}
+}
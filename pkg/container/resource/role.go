@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -117,24 +118,24 @@ type RoleUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (s *Role) List(requestParams interface{}) *utils.Response {
+func (s *Role) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return s.roleDynamic.listTable(ctx, requestParams, s.roleDynamic.GroupVersionResource, s.clusterRoleDynamic.GroupVersionResource)
+	}
 	queryParams := &RoleQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := s.KubeClient.InformerRegistry.RoleInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	clist, err := s.KubeClient.InformerRegistry.ClusterRoleInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var roles []*BuildRole
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -145,7 +146,7 @@ func (s *Role) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		roles = append(roles, s.ToBuildRole(ds))
+		query.Add(ds, s.ToBuildRole(ds))
 	}
 	for _, ds := range clist {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
@@ -157,22 +158,22 @@ func (s *Role) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		roles = append(roles, s.ToBuildClusterRole(ds))
+		query.Add(ds, s.ToBuildClusterRole(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: roles}
+	return query.Response()
 }
 
-func (s *Role) Get(requestParams interface{}) *utils.Response {
+func (s *Role) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &RoleQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Role name is blank"}
+		return utils.NewParamsErrorResponse("Role name is blank")
 	}
 	if queryParams.Kind == "" || (queryParams.Kind != "ClusterRole" && queryParams.Kind != "Role") {
-		return &utils.Response{Code: code.ParamsError, Msg: "Kind is not correct"}
+		return utils.NewParamsErrorResponse("Kind is not correct")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	var role runtime.Object
 	var err error
@@ -182,7 +183,7 @@ func (s *Role) Get(requestParams interface{}) *utils.Response {
 		role, err = s.KubeClient.InformerRegistry.ClusterRoleInformer().Lister().Get(queryParams.Name)
 	}
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -203,24 +204,24 @@ func (s *Role) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, role)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: role}
 }
 
-func (s *Role) UpdateObj(updateParams interface{}) *utils.Response {
+func (s *Role) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &RoleUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Role name is blank"}
+		return utils.NewParamsErrorResponse("Role name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -231,23 +232,34 @@ func (s *Role) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := s.ClientSet.RbacV1().Roles(params.Namespace).Update(s.roleDynamic.context, result, metav1.UpdateOptions{})
+		_, updateErr := s.Client(ctx).ClientSet.RbacV1().Roles(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
-func (s *Role) UpdateYaml(updateParams interface{}) *utils.Response {
+func (s *Role) UpdateYaml(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &DynamicUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Kind == "ClusterRole" {
-		return s.clusterRoleDynamic.UpdateYaml(updateParams)
+		return s.clusterRoleDynamic.UpdateYaml(ctx, updateParams)
+	} else if params.Kind == "Role" {
+		return s.roleDynamic.UpdateYaml(ctx, updateParams)
+	}
+	return utils.NewParamsErrorResponse("Kind parameter is not correct")
+}
+
+func (s *Role) Patch(ctx context.Context, patchParams interface{}) *utils.Response {
+	params := &DynamicPatchParams{}
+	json.Unmarshal(patchParams.([]byte), params)
+	if params.Kind == "ClusterRole" {
+		return s.clusterRoleDynamic.Patch(ctx, patchParams)
 	} else if params.Kind == "Role" {
-		return s.roleDynamic.UpdateYaml(updateParams)
+		return s.roleDynamic.Patch(ctx, patchParams)
 	}
-	return &utils.Response{Code: code.ParamsError, Msg: "Kind parameter is not correct"}
+	return utils.NewParamsErrorResponse("Kind parameter is not correct")
 }
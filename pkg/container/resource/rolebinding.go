@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -120,24 +121,24 @@ type RoleBindingUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (s *RoleBinding) List(requestParams interface{}) *utils.Response {
+func (s *RoleBinding) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return s.roleBindingDynamic.listTable(ctx, requestParams, s.roleBindingDynamic.GroupVersionResource, s.clusterRoleBindingDynamic.GroupVersionResource)
+	}
 	queryParams := &RoleBindingQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := s.KubeClient.InformerRegistry.RoleBindingInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
 	clist, err := s.KubeClient.InformerRegistry.ClusterRoleBindingInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var roleBindings []*BuildRoleBinding
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -148,7 +149,7 @@ func (s *RoleBinding) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		roleBindings = append(roleBindings, s.ToBuildRoleBinding(ds))
+		query.Add(ds, s.ToBuildRoleBinding(ds))
 	}
 	for _, ds := range clist {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
@@ -160,22 +161,22 @@ func (s *RoleBinding) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		roleBindings = append(roleBindings, s.ToBuildClusterRoleBinding(ds))
+		query.Add(ds, s.ToBuildClusterRoleBinding(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: roleBindings}
+	return query.Response()
 }
 
-func (s *RoleBinding) Get(requestParams interface{}) *utils.Response {
+func (s *RoleBinding) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &RoleBindingQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "RoleBinding name is blank"}
+		return utils.NewParamsErrorResponse("RoleBinding name is blank")
 	}
 	if queryParams.Kind == "" || (queryParams.Kind != "ClusterRoleBinding" && queryParams.Kind != "RoleBinding") {
-		return &utils.Response{Code: code.ParamsError, Msg: "Kind is not correct"}
+		return utils.NewParamsErrorResponse("Kind is not correct")
 	}
 	if queryParams.Namespace == "" && queryParams.Kind == "RoleBinding" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	var roleBinding runtime.Object
 	var err error
@@ -185,7 +186,7 @@ func (s *RoleBinding) Get(requestParams interface{}) *utils.Response {
 		roleBinding, err = s.KubeClient.InformerRegistry.ClusterRoleBindingInformer().Lister().Get(queryParams.Name)
 	}
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -206,24 +207,24 @@ func (s *RoleBinding) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, roleBinding)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: roleBinding}
 }
 
-func (s *RoleBinding) UpdateObj(updateParams interface{}) *utils.Response {
+func (s *RoleBinding) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &RoleBindingUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "RoleBinding name is blank"}
+		return utils.NewParamsErrorResponse("RoleBinding name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -234,23 +235,34 @@ func (s *RoleBinding) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := s.ClientSet.RbacV1().RoleBindings(params.Namespace).Update(s.roleBindingDynamic.context, result, metav1.UpdateOptions{})
+		_, updateErr := s.Client(ctx).ClientSet.RbacV1().RoleBindings(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
 
-func (s *RoleBinding) UpdateYaml(updateParams interface{}) *utils.Response {
+func (s *RoleBinding) UpdateYaml(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &DynamicUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Kind == "ClusterRoleBinding" {
-		return s.clusterRoleBindingDynamic.UpdateYaml(updateParams)
+		return s.clusterRoleBindingDynamic.UpdateYaml(ctx, updateParams)
+	} else if params.Kind == "RoleBinding" {
+		return s.roleBindingDynamic.UpdateYaml(ctx, updateParams)
+	}
+	return utils.NewParamsErrorResponse("Kind parameter is not correct")
+}
+
+func (s *RoleBinding) Patch(ctx context.Context, patchParams interface{}) *utils.Response {
+	params := &DynamicPatchParams{}
+	json.Unmarshal(patchParams.([]byte), params)
+	if params.Kind == "ClusterRoleBinding" {
+		return s.clusterRoleBindingDynamic.Patch(ctx, patchParams)
 	} else if params.Kind == "RoleBinding" {
-		return s.roleBindingDynamic.UpdateYaml(updateParams)
+		return s.roleBindingDynamic.Patch(ctx, patchParams)
 	}
-	return &utils.Response{Code: code.ParamsError, Msg: "Kind parameter is not correct"}
+	return utils.NewParamsErrorResponse("Kind parameter is not correct")
 }
@@ -1,6 +1,9 @@
 package resource
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -8,6 +11,7 @@ import (
 	"github.com/kubespace/agent/pkg/utils/code"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -25,6 +29,8 @@ import (
 type Secret struct {
 	watch *WatchResource
 	*DynamicResource
+	// redactData 查询及watch secret时不返回data
+	redactData bool
 }
 
 type BuildSecret struct {
@@ -60,15 +66,17 @@ func (s *Secret) ToBuildSecret(se *v1.Secret) *BuildSecret {
 	return sData
 }
 
-func NewSecret(kubeClient *kubernetes.KubeClient, watch *WatchResource) *Secret {
+func NewSecret(kubeClient *kubernetes.KubeClient, watch *WatchResource, redactData bool) *Secret {
 	s := &Secret{
-		watch: watch,
+		watch:      watch,
+		redactData: redactData,
 		DynamicResource: NewDynamicResource(kubeClient, &schema.GroupVersionResource{
 			Group:    "",
 			Version:  "v1",
 			Resource: "secrets",
 		}),
 	}
+	s.DynamicResource.redactSecrets = redactData
 	s.DoWatch()
 	return s
 }
@@ -76,13 +84,84 @@ func NewSecret(kubeClient *kubernetes.KubeClient, watch *WatchResource) *Secret
 func (s *Secret) DoWatch() {
 	informer := s.KubeClient.SecretInformer().Informer()
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    s.watch.WatchAdd(utils.WatchService),
-		UpdateFunc: s.watch.WatchUpdate(utils.WatchService),
-		DeleteFunc: s.watch.WatchDelete(utils.WatchService),
+		AddFunc: func(obj interface{}) {
+			s.watch.WatchAdd(utils.WatchService)(s.redact(obj))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			s.watch.WatchUpdate(utils.WatchService)(s.redact(oldObj), s.redact(newObj))
+		},
+		DeleteFunc: func(obj interface{}) {
+			s.watch.WatchDelete(utils.WatchService)(s.redact(obj))
+		},
 	})
 }
 
-func (s *Secret) List(requestParams interface{}) *utils.Response {
+// redact 返回去掉data的secret副本，informer缓存中的对象不能修改
+func (s *Secret) redact(obj interface{}) interface{} {
+	if !s.redactData {
+		return obj
+	}
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return obj
+	}
+	secret = secret.DeepCopy()
+	secret.Data = nil
+	secret.StringData = nil
+	return secret
+}
+
+// redactSecret 对象为secret时返回去掉data及stringData的副本，其它对象原样返回
+func redactSecret(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj == nil || obj.GroupVersionKind() != v1.SchemeGroupVersion.WithKind("Secret") {
+		return obj
+	}
+	return removeSecretData(obj)
+}
+
+// maskSecretData 对象为secret时返回data及stringData的值替换为HMAC摘要的副本，用于比较secret的值是否变化
+func maskSecretData(obj *unstructured.Unstructured, key []byte) *unstructured.Unstructured {
+	if obj == nil || obj.GroupVersionKind() != v1.SchemeGroupVersion.WithKind("Secret") {
+		return obj
+	}
+	obj = obj.DeepCopy()
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj.Object[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(fmt.Sprint(v)))
+			values[k] = fmt.Sprintf("<redacted hmac-sha256:%x>", mac.Sum(nil)[:8])
+		}
+	}
+	return obj
+}
+
+// removeSecretData 返回去掉data及stringData的secret副本
+func removeSecretData(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj == nil {
+		return obj
+	}
+	obj = obj.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "data")
+	unstructured.RemoveNestedField(obj.Object, "stringData")
+	return obj
+}
+
+func (s *Secret) ListObjects(ctx context.Context, listParams interface{}) *utils.Response {
+	return s.listObjects(ctx, listParams, s.redactData)
+}
+
+func (s *Secret) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return s.ListTable(ctx, requestParams)
+	}
 	queryParams := &SecretQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	var selector labels.Selector
@@ -93,31 +172,32 @@ func (s *Secret) List(requestParams interface{}) *utils.Response {
 	}
 	secretList, err := s.KubeClient.SecretInformer().Lister().Secrets(queryParams.Namespace).List(selector)
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var secretResource []*BuildSecret
 	for _, cm := range secretList {
-		secretResource = append(secretResource, s.ToBuildSecret(cm))
+		bs := s.ToBuildSecret(cm)
+		if s.redactData {
+			bs.Data = nil
+		}
+		query.Add(cm, bs)
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: secretResource}
+	return query.Response()
 }
 
-func (s *Secret) Get(requestParams interface{}) *utils.Response {
+func (s *Secret) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &SecretQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	secret, err := s.KubeClient.InformerRegistry.SecretInformer().Lister().Secrets(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
+	secret = s.redact(secret).(*v1.Secret)
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
 		rscheme := runtime.NewScheme()
@@ -132,7 +212,7 @@ func (s *Secret) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, secret)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		klog.Info(d)
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
@@ -0,0 +1,46 @@
+package resource
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDynamicResourceRedact(t *testing.T) {
+	cases := []struct {
+		name          string
+		redactSecrets bool
+		obj           *unstructured.Unstructured
+		wantData      bool
+	}{
+		{name: "secret redacted", redactSecrets: true, obj: newTestObject("v1", "Secret", "default", "a", testSecretFields())},
+		{name: "secret not redacted", obj: newTestObject("v1", "Secret", "default", "a", testSecretFields()), wantData: true},
+		{name: "configmap kept", redactSecrets: true, obj: newTestObject("v1", "ConfigMap", "default", "a", testSecretFields()), wantData: true},
+		{name: "other group secret kept", redactSecrets: true, obj: newTestObject("example.com/v1", "Secret", "default", "a", testSecretFields()), wantData: true},
+		{name: "nil", redactSecrets: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := &DynamicResource{redactSecrets: c.redactSecrets}
+			got := d.redact(c.obj)
+			if c.obj == nil {
+				if got != nil {
+					t.Fatalf("redact(nil) = %v", got)
+				}
+				return
+			}
+			_, hasData := got.Object["data"]
+			_, hasStringData := got.Object["stringData"]
+			if hasData != c.wantData || hasStringData != c.wantData {
+				t.Errorf("redact() data = %v, stringData = %v, want %v", hasData, hasStringData, c.wantData)
+			}
+			if got.GetName() != "a" {
+				t.Errorf("redact() lost metadata: %v", got.Object)
+			}
+			// 不能修改原对象
+			if _, ok := c.obj.Object["data"]; !ok {
+				t.Errorf("redact() modified original object")
+			}
+		})
+	}
+}
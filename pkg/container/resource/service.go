@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -94,7 +95,14 @@ type ServiceUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (s *Service) List(requestParams interface{}) *utils.Response {
+func (s *Service) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return s.ListTable(ctx, requestParams)
+	}
 	queryParams := &ServiceQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	var selector labels.Selector
@@ -105,12 +113,8 @@ func (s *Service) List(requestParams interface{}) *utils.Response {
 	}
 	list, err := s.KubeClient.ServiceInformer().Lister().Services(queryParams.Namespace).List(selector)
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var services []*BuildService
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -121,23 +125,23 @@ func (s *Service) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		services = append(services, s.ToBuildService(ds))
+		query.Add(ds, s.ToBuildService(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: services}
+	return query.Response()
 }
 
-func (s *Service) Get(requestParams interface{}) *utils.Response {
+func (s *Service) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &ServiceQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Service name is blank"}
+		return utils.NewParamsErrorResponse("Service name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	service, err := s.KubeClient.InformerRegistry.ServiceInformer().Lister().Services(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -154,24 +158,24 @@ func (s *Service) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, service)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: service}
 }
 
-func (s *Service) UpdateObj(updateParams interface{}) *utils.Response {
+func (s *Service) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &ServiceUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Service name is blank"}
+		return utils.NewParamsErrorResponse("Service name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -182,12 +186,12 @@ func (s *Service) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := s.ClientSet.CoreV1().Services(params.Namespace).Update(s.context, result, metav1.UpdateOptions{})
+		_, updateErr := s.Client(ctx).ClientSet.CoreV1().Services(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
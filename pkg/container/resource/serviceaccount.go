@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -83,17 +84,20 @@ type ServiceAccountUpdateParams struct {
 	Replicas  int32  `json:"replicas"`
 }
 
-func (s *ServiceAccount) List(requestParams interface{}) *utils.Response {
+func (s *ServiceAccount) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return s.ListTable(ctx, requestParams)
+	}
 	queryParams := &ServiceAccountQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	list, err := s.KubeClient.InformerRegistry.ServiceAccountInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var serviceAccounts []*BuildServiceAccount
 	for _, ds := range list {
 		if queryParams.UID != "" && string(ds.UID) != queryParams.UID {
 			continue
@@ -104,23 +108,23 @@ func (s *ServiceAccount) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ds.Name, queryParams.Name) {
 			continue
 		}
-		serviceAccounts = append(serviceAccounts, s.ToBuildServiceAccount(ds))
+		query.Add(ds, s.ToBuildServiceAccount(ds))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: serviceAccounts}
+	return query.Response()
 }
 
-func (s *ServiceAccount) Get(requestParams interface{}) *utils.Response {
+func (s *ServiceAccount) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &ServiceAccountQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "ServiceAccount name is blank"}
+		return utils.NewParamsErrorResponse("ServiceAccount name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	serviceAccount, err := s.KubeClient.InformerRegistry.ServiceAccountInformer().Lister().ServiceAccounts(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -137,24 +141,24 @@ func (s *ServiceAccount) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, serviceAccount)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: serviceAccount}
 }
 
-func (s *ServiceAccount) UpdateObj(updateParams interface{}) *utils.Response {
+func (s *ServiceAccount) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &ServiceAccountUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "ServiceAccount name is blank"}
+		return utils.NewParamsErrorResponse("ServiceAccount name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
@@ -165,12 +169,12 @@ func (s *ServiceAccount) UpdateObj(updateParams interface{}) *utils.Response {
 		}
 
 		//result.Spec.Replicas = &params.Replicas
-		_, updateErr := s.ClientSet.CoreV1().ServiceAccounts(params.Namespace).Update(s.context, result, metav1.UpdateOptions{})
+		_, updateErr := s.Client(ctx).ClientSet.CoreV1().ServiceAccounts(params.Namespace).Update(ctx, result, metav1.UpdateOptions{})
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success"}
 }
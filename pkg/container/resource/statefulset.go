@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -100,19 +101,24 @@ type StatefulSetUpdateParams struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	Replicas  int32  `json:"replicas"`
+	// DryRun 只由apiserver校验并返回更新后的对象，不持久化
+	DryRun bool `json:"dry_run"`
 }
 
-func (s *StatefulSet) List(requestParams interface{}) *utils.Response {
+func (s *StatefulSet) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return s.ListTable(ctx, requestParams)
+	}
 	queryParams := &StatefulSetQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	ssList, err := s.KubeClient.InformerRegistry.StatefulSetInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var bss []*BuildStatefulSet
 	for _, ss := range ssList {
 		if queryParams.UID != "" && string(ss.UID) != queryParams.UID {
 			continue
@@ -123,23 +129,23 @@ func (s *StatefulSet) List(requestParams interface{}) *utils.Response {
 		if queryParams.Name != "" && strings.Contains(ss.Name, queryParams.Name) {
 			continue
 		}
-		bss = append(bss, s.ToBuildStatefulSet(ss))
+		query.Add(ss, s.ToBuildStatefulSet(ss))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: bss}
+	return query.Response()
 }
 
-func (s *StatefulSet) Get(requestParams interface{}) *utils.Response {
+func (s *StatefulSet) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &StatefulSetQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "StatefulSet name is blank"}
+		return utils.NewParamsErrorResponse("StatefulSet name is blank")
 	}
 	if queryParams.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	ss, err := s.KubeClient.InformerRegistry.StatefulSetInformer().Lister().StatefulSets(queryParams.Namespace).Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -156,25 +162,26 @@ func (s *StatefulSet) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, ss)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.Success, Msg: e.Error()}
+			return utils.NewErrorResponse(code.GetError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
 	return &utils.Response{Code: code.Success, Msg: "Success", Data: ss}
 }
 
-func (s *StatefulSet) UpdateObj(updateParams interface{}) *utils.Response {
+func (s *StatefulSet) UpdateObj(ctx context.Context, updateParams interface{}) *utils.Response {
 	params := &StatefulSetUpdateParams{}
 	json.Unmarshal(updateParams.([]byte), params)
 	if params.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "StatefulSet name is blank"}
+		return utils.NewParamsErrorResponse("StatefulSet name is blank")
 	}
 	if params.Namespace == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Namespace is blank"}
+		return utils.NewParamsErrorResponse("Namespace is blank")
 	}
 	if params.Replicas < 1 {
-		return &utils.Response{Code: code.ParamsError, Msg: "Replicas is less than 1"}
+		return utils.NewParamsErrorResponse("Replicas is less than 1")
 	}
+	var updated interface{}
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Deployment before attempting update
 		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
@@ -182,14 +189,17 @@ func (s *StatefulSet) UpdateObj(updateParams interface{}) *utils.Response {
 		if getErr != nil {
 			panic(fmt.Errorf("failed to get latest version of StatefulSets: %v", getErr))
 		}
+		// informer缓存中的对象不能修改
+		result = result.DeepCopy()
 
 		result.Spec.Replicas = &params.Replicas
-		_, updateErr := s.ClientSet.AppsV1().StatefulSets(params.Namespace).Update(s.context, result, metav1.UpdateOptions{})
+		obj, updateErr := s.Client(ctx).ClientSet.AppsV1().StatefulSets(params.Namespace).Update(ctx, result, metav1.UpdateOptions{DryRun: dryRunOption(params.DryRun)})
+		updated = obj
 		return updateErr
 	})
 	if retryErr != nil {
 		klog.Errorf("Update failed: %v", retryErr)
-		return &utils.Response{Code: code.ParamsError, Msg: retryErr.Error()}
+		return utils.NewErrorResponse(code.ParamsError, retryErr)
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success"}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: updated}
 }
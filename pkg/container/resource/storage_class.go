@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kubespace/agent/pkg/kubernetes"
@@ -81,30 +82,33 @@ func (s *StorageClass) ToBuildStorageClass(sc *v1.StorageClass) *BuildStorageCla
 	return pvData
 }
 
-func (s *StorageClass) List(requestParams interface{}) *utils.Response {
+func (s *StorageClass) List(ctx context.Context, requestParams interface{}) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	if query.Output == OutputTable {
+		return s.ListTable(ctx, requestParams)
+	}
 	persistentVolumeList, err := s.KubeClient.InformerRegistry.StorageClassInformer().Lister().List(labels.Everything())
 	if err != nil {
-		return &utils.Response{
-			Code: code.ListError,
-			Msg:  err.Error(),
-		}
+		return utils.NewErrorResponse(code.ListError, err)
 	}
-	var persistentVolumeResource []*BuildStorageClass
 	for _, pv := range persistentVolumeList {
-		persistentVolumeResource = append(persistentVolumeResource, s.ToBuildStorageClass(pv))
+		query.Add(pv, s.ToBuildStorageClass(pv))
 	}
-	return &utils.Response{Code: code.Success, Msg: "Success", Data: persistentVolumeResource}
+	return query.Response()
 }
 
-func (s *StorageClass) Get(requestParams interface{}) *utils.Response {
+func (s *StorageClass) Get(ctx context.Context, requestParams interface{}) *utils.Response {
 	queryParams := &StorageClassQueryParams{}
 	json.Unmarshal(requestParams.([]byte), queryParams)
 	if queryParams.Name == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Name is blank"}
+		return utils.NewParamsErrorResponse("Name is blank")
 	}
 	sc, err := s.KubeClient.StorageClassInformer().Lister().Get(queryParams.Name)
 	if err != nil {
-		return &utils.Response{Code: code.GetError, Msg: err.Error()}
+		return utils.NewErrorResponse(code.GetError, err)
 	}
 	if queryParams.Output == "yaml" {
 		const mediaType = runtime.ContentTypeYAML
@@ -120,7 +124,7 @@ func (s *StorageClass) Get(requestParams interface{}) *utils.Response {
 		d, e := runtime.Encode(encoder, sc)
 		if e != nil {
 			klog.Error(e)
-			return &utils.Response{Code: code.EncodeError, Msg: e.Error()}
+			return utils.NewErrorResponse(code.EncodeError, e)
 		}
 		return &utils.Response{Code: code.Success, Msg: "Success", Data: string(d)}
 	}
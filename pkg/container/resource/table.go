@@ -0,0 +1,174 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"strings"
+)
+
+const (
+	OutputTable = "table"
+
+	tableAcceptHeader = "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+)
+
+// Table output为table时List返回的数据，列与kubectl get输出的一致，包括CRD的additionalPrinterColumns
+type Table struct {
+	Columns []metav1.TableColumnDefinition `json:"columns"`
+	Rows    []interface{}                  `json:"rows"`
+}
+
+// tableRow Status为Status列的值，用于按status排序
+type tableRow struct {
+	metav1.TableRow
+	Status string `json:"-"`
+}
+
+type tableParams struct {
+	Namespace string `json:"namespace"`
+}
+
+// ListTable 通过api server返回资源的Table，支持ListQuery的过滤、排序及分页
+func (d *DynamicResource) ListTable(ctx context.Context, requestParams interface{}) *utils.Response {
+	return d.listTable(ctx, requestParams, d.GroupVersionResource)
+}
+
+// listTable 多个资源的行合并到同一个Table，列按名称合并，指定namespace时不返回集群级别的资源
+func (d *DynamicResource) listTable(ctx context.Context, requestParams interface{}, gvrs ...*schema.GroupVersionResource) *utils.Response {
+	query, err := parseListQuery(requestParams)
+	if err != nil {
+		return listQueryError(err)
+	}
+	params := &tableParams{}
+	if data, ok := requestParams.([]byte); ok && len(data) > 0 {
+		json.Unmarshal(data, params)
+	}
+	fieldSelector := query.serverFieldSelector()
+	table := &Table{}
+	for _, gvr := range gvrs {
+		mapping, err := d.restMapping(*gvr)
+		if err != nil {
+			return utils.NewParamsErrorResponse(err.Error())
+		}
+		namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		if !namespaced && params.Namespace != "" && len(gvrs) > 1 {
+			continue
+		}
+		t, err := d.requestTable(ctx, mapping, params.Namespace, metav1.ListOptions{
+			LabelSelector: query.LabelSelector.String(),
+			FieldSelector: fieldSelector,
+		})
+		if err != nil {
+			return utils.NewErrorResponse(code.ListError, err)
+		}
+		// 不同资源的列可能不同，按列名对应到合并后的列
+		positions, identical := table.mergeColumns(t.ColumnDefinitions)
+		statusColumn := -1
+		for i, c := range t.ColumnDefinitions {
+			if strings.EqualFold(c.Name, "status") {
+				statusColumn = i
+			}
+		}
+		for _, row := range t.Rows {
+			obj := &metav1.PartialObjectMetadata{}
+			if len(row.Object.Raw) > 0 {
+				json.Unmarshal(row.Object.Raw, obj)
+			} else if len(row.Cells) > 0 {
+				obj.Name = fmt.Sprint(row.Cells[0])
+			}
+			r := &tableRow{TableRow: row}
+			if statusColumn >= 0 && statusColumn < len(row.Cells) {
+				r.Status = fmt.Sprint(row.Cells[statusColumn])
+			}
+			if !identical {
+				r.Cells = make([]interface{}, len(table.Columns))
+				for i, cell := range row.Cells {
+					if i < len(positions) {
+						r.Cells[positions[i]] = cell
+					}
+				}
+			}
+			query.Add(obj, r)
+		}
+	}
+	var listMeta *utils.ListMeta
+	table.Rows, listMeta = query.Result()
+	// 之前合并的资源没有后面新增的列，补齐为空
+	for _, row := range table.Rows {
+		r := row.(*tableRow)
+		for len(r.Cells) < len(table.Columns) {
+			r.Cells = append(r.Cells, nil)
+		}
+	}
+	return &utils.Response{Code: code.Success, Msg: "Success", Data: table, List: listMeta}
+}
+
+// mergeColumns 将资源的列合并到table中，名称相同的列合并为一列，返回每一列在合并后的位置，
+// identical为true时列与合并后的列完全一致，行不需要重新排列
+func (t *Table) mergeColumns(columns []metav1.TableColumnDefinition) ([]int, bool) {
+	if t.Columns == nil {
+		t.Columns = columns
+		return nil, true
+	}
+	positions := make([]int, len(columns))
+	identical := len(columns) == len(t.Columns)
+	for i, c := range columns {
+		positions[i] = -1
+		for j, merged := range t.Columns {
+			if merged.Name == c.Name {
+				positions[i] = j
+				break
+			}
+		}
+		if positions[i] < 0 {
+			positions[i] = len(t.Columns)
+			t.Columns = append(t.Columns, c)
+		}
+		identical = identical && positions[i] == i
+	}
+	return positions, identical
+}
+
+// requestTable 以Table格式list资源，行中包含对象的metadata
+func (d *DynamicResource) requestTable(ctx context.Context, mapping *meta.RESTMapping, namespace string, opts metav1.ListOptions) (*metav1.Table, error) {
+	config := rest.CopyConfig(d.Client(ctx).Config)
+	gv := mapping.Resource.GroupVersion()
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	if gv.Group == "" {
+		config.APIPath = "/api"
+	}
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	restClient, err := rest.RESTClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	req := restClient.Get().
+		Resource(mapping.Resource.Resource).
+		SetHeader("Accept", tableAcceptHeader).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Param("includeObject", string(metav1.IncludeMetadata))
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && namespace != "" {
+		req = req.Namespace(namespace)
+	}
+	data, err := req.Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	table := &metav1.Table{}
+	if err = json.Unmarshal(data, table); err != nil {
+		return nil, err
+	}
+	if table.Kind != "Table" {
+		return nil, fmt.Errorf("server does not support table output for %s", mapping.Resource.String())
+	}
+	return table, nil
+}
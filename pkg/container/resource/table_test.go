@@ -0,0 +1,213 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// tableHandler 返回Table，kinds为各路径返回的kind，默认为Table
+// 每行的名称为路径的最后一段加序号，status依次为Running、Failed、Pending
+func tableHandler(kinds map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind := "Table"
+		if k, ok := kinds[r.URL.Path]; ok {
+			kind = k
+		}
+		resource := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		table := &metav1.Table{
+			TypeMeta: metav1.TypeMeta{Kind: kind, APIVersion: "meta.k8s.io/v1"},
+			ColumnDefinitions: []metav1.TableColumnDefinition{
+				{Name: "Name", Type: "string"},
+				{Name: "Status", Type: "string"},
+			},
+		}
+		for i, status := range []string{"Running", "Failed", "Pending"} {
+			name := fmt.Sprintf("%s-%d", resource, i)
+			obj, _ := json.Marshal(&metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app": "web"}}})
+			table.Rows = append(table.Rows, metav1.TableRow{
+				Cells:  []interface{}{name, status},
+				Object: runtime.RawExtension{Raw: obj},
+			})
+		}
+		json.NewEncoder(w).Encode(table)
+	}
+}
+
+func tableRowNames(data interface{}) []string {
+	var names []string
+	for _, row := range data.(*Table).Rows {
+		names = append(names, fmt.Sprint(row.(*tableRow).Cells[0]))
+	}
+	return names
+}
+
+func TestListTable(t *testing.T) {
+	pods := &schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	namespaces := &schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	clusterRoles := &schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}
+	cases := []struct {
+		name      string
+		params    map[string]interface{}
+		gvrs      []*schema.GroupVersionResource
+		kinds     map[string]string
+		wantCode  string
+		wantPaths []string
+		wantRows  []string
+		wantQuery url.Values
+	}{
+		{
+			name:      "namespaced resource",
+			params:    map[string]interface{}{"namespace": "dev"},
+			gvrs:      []*schema.GroupVersionResource{pods},
+			wantCode:  code.Success,
+			wantPaths: []string{"/api/v1/namespaces/dev/pods"},
+			wantRows:  []string{"pods-0", "pods-1", "pods-2"},
+		},
+		{
+			name: "selectors passed to api server",
+			params: map[string]interface{}{
+				"label_selector": "app=web",
+				"field_selector": "status.phase=Running",
+			},
+			gvrs:      []*schema.GroupVersionResource{pods},
+			wantCode:  code.Success,
+			wantPaths: []string{"/api/v1/pods"},
+			wantRows:  []string{"pods-0", "pods-1", "pods-2"},
+			wantQuery: url.Values{"labelSelector": {"app=web"}, "fieldSelector": {"status.phase=Running"}},
+		},
+		{
+			name:      "sort by status column and paginate",
+			params:    map[string]interface{}{"sort_by": SortByStatus, "limit": 2},
+			gvrs:      []*schema.GroupVersionResource{pods},
+			wantCode:  code.Success,
+			wantPaths: []string{"/api/v1/pods"},
+			wantRows:  []string{"pods-1", "pods-2"},
+		},
+		{
+			name:      "cluster scoped resource ignores namespace",
+			params:    map[string]interface{}{"namespace": "dev"},
+			gvrs:      []*schema.GroupVersionResource{clusterRoles},
+			wantCode:  code.Success,
+			wantPaths: []string{"/apis/rbac.authorization.k8s.io/v1/clusterroles"},
+			wantRows:  []string{"clusterroles-0", "clusterroles-1", "clusterroles-2"},
+		},
+		{
+			name:      "merged resources skip cluster scoped in namespace",
+			params:    map[string]interface{}{"namespace": "dev", "sort_by": SortByName, "limit": 4},
+			gvrs:      []*schema.GroupVersionResource{pods, namespaces, clusterRoles},
+			wantCode:  code.Success,
+			wantPaths: []string{"/api/v1/namespaces/dev/pods"},
+			wantRows:  []string{"pods-0", "pods-1", "pods-2"},
+		},
+		{
+			name:      "merged resources",
+			params:    map[string]interface{}{"sort_by": SortByName, "limit": 4},
+			gvrs:      []*schema.GroupVersionResource{pods, namespaces},
+			wantCode:  code.Success,
+			wantPaths: []string{"/api/v1/pods", "/api/v1/namespaces"},
+			wantRows:  []string{"namespaces-0", "namespaces-1", "namespaces-2", "pods-0"},
+		},
+		{
+			name:      "server without table support",
+			gvrs:      []*schema.GroupVersionResource{pods},
+			kinds:     map[string]string{"/api/v1/pods": "PodList"},
+			wantCode:  code.ListError,
+			wantPaths: []string{"/api/v1/pods"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, s := newTestAPIServer(t, pods, tableHandler(c.kinds))
+			params, _ := json.Marshal(c.params)
+			resp := d.listTable(context.Background(), params, c.gvrs...)
+			if resp.Code != c.wantCode {
+				t.Fatalf("listTable() = %s %s, want %s", resp.Code, resp.Msg, c.wantCode)
+			}
+			var paths []string
+			for _, r := range s.Requests() {
+				paths = append(paths, r.Path)
+				if accept := r.Header.Get("Accept"); accept != tableAcceptHeader {
+					t.Errorf("accept header = %q, want %q", accept, tableAcceptHeader)
+				}
+				query := r.Query
+				if query.Get("includeObject") != string(metav1.IncludeMetadata) {
+					t.Errorf("includeObject = %q, want %q", query.Get("includeObject"), metav1.IncludeMetadata)
+				}
+				for k := range c.wantQuery {
+					if query.Get(k) != c.wantQuery.Get(k) {
+						t.Errorf("query %s = %q, want %q", k, query.Get(k), c.wantQuery.Get(k))
+					}
+				}
+			}
+			if !reflect.DeepEqual(paths, c.wantPaths) {
+				t.Errorf("request paths = %v, want %v", paths, c.wantPaths)
+			}
+			if resp.Code != code.Success {
+				return
+			}
+			if got := tableRowNames(resp.Data); !reflect.DeepEqual(got, c.wantRows) {
+				t.Errorf("rows = %v, want %v", got, c.wantRows)
+			}
+			if columns := resp.Data.(*Table).Columns; len(columns) != 2 || columns[1].Name != "Status" {
+				t.Errorf("columns = %+v", columns)
+			}
+		})
+	}
+}
+
+func TestTableMergeColumns(t *testing.T) {
+	columns := func(names ...string) []metav1.TableColumnDefinition {
+		var c []metav1.TableColumnDefinition
+		for _, name := range names {
+			c = append(c, metav1.TableColumnDefinition{Name: name})
+		}
+		return c
+	}
+	cases := []struct {
+		name          string
+		merged        []string
+		columns       []string
+		wantColumns   []string
+		wantPositions []int
+		wantIdentical bool
+	}{
+		{name: "first table", columns: []string{"Name", "Age"}, wantColumns: []string{"Name", "Age"}, wantIdentical: true},
+		{name: "same columns", merged: []string{"Name", "Age"}, columns: []string{"Name", "Age"}, wantColumns: []string{"Name", "Age"}, wantPositions: []int{0, 1}, wantIdentical: true},
+		{name: "reordered columns", merged: []string{"Name", "Age"}, columns: []string{"Age", "Name"}, wantColumns: []string{"Name", "Age"}, wantPositions: []int{1, 0}},
+		{name: "new column", merged: []string{"Name", "Age"}, columns: []string{"Name", "Role", "Age"}, wantColumns: []string{"Name", "Age", "Role"}, wantPositions: []int{0, 2, 1}},
+		{name: "fewer columns", merged: []string{"Name", "Role", "Age"}, columns: []string{"Name", "Age"}, wantColumns: []string{"Name", "Role", "Age"}, wantPositions: []int{0, 2}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			table := &Table{}
+			if c.merged != nil {
+				table.Columns = columns(c.merged...)
+			}
+			positions, identical := table.mergeColumns(columns(c.columns...))
+			var names []string
+			for _, column := range table.Columns {
+				names = append(names, column.Name)
+			}
+			if !reflect.DeepEqual(names, c.wantColumns) {
+				t.Errorf("columns = %v, want %v", names, c.wantColumns)
+			}
+			if c.wantPositions != nil && !reflect.DeepEqual(positions, c.wantPositions) {
+				t.Errorf("positions = %v, want %v", positions, c.wantPositions)
+			}
+			if identical != c.wantIdentical {
+				t.Errorf("identical = %v, want %v", identical, c.wantIdentical)
+			}
+		})
+	}
+}
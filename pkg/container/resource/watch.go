@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/kubespace/agent/pkg/utils"
 	"github.com/kubespace/agent/pkg/utils/code"
@@ -27,14 +28,14 @@ type WatchParams struct {
 	Action string `json:"action"`
 }
 
-func (w *WatchResource) WatchAction(requestParams interface{}) *utils.Response {
+func (w *WatchResource) WatchAction(ctx context.Context, requestParams interface{}) *utils.Response {
 	params := &WatchParams{}
 	json.Unmarshal(requestParams.([]byte), params)
 	if params.Action == "" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Action param is blank"}
+		return utils.NewParamsErrorResponse("Action param is blank")
 	}
 	if params.Action != "close" && params.Action != "open" {
-		return &utils.Response{Code: code.ParamsError, Msg: "Action param is not valid"}
+		return utils.NewParamsErrorResponse("Action param is not valid")
 	}
 	klog.Infof("watch resource %s", params.Action)
 
@@ -1,11 +1,14 @@
 package container
 
 import (
+	"context"
 	"github.com/kubespace/agent/pkg/container/resource"
 	"github.com/kubespace/agent/pkg/kubernetes"
 	"github.com/kubespace/agent/pkg/ospserver"
+	"github.com/kubespace/agent/pkg/policy"
 	"github.com/kubespace/agent/pkg/utils"
 	"github.com/kubespace/agent/pkg/websocket"
+	"sort"
 )
 
 const (
@@ -23,21 +26,27 @@ const (
 	APPLY      = "apply"
 	STATUS     = "status"
 	LISTOBJS   = "list_objects"
+	DIFF       = "diff"
+	UPDATE     = "update"
+	PATCH      = "patch"
+	WATCH      = "watch"
 )
 
-type Handler func(interface{}) *utils.Response
+type Handler func(context.Context, interface{}) *utils.Response
 
 type ActionHandler map[string]Handler
 
 type ResourceActions struct {
 	KubeClient            *kubernetes.KubeClient
 	ResourceActionHandler map[string]ActionHandler
+	middlewares           []Middleware
 }
 
 func NewResourceActions(
 	kubeClient *kubernetes.KubeClient,
 	sendResponse websocket.SendResponse,
-	ospServer *ospserver.OspServer) *ResourceActions {
+	ospServer *ospserver.OspServer,
+	agentPolicy *policy.Policy) *ResourceActions {
 	actionHandlers := make(map[string]ActionHandler)
 
 	watch := resource.NewWatchResource(sendResponse)
@@ -46,12 +55,13 @@ func NewResourceActions(
 	}
 	actionHandlers["watch"] = watchActions
 
-	cluster := resource.NewCluster(kubeClient, watch)
+	cluster := resource.NewCluster(kubeClient, watch, agentPolicy)
 	clusterActions := ActionHandler{
 		GET:       cluster.Get,
 		APPLY:     cluster.ApplyYaml,
 		CREATE:    cluster.CreateYaml,
 		UPDATEGVR: cluster.UpdateGVRYaml,
+		DIFF:      cluster.DiffYaml,
 	}
 	actionHandlers["cluster"] = clusterActions
 
@@ -65,6 +75,7 @@ func NewResourceActions(
 		CLOSELOG:   pod.CloseLog,
 		DELETE:     pod.Delete,
 		UPDATEYAML: pod.UpdateYaml,
+		PATCH:      pod.Patch,
 	}
 	actionHandlers["pod"] = podActions
 
@@ -74,6 +85,7 @@ func NewResourceActions(
 		GET:        ns.Get,
 		DELETE:     ns.Delete,
 		UPDATEYAML: ns.UpdateYaml,
+		PATCH:      ns.Patch,
 	}
 	actionHandlers["namespace"] = nsActions
 
@@ -82,12 +94,14 @@ func NewResourceActions(
 		LIST:       node.List,
 		GET:        node.Get,
 		UPDATEYAML: node.UpdateYaml,
+		PATCH:      node.Patch,
 	}
 	actionHandlers["node"] = nodeActions
 
 	event := resource.NewEvent(kubeClient, watch)
 	eventActions := ActionHandler{
-		LIST: event.List,
+		LIST:  event.List,
+		PATCH: event.Patch,
 	}
 	actionHandlers["event"] = eventActions
 
@@ -98,6 +112,7 @@ func NewResourceActions(
 		DELETE:     deployment.Delete,
 		UPDATEYAML: deployment.UpdateYaml,
 		UPDATEOBJ:  deployment.UpdateObj,
+		PATCH:      deployment.Patch,
 	}
 	actionHandlers["deployment"] = deploymentActions
 
@@ -108,6 +123,7 @@ func NewResourceActions(
 		DELETE:     statefulset.Delete,
 		UPDATEYAML: statefulset.UpdateYaml,
 		UPDATEOBJ:  statefulset.UpdateObj,
+		PATCH:      statefulset.Patch,
 	}
 	actionHandlers["statefulset"] = statefulsetActions
 
@@ -118,6 +134,7 @@ func NewResourceActions(
 		DELETE:     daemonset.Delete,
 		UPDATEYAML: daemonset.UpdateYaml,
 		UPDATEOBJ:  daemonset.UpdateObj,
+		PATCH:      daemonset.Patch,
 	}
 	actionHandlers["daemonset"] = daemonsetActions
 
@@ -128,6 +145,7 @@ func NewResourceActions(
 		DELETE:     job.Delete,
 		UPDATEYAML: job.UpdateYaml,
 		UPDATEOBJ:  job.UpdateObj,
+		PATCH:      job.Patch,
 	}
 	actionHandlers["job"] = jobActions
 
@@ -138,6 +156,7 @@ func NewResourceActions(
 		DELETE:     cronjob.Delete,
 		UPDATEYAML: cronjob.UpdateYaml,
 		UPDATEOBJ:  cronjob.UpdateObj,
+		PATCH:      cronjob.Patch,
 	}
 	actionHandlers["cronjob"] = cronjobActions
 
@@ -149,6 +168,7 @@ func NewResourceActions(
 		//CREATE: 	configMap.Create,
 		DELETE:   configMap.Delete,
 		LISTOBJS: configMap.ListObjects,
+		PATCH:    configMap.Patch,
 	}
 	actionHandlers["configMap"] = configMapActions
 
@@ -158,6 +178,7 @@ func NewResourceActions(
 		GET:        persistentVolume.Get,
 		UPDATEYAML: persistentVolume.UpdateYaml,
 		DELETE:     persistentVolume.Delete,
+		PATCH:      persistentVolume.Patch,
 	}
 	actionHandlers["persistentVolume"] = persistentVolumeActions
 
@@ -168,6 +189,7 @@ func NewResourceActions(
 		UPDATEYAML: persistentVolumeClaim.UpdateYaml,
 		DELETE:     persistentVolumeClaim.Delete,
 		LISTOBJS:   persistentVolumeClaim.ListObjects,
+		PATCH:      persistentVolumeClaim.Patch,
 	}
 	actionHandlers["persistentVolumeClaim"] = persistentVolumeClaimActions
 
@@ -177,6 +199,7 @@ func NewResourceActions(
 		GET:        storageClass.Get,
 		UPDATEYAML: storageClass.UpdateYaml,
 		DELETE:     storageClass.Delete,
+		PATCH:      storageClass.Patch,
 	}
 	actionHandlers["storageClass"] = storageClassActions
 
@@ -186,6 +209,7 @@ func NewResourceActions(
 		GET:        hpa.Get,
 		UPDATEYAML: hpa.UpdateYaml,
 		DELETE:     hpa.Delete,
+		PATCH:      hpa.Patch,
 	}
 	actionHandlers["horizontalPodAutoscaler"] = hpaActions
 
@@ -195,6 +219,7 @@ func NewResourceActions(
 		GET:        service.Get,
 		UPDATEYAML: service.UpdateYaml,
 		DELETE:     service.Delete,
+		PATCH:      service.Patch,
 	}
 	actionHandlers["service"] = serviceActions
 
@@ -204,6 +229,7 @@ func NewResourceActions(
 		GET:        ingress.Get,
 		UPDATEYAML: ingress.UpdateYaml,
 		DELETE:     ingress.Delete,
+		PATCH:      ingress.Patch,
 	}
 	actionHandlers["ingress"] = ingressActions
 
@@ -213,6 +239,7 @@ func NewResourceActions(
 		GET:        endpoints.Get,
 		UPDATEYAML: endpoints.UpdateYaml,
 		DELETE:     endpoints.Delete,
+		PATCH:      endpoints.Patch,
 	}
 	actionHandlers["endpoints"] = endpointsActions
 
@@ -222,6 +249,7 @@ func NewResourceActions(
 		GET:        networkpolicy.Get,
 		UPDATEYAML: networkpolicy.UpdateYaml,
 		DELETE:     networkpolicy.Delete,
+		PATCH:      networkpolicy.Patch,
 	}
 	actionHandlers["networkpolicy"] = networkpolicyActions
 
@@ -231,6 +259,7 @@ func NewResourceActions(
 		GET:        serviceaccount.Get,
 		UPDATEYAML: serviceaccount.UpdateYaml,
 		DELETE:     serviceaccount.Delete,
+		PATCH:      serviceaccount.Patch,
 	}
 	actionHandlers["serviceaccount"] = serviceaccountActions
 
@@ -239,29 +268,50 @@ func NewResourceActions(
 		LIST:       rolebinding.List,
 		GET:        rolebinding.Get,
 		UPDATEYAML: rolebinding.UpdateYaml,
+		PATCH:      rolebinding.Patch,
 		//DELETE:     rolebinding.Delete,
 	}
 	actionHandlers["rolebinding"] = rolebindingActions
 
 	role := resource.NewRole(kubeClient, watch)
 	roleActions := ActionHandler{
-		LIST: role.List,
-		GET:  role.Get,
+		LIST:  role.List,
+		GET:   role.Get,
+		PATCH: role.Patch,
 		//UPDATEYAML: rolebinding.UpdateYaml,
 		//DELETE:     rolebinding.Delete,
 	}
 	actionHandlers["role"] = roleActions
 
-	secret := resource.NewSecret(kubeClient, watch)
+	secret := resource.NewSecret(kubeClient, watch, agentPolicy.DenySecretReveal)
 	secretActions := ActionHandler{
 		LIST:       secret.List,
 		GET:        secret.Get,
 		UPDATEYAML: secret.UpdateYaml,
 		DELETE:     secret.Delete,
 		LISTOBJS:   secret.ListObjects,
+		PATCH:      secret.Patch,
 	}
 	actionHandlers["secret"] = secretActions
 
+	generic := resource.NewGenericResource(kubeClient, sendResponse, agentPolicy.DenySecretReveal)
+	genericActions := ActionHandler{
+		LIST:   generic.List,
+		GET:    generic.Get,
+		CREATE: generic.Create,
+		UPDATE: generic.Update,
+		PATCH:  generic.Patch,
+		DELETE: generic.Delete,
+		WATCH:  generic.Watch,
+	}
+	actionHandlers["resource"] = genericActions
+
+	discovery := resource.NewDiscovery(kubeClient)
+	discoveryActions := ActionHandler{
+		GET: discovery.Get,
+	}
+	actionHandlers["discovery"] = discoveryActions
+
 	helm := resource.NewHelm(pod, kubeClient, watch, ospServer)
 	helmActions := ActionHandler{
 		LIST:      helm.List,
@@ -275,8 +325,9 @@ func NewResourceActions(
 
 	crd := resource.NewCrd(kubeClient)
 	crdActions := ActionHandler{
-		LIST: crd.List,
-		GET:  crd.Get,
+		LIST:  crd.List,
+		GET:   crd.Get,
+		PATCH: crd.Patch,
 	}
 	actionHandlers["crd"] = crdActions
 
@@ -285,6 +336,7 @@ func NewResourceActions(
 		LIST:   cr.ListCR,
 		GET:    cr.GetCR,
 		DELETE: cr.DeleteCrs,
+		PATCH:  cr.PatchCR,
 	}
 	actionHandlers["cr"] = crActions
 
@@ -294,6 +346,29 @@ func NewResourceActions(
 	}
 }
 
+// Capabilities 返回所有注册的resource及其action，用于连接server时的handshake
+func (r *ResourceActions) Capabilities() map[string][]string {
+	capabilities := make(map[string][]string)
+	for resource, actions := range r.ResourceActionHandler {
+		var actionNames []string
+		for action := range actions {
+			actionNames = append(actionNames, action)
+		}
+		sort.Strings(actionNames)
+		capabilities[resource] = actionNames
+	}
+	return capabilities
+}
+
+// Use 添加作用于所有resource handler的中间件，先添加的在外层
+func (r *ResourceActions) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
 func (r *ResourceActions) GetRequestHandler(resource string, action string) Handler {
-	return r.ResourceActionHandler[resource][action]
+	handler := r.ResourceActionHandler[resource][action]
+	if handler == nil {
+		return nil
+	}
+	return Chain(handler, r.middlewares...)
 }
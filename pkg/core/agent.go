@@ -1,15 +1,19 @@
 package core
 
 import (
+	"crypto/tls"
+	"fmt"
 	"github.com/kubespace/agent/pkg/config"
 	"github.com/kubespace/agent/pkg/container"
 	"github.com/kubespace/agent/pkg/container/resource"
 	"github.com/kubespace/agent/pkg/kubernetes"
 	"github.com/kubespace/agent/pkg/ospserver"
+	"github.com/kubespace/agent/pkg/policy"
 	"github.com/kubespace/agent/pkg/utils"
 	"github.com/kubespace/agent/pkg/websocket"
 	"k8s.io/klog"
 	"net/url"
+	"strings"
 )
 
 type AgentConfig struct {
@@ -24,13 +28,55 @@ func NewAgentConfig(opt *config.AgentOptions) (*AgentConfig, error) {
 	agentConfig := &AgentConfig{
 		AgentOptions: opt,
 		RequestChan:  make(chan *utils.Request),
-		ResponseChan: make(chan *utils.TResponse),
+		ResponseChan: make(chan *utils.TResponse, 1000),
 	}
 
-	serverUrl := &url.URL{Scheme: "ws", Host: opt.ServerUrl, Path: "/api/v1/kube/connect"}
-	serverRespUrl := &url.URL{Scheme: "ws", Host: opt.ServerUrl, Path: "/api/v1/kube/response"}
-	serverHttpsUrl := &url.URL{Scheme: "http", Host: opt.ServerUrl}
-	ospServer, err := ospserver.NewOspServer(serverHttpsUrl)
+	wsScheme, httpScheme := "ws", "http"
+	var tlsConfig *tls.Config
+	if opt.ServerTLS {
+		wsScheme, httpScheme = "wss", "https"
+		var pinnedKeys []string
+		if opt.ServerPinnedKeys != "" {
+			pinnedKeys = strings.Split(opt.ServerPinnedKeys, ",")
+		}
+		var err error
+		tlsConfig, err = utils.NewTLSConfig(&utils.TLSOptions{
+			CAFile:             opt.ServerCAFile,
+			CertFile:           opt.ClientCertFile,
+			KeyFile:            opt.ClientKeyFile,
+			PinnedPublicKeys:   pinnedKeys,
+			InsecureSkipVerify: opt.InsecureSkipVerify,
+		})
+		if err != nil {
+			klog.Errorf("new tls config error: %v", err)
+			return nil, err
+		}
+	} else if opt.ServerCAFile != "" || opt.ClientCertFile != "" || opt.ClientKeyFile != "" ||
+		opt.ServerPinnedKeys != "" || opt.InsecureSkipVerify {
+		// 未启用tls时这些配置不会生效，直接报错避免误以为连接已加密或已校验
+		err := fmt.Errorf("server tls options (server-ca-file, client-cert-file, client-key-file, " +
+			"server-pinned-keys, insecure-skip-verify) require server-tls to be enabled")
+		klog.Error(err)
+		return nil, err
+	}
+	endpoints, err := websocket.NewEndpoints(opt.ServerUrl)
+	if err != nil {
+		klog.Errorf("resolve server endpoints error: %v", err)
+		return nil, err
+	}
+	serverUrl := &url.URL{Scheme: wsScheme, Host: endpoints.Primary(), Path: "/api/v1/kube/connect"}
+	serverHttpsUrl := &url.URL{Scheme: httpScheme, Host: endpoints.Primary()}
+	proxy, err := utils.NewProxyFunc(&utils.ProxyOptions{
+		ProxyUrl: opt.ProxyUrl,
+		NoProxy:  opt.NoProxy,
+		Username: opt.ProxyUsername,
+		Password: opt.ProxyPassword,
+	})
+	if err != nil {
+		klog.Errorf("new proxy config error: %v", err)
+		return nil, err
+	}
+	ospServer, err := ospserver.NewOspServer(serverHttpsUrl, tlsConfig, proxy)
 	if err != nil {
 		klog.Errorf("new ospserver error: %v", err)
 		return nil, err
@@ -44,20 +90,66 @@ func NewAgentConfig(opt *config.AgentOptions) (*AgentConfig, error) {
 	}
 	agentConfig.WebSocket = websocket.NewWebSocket(
 		serverUrl,
+		endpoints,
 		opt.AgentToken,
 		agentConfig.RequestChan,
 		agentConfig.ResponseChan,
-		serverRespUrl,
 		ospServer,
 		dynamicResource,
-		updateAgent)
+		updateAgent,
+		tlsConfig,
+		proxy,
+		&websocket.KeepaliveOptions{
+			PingInterval:        opt.PingInterval,
+			PongTimeout:         opt.PongTimeout,
+			ReconnectMinBackoff: opt.ReconnectMinBackoff,
+			ReconnectMaxBackoff: opt.ReconnectMaxBackoff,
+		},
+		&websocket.OutboxOptions{
+			MaxMessages: opt.OutboxMaxMessages,
+			MaxBytes:    opt.OutboxMaxBytes,
+			MaxAge:      opt.OutboxMaxAge,
+			Dir:         opt.OutboxDir,
+		})
+	agentConfig.WebSocket.Compression = opt.Compression
+	agentConfig.WebSocket.CompactEncoding = opt.CompactEncoding
+	if opt.MaxMessageSize > 0 {
+		agentConfig.WebSocket.MaxMessageSize = opt.MaxMessageSize
+	}
+	if opt.PrimaryProbeInterval > 0 {
+		agentConfig.WebSocket.PrimaryProbeInterval = opt.PrimaryProbeInterval
+	}
+	resourceLimits, err := container.ParseResourceLimits(opt.ResourceConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	agentPolicy, err := policy.Load(opt.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	if opt.ReadOnly {
+		agentPolicy.ReadOnly = true
+	}
 	agentConfig.Container = container.NewContainer(
 		//nil,
 		kubeClient,
 		agentConfig.RequestChan,
 		agentConfig.ResponseChan,
 		agentConfig.WebSocket.SendResponse,
-		ospServer)
+		ospServer,
+		&container.PoolOptions{
+			Workers:              opt.Workers,
+			InteractiveWorkers:   opt.InteractiveWorkers,
+			ResourceLimits:       resourceLimits,
+			DefaultResourceLimit: opt.DefaultResourceConcurrency,
+			StatsInterval:        opt.StatsInterval,
+			MaxQueued:            opt.MaxQueuedRequests,
+		},
+		agentPolicy)
+	if kubeClient.Version != nil {
+		agentConfig.WebSocket.KubernetesVersion = kubeClient.Version.GitVersion
+	}
+	agentConfig.WebSocket.Capabilities = agentConfig.Container.Capabilities
 
 	return agentConfig, nil
 }
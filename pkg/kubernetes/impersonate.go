@@ -0,0 +1,159 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiExtensionsClientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 缓存的模拟用户客户端数量上限，超过后清空重建
+	maxImpersonatedClients = 256
+	// 鉴权结果缓存时间
+	accessReviewTTL = 30 * time.Second
+)
+
+type clientKey struct{}
+
+// WithClient 将请求使用的客户端放入context
+func WithClient(ctx context.Context, client *KubeClient) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// Client 返回context中请求使用的客户端，没有时为agent自身的客户端
+func (k *KubeClient) Client(ctx context.Context) *KubeClient {
+	if client, ok := ctx.Value(clientKey{}).(*KubeClient); ok && client != nil {
+		return client
+	}
+	return k
+}
+
+type impersonation struct {
+	mutex   sync.Mutex
+	clients map[string]*KubeClient
+	reviews map[string]accessReview
+}
+
+type accessReview struct {
+	allowed bool
+	reason  string
+	expire  time.Time
+}
+
+func impersonationKey(user string, groups []string) string {
+	sorted := append([]string{}, groups...)
+	sort.Strings(sorted)
+	return user + "\x00" + strings.Join(sorted, "\x00")
+}
+
+// ForUser 返回以user、groups身份访问apiserver的客户端，apiserver按该用户的RBAC鉴权并记录审计日志。
+// informer缓存是agent共享的，模拟用户的客户端同样使用，读取缓存前需通过Authorize鉴权
+func (k *KubeClient) ForUser(user string, groups []string) (*KubeClient, error) {
+	if user == "" {
+		return k, nil
+	}
+	key := impersonationKey(user, groups)
+	k.impersonation.mutex.Lock()
+	defer k.impersonation.mutex.Unlock()
+	if client, ok := k.impersonation.clients[key]; ok {
+		return client, nil
+	}
+
+	config := rest.CopyConfig(k.Config)
+	config.Impersonate = rest.ImpersonationConfig{UserName: user, Groups: groups}
+	clientSet, err := kube_client.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	apiExtensions, err := apiExtensionsClientset.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	client := &KubeClient{
+		KubeConfigFile:         k.KubeConfigFile,
+		ClientSet:              clientSet,
+		DynamicClient:          dynamicClient,
+		Config:                 config,
+		InformerRegistry:       k.InformerRegistry,
+		DiscoveryClient:        dc,
+		ApiExtensionsClientSet: apiExtensions,
+		Version:                k.Version,
+		User:                   user,
+		Groups:                 groups,
+		impersonation:          k.impersonation,
+	}
+	if len(k.impersonation.clients) >= maxImpersonatedClients {
+		k.impersonation.clients = make(map[string]*KubeClient)
+	}
+	k.impersonation.clients[key] = client
+	return client, nil
+}
+
+// Authorize 通过SelfSubjectAccessReview检查模拟的用户是否有权限，agent自身的客户端不检查
+func (k *KubeClient) Authorize(ctx context.Context, attrs *authorizationv1.ResourceAttributes) error {
+	if k.User == "" {
+		return nil
+	}
+	key := fmt.Sprintf("%s\x00%s/%s/%s/%s/%s/%s", impersonationKey(k.User, k.Groups),
+		attrs.Verb, attrs.Group, attrs.Resource, attrs.Subresource, attrs.Namespace, attrs.Name)
+	k.impersonation.mutex.Lock()
+	review, ok := k.impersonation.reviews[key]
+	k.impersonation.mutex.Unlock()
+	if !ok || time.Now().After(review.expire) {
+		ssar := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attrs},
+		}
+		res, err := k.ClientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, ssar, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		review = accessReview{allowed: res.Status.Allowed, reason: res.Status.Reason, expire: time.Now().Add(accessReviewTTL)}
+		k.impersonation.mutex.Lock()
+		if len(k.impersonation.reviews) >= maxImpersonatedClients*16 {
+			k.impersonation.reviews = make(map[string]accessReview)
+		}
+		k.impersonation.reviews[key] = review
+		k.impersonation.mutex.Unlock()
+	}
+	if !review.allowed {
+		resource := attrs.Resource
+		if attrs.Group != "" {
+			resource = attrs.Resource + "." + attrs.Group
+		}
+		msg := fmt.Sprintf("user %q cannot %s resource %q", k.User, attrs.Verb, resource)
+		if attrs.Namespace != "" {
+			msg += fmt.Sprintf(" in namespace %q", attrs.Namespace)
+		}
+		if review.reason != "" {
+			msg += ": " + review.reason
+		}
+		return &ForbiddenError{Msg: msg}
+	}
+	return nil
+}
+
+type ForbiddenError struct {
+	Msg string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Msg
+}
@@ -0,0 +1,163 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestKubeClient() *KubeClient {
+	return &KubeClient{
+		ClientSet: fake.NewSimpleClientset(),
+		Config:    &rest.Config{Host: "https://127.0.0.1:6443"},
+		impersonation: &impersonation{
+			clients: make(map[string]*KubeClient),
+			reviews: make(map[string]accessReview),
+		},
+	}
+}
+
+func TestForUser(t *testing.T) {
+	cases := []struct {
+		name    string
+		user    string
+		groups  []string
+		other   string
+		others  []string
+		wantNew bool
+	}{
+		{name: "same user and groups", user: "alice", groups: []string{"dev"}, other: "alice", others: []string{"dev"}},
+		{name: "groups in different order", user: "alice", groups: []string{"dev", "ops"}, other: "alice", others: []string{"ops", "dev"}},
+		{name: "different groups", user: "alice", groups: []string{"dev"}, other: "alice", others: []string{"ops"}, wantNew: true},
+		{name: "different user", user: "alice", other: "bob", wantNew: true},
+		{name: "user group separator", user: "alice", groups: []string{"dev"}, other: "alice\x00dev", wantNew: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := newTestKubeClient()
+			client, err := k.ForUser(c.user, c.groups)
+			if err != nil {
+				t.Fatalf("ForUser() error = %v", err)
+			}
+			if client.User != c.user || client.Config.Impersonate.UserName != c.user {
+				t.Errorf("impersonated user = %q, config %q, want %q", client.User, client.Config.Impersonate.UserName, c.user)
+			}
+			if fmt.Sprint(client.Config.Impersonate.Groups) != fmt.Sprint(c.groups) {
+				t.Errorf("impersonated groups = %v, want %v", client.Config.Impersonate.Groups, c.groups)
+			}
+			if k.Config.Impersonate.UserName != "" {
+				t.Error("agent client config is modified")
+			}
+			other, err := k.ForUser(c.other, c.others)
+			if err != nil {
+				t.Fatalf("ForUser() error = %v", err)
+			}
+			if (other != client) != c.wantNew {
+				t.Errorf("new client = %v, want %v", other != client, c.wantNew)
+			}
+		})
+	}
+}
+
+func TestForUserAgentClient(t *testing.T) {
+	k := newTestKubeClient()
+	client, err := k.ForUser("", []string{"dev"})
+	if err != nil || client != k {
+		t.Errorf("ForUser() without user = %p, %v, want agent client", client, err)
+	}
+}
+
+func TestForUserCacheLimit(t *testing.T) {
+	k := newTestKubeClient()
+	for i := 0; i < maxImpersonatedClients; i++ {
+		if _, err := k.ForUser(fmt.Sprintf("user-%d", i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := k.ForUser("overflow", nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(k.impersonation.clients); n != 1 {
+		t.Errorf("cached clients = %d after overflow, want 1", n)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	cases := []struct {
+		name    string
+		user    string
+		allowed bool
+		reason  string
+		attrs   *authorizationv1.ResourceAttributes
+		// wantErr 期望的错误信息，为空时允许访问
+		wantErr    string
+		wantReview bool
+	}{
+		{
+			name:  "agent client not checked",
+			attrs: &authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"},
+		},
+		{
+			name:       "allowed",
+			user:       "alice",
+			allowed:    true,
+			attrs:      &authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods", Namespace: "dev"},
+			wantReview: true,
+		},
+		{
+			name:       "denied in namespace",
+			user:       "alice",
+			reason:     "no rolebinding",
+			attrs:      &authorizationv1.ResourceAttributes{Verb: "get", Group: "apps", Resource: "deployments", Namespace: "dev", Name: "web"},
+			wantErr:    `user "alice" cannot get resource "deployments.apps" in namespace "dev": no rolebinding`,
+			wantReview: true,
+		},
+		{
+			name:       "denied cluster scope",
+			user:       "alice",
+			attrs:      &authorizationv1.ResourceAttributes{Verb: "list", Resource: "nodes"},
+			wantErr:    `user "alice" cannot list resource "nodes"`,
+			wantReview: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := newTestKubeClient()
+			reviews := 0
+			k.ClientSet.(*fake.Clientset).PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				reviews += 1
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				if *review.Spec.ResourceAttributes != *c.attrs {
+					t.Errorf("review attributes = %+v, want %+v", review.Spec.ResourceAttributes, c.attrs)
+				}
+				review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: c.allowed, Reason: c.reason}
+				return true, review, nil
+			})
+			client := k
+			if c.user != "" {
+				client = &KubeClient{ClientSet: k.ClientSet, User: c.user, impersonation: k.impersonation}
+			}
+			// 第二次检查使用缓存的结果
+			for i := 0; i < 2; i++ {
+				err := client.Authorize(context.Background(), c.attrs)
+				if c.wantErr == "" && err != nil {
+					t.Fatalf("Authorize() error = %v", err)
+				}
+				if c.wantErr != "" {
+					if _, ok := err.(*ForbiddenError); !ok || err.Error() != c.wantErr {
+						t.Fatalf("Authorize() error = %v, want ForbiddenError %q", err, c.wantErr)
+					}
+				}
+			}
+			if (reviews == 1) != c.wantReview || reviews > 1 {
+				t.Errorf("access reviews = %d, want review %v", reviews, c.wantReview)
+			}
+		})
+	}
+}
@@ -22,6 +22,10 @@ type KubeClient struct {
 	*discovery.DiscoveryClient
 	ApiExtensionsClientSet apiExtensionsClientset.Interface
 	Version                *version.Info
+	// User、Groups 模拟的用户，为空时为agent自身的客户端
+	User          string
+	Groups        []string
+	impersonation *impersonation
 }
 
 func getKubeConfig(kubeConfigFile string) *rest.Config {
@@ -71,6 +75,10 @@ func NewKubeClient(kubeConfigFile string) *KubeClient {
 		DiscoveryClient:        dc,
 		ApiExtensionsClientSet: apiExtensions,
 		Version:                ver,
+		impersonation: &impersonation{
+			clients: make(map[string]*KubeClient),
+			reviews: make(map[string]accessReview),
+		},
 	}
 }
 
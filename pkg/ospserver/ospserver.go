@@ -2,29 +2,51 @@ package ospserver
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"github.com/kubespace/agent/pkg/utils"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"net/url"
+	"sync"
 )
 
 type OspServer struct {
-	Url        *url.URL
+	// serverUrl 会在websocket重连协程中切换，读写都需要加锁
+	mutex      sync.RWMutex
+	serverUrl  *url.URL
 	httpClient *utils.HttpClient
 }
 
-func NewOspServer(url *url.URL) (*OspServer, error) {
-	client, err := utils.NewHttpClient(url.String())
+func NewOspServer(url *url.URL, tlsConfig *tls.Config, proxy utils.ProxyFunc) (*OspServer, error) {
+	client, err := utils.NewHttpClient(url.String(), tlsConfig, proxy)
 	if err != nil {
 		return nil, err
 	}
 	return &OspServer{
-		Url:        url,
+		serverUrl:  url,
 		httpClient: client,
 	}, nil
 }
 
+// SetHost server地址failover后切换请求的地址
+func (o *OspServer) SetHost(host string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	u := *o.serverUrl
+	u.Host = host
+	o.serverUrl = &u
+	o.httpClient.SetBaseUrl(u.String())
+}
+
+// Url 返回当前请求的server地址
+func (o *OspServer) Url() *url.URL {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	u := *o.serverUrl
+	return &u
+}
+
 func (o *OspServer) GetAppChart(chartPath string) (*chart.Chart, error) {
 	ret, err := o.httpClient.Get(fmt.Sprintf("/app/charts/%s", chartPath), nil)
 	if err != nil {
@@ -0,0 +1,55 @@
+package ospserver
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestSetHost(t *testing.T) {
+	u, _ := url.Parse("https://primary:443/api")
+	o, err := NewOspServer(u, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		host string
+		want string
+	}{
+		{name: "failover", host: "backup:8443", want: "https://backup:8443/api"},
+		{name: "switch back", host: "primary:443", want: "https://primary:443/api"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o.SetHost(c.host)
+			if got := o.Url().String(); got != c.want {
+				t.Errorf("Url() = %s, want %s", got, c.want)
+			}
+		})
+	}
+	if u.Host != "primary:443" {
+		t.Errorf("SetHost modified the original url: %s", u.String())
+	}
+}
+
+func TestSetHostConcurrent(t *testing.T) {
+	u, _ := url.Parse("https://primary:443")
+	o, err := NewOspServer(u, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			o.SetHost("backup:443")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = o.Url().Host
+		}()
+	}
+	wg.Wait()
+}
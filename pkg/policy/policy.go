@@ -0,0 +1,284 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"path"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Policy agent本地的访问策略，即使server被攻破，集群所有者仍能限制agent可执行的操作
+type Policy struct {
+	// ReadOnly 只允许查询操作
+	ReadOnly bool `json:"readOnly"`
+	// Namespaces 允许访问的namespace
+	Namespaces NamespacePolicy `json:"namespaces"`
+	// Rules 按顺序匹配resource及action，第一个匹配的规则生效
+	Rules []Rule `json:"rules"`
+	// DefaultEffect 没有规则匹配时的结果，默认为allow
+	DefaultEffect string `json:"defaultEffect"`
+	// DenyExec 禁止进入pod容器执行命令
+	DenyExec bool `json:"denyExec"`
+	// DenySecretReveal 查询secret时不返回data
+	DenySecretReveal bool `json:"denySecretReveal"`
+}
+
+type NamespacePolicy struct {
+	// Allow、Deny namespace匹配模式，支持通配符，如 team-*
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+	// AllowClusterWide 配置了namespace限制时，是否允许不指定namespace的请求，如集群级别资源或跨namespace查询
+	AllowClusterWide bool `json:"allowClusterWide"`
+}
+
+type Rule struct {
+	Resources []string `json:"resources"`
+	Actions   []string `json:"actions"`
+	// Namespaces 为空时匹配所有namespace
+	Namespaces []string `json:"namespaces"`
+	Effect     string   `json:"effect"`
+}
+
+// readActions 只读的action，只读模式下只允许这些action
+var readActions = map[string]bool{
+	"list":         true,
+	"get":          true,
+	"status":       true,
+	"list_objects": true,
+	"openLog":      true,
+	"closeLog":     true,
+	"watch":        true,
+	// diff通过dry run计算差异，不修改集群
+	"diff": true,
+}
+
+// dryRunActions 将dry_run转换为apiserver DryRunAll的action，只读模式下只有这些action的dry_run请求被允许。
+// exec、stdin及update_obj会忽略dry_run，不能放入该列表
+var dryRunActions = map[string]bool{
+	"create":      true,
+	"update":      true,
+	"update_yaml": true,
+	"update_gvr":  true,
+	"apply":       true,
+	"patch":       true,
+	"delete":      true,
+}
+
+// Load 从yaml或json文件加载策略，path为空时不做任何限制
+func Load(file string) (*Policy, error) {
+	p := &Policy{}
+	if file == "" {
+		return p, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("parse policy file %s error: %v", file, err)
+	}
+	if err = p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", file, err)
+	}
+	return p, nil
+}
+
+func (p *Policy) validate() error {
+	patterns := append(append([]string{}, p.Namespaces.Allow...), p.Namespaces.Deny...)
+	for i, rule := range p.Rules {
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return fmt.Errorf("rule %d effect must be allow or deny", i)
+		}
+		patterns = append(patterns, rule.Resources...)
+		patterns = append(patterns, rule.Actions...)
+		patterns = append(patterns, rule.Namespaces...)
+	}
+	if p.DefaultEffect != "" && p.DefaultEffect != EffectAllow && p.DefaultEffect != EffectDeny {
+		return fmt.Errorf("defaultEffect must be allow or deny")
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// IsReadAction 判断action是否只读
+func IsReadAction(action string) bool {
+	return readActions[action]
+}
+
+// IsDryRunAction 判断请求设置dry_run时是否由apiserver执行dry run而不修改集群，
+// helm在agent本地渲染及安装chart，不在其中
+func IsDryRunAction(resource, action string) bool {
+	return resource != "helm" && dryRunActions[action]
+}
+
+// Check 检查请求是否被允许，namespaces为请求涉及的所有namespace，空字符串表示不限定namespace。
+// 由apiserver执行的dry_run请求不会修改集群，只读模式下也允许
+func (p *Policy) Check(resource, action string, dryRun bool, namespaces []string) error {
+	if p.DenyExec && resource == "pod" && (action == "exec" || action == "stdin") {
+		return fmt.Errorf("exec into pod containers is disabled by agent policy")
+	}
+	if p.ReadOnly && resource != "watch" && !IsReadAction(action) && !(dryRun && IsDryRunAction(resource, action)) {
+		return fmt.Errorf("agent is in read-only mode, resource %s action %s is not allowed", resource, action)
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	// API发现不涉及namespace中的数据
+	if resource != "discovery" {
+		for _, ns := range namespaces {
+			if err := p.checkNamespace(ns); err != nil {
+				return err
+			}
+		}
+	}
+	for _, rule := range p.Rules {
+		if !matchAny(rule.Resources, resource) || !matchAny(rule.Actions, action) {
+			continue
+		}
+		if len(rule.Namespaces) > 0 && !allMatch(rule.Namespaces, namespaces) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return fmt.Errorf("resource %s action %s is denied by agent policy", resource, action)
+		}
+		return nil
+	}
+	if p.DefaultEffect == EffectDeny {
+		return fmt.Errorf("resource %s action %s is not allowed by agent policy", resource, action)
+	}
+	return nil
+}
+
+// AllowsClusterWide 是否允许不限定namespace的请求
+func (p *Policy) AllowsClusterWide() bool {
+	return p.checkNamespace("") == nil
+}
+
+func (p *Policy) checkNamespace(namespace string) error {
+	if len(p.Namespaces.Allow) == 0 && len(p.Namespaces.Deny) == 0 {
+		return nil
+	}
+	if namespace == "" {
+		if p.Namespaces.AllowClusterWide {
+			return nil
+		}
+		return fmt.Errorf("requests not scoped to a namespace are denied by agent policy")
+	}
+	if matchAny(p.Namespaces.Deny, namespace) {
+		return fmt.Errorf("namespace %s is denied by agent policy", namespace)
+	}
+	if len(p.Namespaces.Allow) > 0 && !matchAny(p.Namespaces.Allow, namespace) {
+		return fmt.Errorf("namespace %s is not allowed by agent policy", namespace)
+	}
+	return nil
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func allMatch(patterns []string, values []string) bool {
+	for _, v := range values {
+		if !matchAny(patterns, v) {
+			return false
+		}
+	}
+	return true
+}
+
+type requestDryRun struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// IsDryRun 请求参数中是否设置了dry_run
+func IsDryRun(params []byte) bool {
+	req := &requestDryRun{}
+	json.Unmarshal(params, req)
+	return req.DryRun
+}
+
+type requestNamespaces struct {
+	Namespace *string `json:"namespace"`
+	Resources []struct {
+		Namespace string `json:"namespace"`
+	} `json:"resources"`
+	YamlStr string `json:"yaml"`
+}
+
+// DefaultNamespace yaml中namespace级别的对象未指定namespace时创建到该namespace
+const DefaultNamespace = "default"
+
+// ScopeFunc 判断yaml中的对象是否为namespace级别的资源
+type ScopeFunc func(gvk schema.GroupVersionKind) (bool, error)
+
+// RequestNamespaces 从请求参数中解析涉及的namespace，包括params.namespace、批量删除的resources及yaml中的对象。
+// yaml中未指定namespace的namespace级别对象按DefaultNamespace检查，无法确定scope时按不限定namespace检查
+func RequestNamespaces(params []byte, namespaced ScopeFunc) ([]string, error) {
+	req := &requestNamespaces{}
+	if len(params) > 0 {
+		// 参数格式错误时由handler返回参数错误
+		json.Unmarshal(params, req)
+	}
+	var namespaces []string
+	if req.Namespace != nil {
+		namespaces = append(namespaces, *req.Namespace)
+	}
+	for _, r := range req.Resources {
+		namespaces = append(namespaces, r.Namespace)
+	}
+	if req.YamlStr != "" {
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader([]byte(req.YamlStr))))
+		for {
+			buf, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			obj := &struct {
+				ApiVersion string `json:"apiVersion"`
+				Kind       string `json:"kind"`
+				Metadata   struct {
+					Namespace string `json:"namespace"`
+				} `json:"metadata"`
+			}{}
+			if err = yaml.Unmarshal(buf, obj); err != nil {
+				return nil, err
+			}
+			if obj.Kind == "" {
+				// 空文档
+				continue
+			}
+			ns := obj.Metadata.Namespace
+			if ns == "" && namespaced != nil {
+				gv, _ := schema.ParseGroupVersion(obj.ApiVersion)
+				if ok, err := namespaced(gv.WithKind(obj.Kind)); err == nil && ok {
+					ns = DefaultNamespace
+				}
+			}
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces, nil
+}
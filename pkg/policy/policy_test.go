@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		name       string
+		policy     Policy
+		resource   string
+		action     string
+		dryRun     bool
+		namespaces []string
+		wantErr    bool
+	}{
+		{name: "empty policy", resource: "deployment", action: "delete", namespaces: []string{"default"}},
+		{name: "deny exec", policy: Policy{DenyExec: true}, resource: "pod", action: "exec", wantErr: true},
+		{name: "deny exec stdin", policy: Policy{DenyExec: true}, resource: "pod", action: "stdin", wantErr: true},
+		{name: "deny exec allows log", policy: Policy{DenyExec: true}, resource: "pod", action: "openLog"},
+		{name: "read only list", policy: Policy{ReadOnly: true}, resource: "pod", action: "list"},
+		{name: "read only delete", policy: Policy{ReadOnly: true}, resource: "pod", action: "delete", wantErr: true},
+		{name: "read only watch resource", policy: Policy{ReadOnly: true}, resource: "watch", action: "close"},
+		{name: "read only dry run apply", policy: Policy{ReadOnly: true}, resource: "cluster", action: "apply", dryRun: true},
+		{name: "read only dry run delete", policy: Policy{ReadOnly: true}, resource: "deployment", action: "delete", dryRun: true},
+		{name: "read only dry run patch", policy: Policy{ReadOnly: true}, resource: "secret", action: "patch", dryRun: true},
+		{name: "read only dry run exec", policy: Policy{ReadOnly: true}, resource: "pod", action: "exec", dryRun: true, wantErr: true},
+		{name: "read only dry run stdin", policy: Policy{ReadOnly: true}, resource: "pod", action: "stdin", dryRun: true, wantErr: true},
+		{name: "read only dry run helm install", policy: Policy{ReadOnly: true}, resource: "helm", action: "create", dryRun: true, wantErr: true},
+		{name: "read only dry run update_obj", policy: Policy{ReadOnly: true}, resource: "deployment", action: "update_obj", dryRun: true, wantErr: true},
+		{
+			name:       "namespace allowed",
+			policy:     Policy{Namespaces: NamespacePolicy{Allow: []string{"team-*"}}},
+			resource:   "pod",
+			action:     "delete",
+			namespaces: []string{"team-a"},
+		},
+		{
+			name:       "namespace not allowed",
+			policy:     Policy{Namespaces: NamespacePolicy{Allow: []string{"team-*"}}},
+			resource:   "pod",
+			action:     "list",
+			namespaces: []string{"team-a", "kube-system"},
+			wantErr:    true,
+		},
+		{
+			name:       "namespace denied",
+			policy:     Policy{Namespaces: NamespacePolicy{Deny: []string{"kube-*"}}},
+			resource:   "pod",
+			action:     "list",
+			namespaces: []string{"kube-system"},
+			wantErr:    true,
+		},
+		{
+			name:     "cluster wide denied",
+			policy:   Policy{Namespaces: NamespacePolicy{Allow: []string{"team-*"}}},
+			resource: "node",
+			action:   "list",
+			wantErr:  true,
+		},
+		{
+			name:     "cluster wide allowed",
+			policy:   Policy{Namespaces: NamespacePolicy{Allow: []string{"team-*"}, AllowClusterWide: true}},
+			resource: "node",
+			action:   "list",
+		},
+		{
+			name:     "discovery not scoped",
+			policy:   Policy{Namespaces: NamespacePolicy{Allow: []string{"team-*"}}},
+			resource: "discovery",
+			action:   "list",
+		},
+		{
+			name: "first matching rule denies",
+			policy: Policy{Rules: []Rule{
+				{Resources: []string{"secret"}, Actions: []string{"*"}, Effect: EffectDeny},
+				{Resources: []string{"*"}, Actions: []string{"*"}, Effect: EffectAllow},
+			}},
+			resource: "secret",
+			action:   "get",
+			wantErr:  true,
+		},
+		{
+			name: "rule namespaces not matched",
+			policy: Policy{Rules: []Rule{
+				{Resources: []string{"*"}, Actions: []string{"delete"}, Namespaces: []string{"prod"}, Effect: EffectDeny},
+			}},
+			resource:   "pod",
+			action:     "delete",
+			namespaces: []string{"dev"},
+		},
+		{
+			name:       "default deny",
+			policy:     Policy{DefaultEffect: EffectDeny, Rules: []Rule{{Resources: []string{"pod"}, Actions: []string{"list"}, Effect: EffectAllow}}},
+			resource:   "pod",
+			action:     "delete",
+			namespaces: []string{"default"},
+			wantErr:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.Check(c.resource, c.action, c.dryRun, c.namespaces)
+			if (err != nil) != c.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "bad effect", policy: Policy{Rules: []Rule{{Effect: "maybe"}}}, wantErr: true},
+		{name: "bad default effect", policy: Policy{DefaultEffect: "maybe"}, wantErr: true},
+		{name: "bad pattern", policy: Policy{Namespaces: NamespacePolicy{Allow: []string{"team-["}}}, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.policy.validate(); (err != nil) != c.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+// testScope 模拟discovery，ConfigMap为namespace级别，ClusterRole为集群级别，其它Kind未知
+func testScope(gvk schema.GroupVersionKind) (bool, error) {
+	switch gvk.Kind {
+	case "ConfigMap":
+		return true, nil
+	case "ClusterRole":
+		return false, nil
+	}
+	return false, fmt.Errorf("no matches for kind %q", gvk.Kind)
+}
+
+func TestRequestNamespaces(t *testing.T) {
+	cases := []struct {
+		name    string
+		params  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty params"},
+		{name: "namespace", params: `{"namespace":"dev"}`, want: []string{"dev"}},
+		{name: "blank namespace", params: `{"namespace":""}`, want: []string{""}},
+		{name: "delete resources", params: `{"resources":[{"namespace":"a"},{"namespace":"b"}]}`, want: []string{"a", "b"}},
+		{
+			name:   "yaml with namespace",
+			params: `{"yaml":"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: dev\n"}`,
+			want:   []string{"dev"},
+		},
+		{
+			name:   "namespaced yaml without namespace",
+			params: `{"yaml":"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n"}`,
+			want:   []string{DefaultNamespace},
+		},
+		{
+			name:   "cluster scoped yaml",
+			params: `{"yaml":"apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: a\n"}`,
+			want:   []string{""},
+		},
+		{
+			name:   "unknown kind",
+			params: `{"yaml":"apiVersion: example.com/v1\nkind: Foo\nmetadata:\n  name: a\n"}`,
+			want:   []string{""},
+		},
+		{
+			name:   "multiple documents",
+			params: `{"yaml":"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n  namespace: dev\n"}`,
+			want:   []string{DefaultNamespace, "dev"},
+		},
+		{name: "bad yaml", params: `{"yaml":"kind: [a"}`, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := RequestNamespaces([]byte(c.params), testScope)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("RequestNamespaces() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("RequestNamespaces() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsDryRun(t *testing.T) {
+	cases := []struct {
+		params string
+		want   bool
+	}{
+		{params: ``},
+		{params: `{}`},
+		{params: `{"dry_run":false}`},
+		{params: `{"dry_run":true}`, want: true},
+	}
+	for _, c := range cases {
+		if got := IsDryRun([]byte(c.params)); got != c.want {
+			t.Errorf("IsDryRun(%s) = %v, want %v", c.params, got, c.want)
+		}
+	}
+}
+
+func TestAllowsClusterWide(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy Policy
+		want   bool
+	}{
+		{name: "no namespace policy", want: true},
+		{name: "namespace restricted", policy: Policy{Namespaces: NamespacePolicy{Allow: []string{"team-*"}}}},
+		{name: "namespace denied only", policy: Policy{Namespaces: NamespacePolicy{Deny: []string{"kube-*"}}}},
+		{name: "cluster wide allowed", policy: Policy{Namespaces: NamespacePolicy{Allow: []string{"team-*"}, AllowClusterWide: true}}, want: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.policy.AllowsClusterWide(); got != c.want {
+				t.Errorf("AllowsClusterWide() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
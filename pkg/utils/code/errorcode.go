@@ -10,4 +10,13 @@ const (
 	UpdateError  = "UpdateError"
 	EncodeError  = "EncodeError"
 	ApplyError   = "ApplyError"
+	Cancelled    = "Cancelled"
+	Timeout      = "Timeout"
+	Forbidden    = "Forbidden"
+	// ImpersonateError 创建模拟用户的客户端失败
+	ImpersonateError = "ImpersonateError"
+	// Busy agent请求队列已满
+	Busy = "Busy"
+	// WatchError watch过程中apiserver返回错误，watch会话已关闭
+	WatchError = "WatchError"
 )
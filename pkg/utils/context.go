@@ -0,0 +1,15 @@
+package utils
+
+import "context"
+
+type requestKey struct{}
+
+// WithRequest 将请求放入context，handler及中间件通过RequestFrom获取请求信息
+func WithRequest(ctx context.Context, request *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+func RequestFrom(ctx context.Context) *Request {
+	request, _ := ctx.Value(requestKey{}).(*Request)
+	return request
+}
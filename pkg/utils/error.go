@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/kubespace/agent/pkg/utils/code"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+)
+
+const (
+	// ReasonCancelled 请求被取消，apiserver没有对应的reason
+	ReasonCancelled = "Cancelled"
+)
+
+// Error 结构化的错误信息，由apiserver返回的StatusError转换，server据此区分NotFound、Forbidden、Conflict等错误
+type Error struct {
+	// Reason apiserver的StatusReason，如NotFound、Forbidden、Conflict、Invalid，无法识别时为空
+	Reason string `json:"reason"`
+	// Status HTTP状态码，无法识别时为0
+	Status int32 `json:"status"`
+	// Causes 错误的具体原因，如字段校验失败
+	Causes []ErrorCause `json:"causes,omitempty"`
+	// Retryable 稍后重试是否可能成功
+	Retryable bool `json:"retryable"`
+	// RetryAfterSeconds apiserver建议的重试间隔
+	RetryAfterSeconds int32 `json:"retry_after_seconds,omitempty"`
+}
+
+type ErrorCause struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+// NewError 从错误中解析apiserver返回的状态，兼容被wrap的错误
+func NewError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Reason: string(metav1.StatusReasonTimeout), Status: http.StatusGatewayTimeout, Retryable: true}
+	}
+	if errors.Is(err, context.Canceled) {
+		return &Error{Reason: ReasonCancelled}
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return &Error{}
+	}
+	s := status.Status()
+	e := &Error{Reason: string(s.Reason), Status: s.Code}
+	if s.Details != nil {
+		for _, c := range s.Details.Causes {
+			e.Causes = append(e.Causes, ErrorCause{Type: string(c.Type), Message: c.Message, Field: c.Field})
+		}
+	}
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+		e.RetryAfterSeconds = int32(seconds)
+	}
+	e.Retryable = e.RetryAfterSeconds > 0 ||
+		apierrors.IsConflict(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsUnexpectedServerError(err)
+	return e
+}
+
+// NewErrorResponse 返回带有结构化错误的响应，Msg为错误信息
+func NewErrorResponse(code string, err error) *Response {
+	return &Response{Code: code, Msg: err.Error(), Error: NewError(err)}
+}
+
+// NewParamsErrorResponse 请求参数错误时返回的响应
+func NewParamsErrorResponse(msg string) *Response {
+	return &Response{Code: code.ParamsError, Msg: msg, Error: &Error{Reason: string(metav1.StatusReasonBadRequest), Status: http.StatusBadRequest}}
+}
+
+// NewBusyResponse agent请求队列已满时返回的响应，server可以稍后重试
+func NewBusyResponse(msg string) *Response {
+	return &Response{Code: code.Busy, Msg: msg, Error: &Error{
+		Reason:            string(metav1.StatusReasonTooManyRequests),
+		Status:            http.StatusTooManyRequests,
+		Retryable:         true,
+		RetryAfterSeconds: 1,
+	}}
+}
+
+// NewForbiddenResponse agent本地拒绝请求时返回的响应
+func NewForbiddenResponse(msg string) *Response {
+	return &Response{Code: code.Forbidden, Msg: msg, Error: &Error{Reason: string(metav1.StatusReasonForbidden), Status: http.StatusForbidden}}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kubespace/agent/pkg/utils/code"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestNewError(t *testing.T) {
+	podGR := schema.GroupResource{Resource: "pods"}
+	cases := []struct {
+		name          string
+		err           error
+		wantNil       bool
+		wantReason    string
+		wantStatus    int32
+		wantRetryable bool
+		wantCauses    int
+	}{
+		{name: "nil", wantNil: true},
+		{name: "plain error", err: fmt.Errorf("boom")},
+		{name: "deadline", err: context.DeadlineExceeded, wantReason: string(metav1.StatusReasonTimeout), wantStatus: http.StatusGatewayTimeout, wantRetryable: true},
+		{name: "cancelled", err: fmt.Errorf("list pods: %w", context.Canceled), wantReason: ReasonCancelled},
+		{name: "not found", err: apierrors.NewNotFound(podGR, "a"), wantReason: string(metav1.StatusReasonNotFound), wantStatus: http.StatusNotFound},
+		{name: "wrapped forbidden", err: fmt.Errorf("get: %w", apierrors.NewForbidden(podGR, "a", fmt.Errorf("denied"))), wantReason: string(metav1.StatusReasonForbidden), wantStatus: http.StatusForbidden},
+		{name: "conflict", err: apierrors.NewConflict(podGR, "a", fmt.Errorf("changed")), wantReason: string(metav1.StatusReasonConflict), wantStatus: http.StatusConflict, wantRetryable: true},
+		{name: "too many requests", err: apierrors.NewTooManyRequests("slow down", 3), wantReason: string(metav1.StatusReasonTooManyRequests), wantStatus: http.StatusTooManyRequests, wantRetryable: true},
+		{
+			name:       "invalid",
+			err:        apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "a", field.ErrorList{field.Required(field.NewPath("spec", "containers"), "")}),
+			wantReason: string(metav1.StatusReasonInvalid),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCauses: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := NewError(c.err)
+			if c.wantNil {
+				if e != nil {
+					t.Errorf("NewError() = %+v, want nil", e)
+				}
+				return
+			}
+			if e.Reason != c.wantReason || e.Status != c.wantStatus || e.Retryable != c.wantRetryable || len(e.Causes) != c.wantCauses {
+				t.Errorf("NewError() = %+v, want reason %q status %d retryable %v causes %d",
+					e, c.wantReason, c.wantStatus, c.wantRetryable, c.wantCauses)
+			}
+		})
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	cases := []struct {
+		name       string
+		resp       *Response
+		wantCode   string
+		wantReason string
+		wantStatus int32
+	}{
+		{name: "params", resp: NewParamsErrorResponse("Name is blank"), wantCode: code.ParamsError, wantReason: string(metav1.StatusReasonBadRequest), wantStatus: http.StatusBadRequest},
+		{name: "busy", resp: NewBusyResponse("queue full"), wantCode: code.Busy, wantReason: string(metav1.StatusReasonTooManyRequests), wantStatus: http.StatusTooManyRequests},
+		{name: "forbidden", resp: NewForbiddenResponse("denied"), wantCode: code.Forbidden, wantReason: string(metav1.StatusReasonForbidden), wantStatus: http.StatusForbidden},
+		{
+			name:       "api error",
+			resp:       NewErrorResponse(code.GetError, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "a")),
+			wantCode:   code.GetError,
+			wantReason: string(metav1.StatusReasonNotFound),
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.resp.Code != c.wantCode || c.resp.Msg == "" || c.resp.IsSuccess() {
+				t.Errorf("response = %+v, want code %s with message", c.resp, c.wantCode)
+			}
+			if c.resp.Error == nil || c.resp.Error.Reason != c.wantReason || c.resp.Error.Status != c.wantStatus {
+				t.Errorf("response error = %+v, want reason %q status %d", c.resp.Error, c.wantReason, c.wantStatus)
+			}
+		})
+	}
+}
@@ -11,23 +11,26 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
 )
 
 type HttpClient struct {
 	client  *http.Client
+	mutex   sync.RWMutex
 	baseUrl string
 	ctx     context.Context
 	headers http.Header
 }
 
-func NewHttpClient(baseUrl string) (*HttpClient, error) {
+func NewHttpClient(baseUrl string, tlsConfig *tls.Config, proxy ProxyFunc) (*HttpClient, error) {
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig: tlsConfig,
+		Proxy:           proxy,
 	}
 	httpClient := HttpClient{client: &http.Client{Transport: tr}}
 	u, err := url.Parse(baseUrl)
 	if err != nil {
-		klog.Error("http request url parse error: httpUrl=%s. error=%v", baseUrl, err)
+		klog.Errorf("http request url parse error: httpUrl=%s. error=%v", baseUrl, err)
 		return nil, err
 	}
 	httpClient.baseUrl = u.String()
@@ -56,14 +59,14 @@ func (c *HttpClient) Post(postPath string, params map[string]string, body []byte
 func (c *HttpClient) doRequest(url string, method string, buf io.Reader) ([]byte, error) {
 	req, err := http.NewRequest(method, url, buf)
 	if err != nil {
-		klog.Error("get http request error: error=%v, url=%s, method=%s", err, url, method)
+		klog.Errorf("get http request error: error=%v, url=%s, method=%s", err, url, method)
 		return nil, err
 	}
 	req.Header = c.headers
 	req = req.WithContext(c.ctx)
 	resp, err := c.client.Do(req)
 	if err != nil {
-		klog.Error("send http req error: error=%s", err)
+		klog.Errorf("send http req error: error=%s", err)
 		return nil, err
 	} else {
 		data, errReadBody := ioutil.ReadAll(resp.Body)
@@ -72,9 +75,9 @@ func (c *HttpClient) doRequest(url string, method string, buf io.Reader) ([]byte
 			klog.Error("read received http resp body error: error=", err)
 			return nil, err
 		}
-		klog.Info("doRequest get responce: url=%s, method=%s, status=%s", url, method, resp.StatusCode)
+		klog.Infof("doRequest get responce: url=%s, method=%s, status=%d", url, method, resp.StatusCode)
 		if resp.StatusCode != http.StatusOK {
-			klog.Error("receive http code not 200: httpcode=%d", resp.StatusCode)
+			klog.Errorf("receive http code not 200: httpcode=%d", resp.StatusCode)
 			return data, fmt.Errorf("status code %v", resp.StatusCode)
 		} else {
 			return data, nil
@@ -82,8 +85,16 @@ func (c *HttpClient) doRequest(url string, method string, buf io.Reader) ([]byte
 	}
 }
 
+func (c *HttpClient) SetBaseUrl(baseUrl string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.baseUrl = baseUrl
+}
+
 func (c *HttpClient) addParamsToPath(oriPath string, params map[string]string) string {
+	c.mutex.RLock()
 	oriUrl, _ := url.Parse(c.baseUrl)
+	c.mutex.RUnlock()
 	oriUrl.Path = path.Join(oriUrl.Path, oriPath)
 	if params != nil && len(params) != 0 {
 		urlParam := url.Values{}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MarshalMsgpack 将对象编码为MessagePack格式，对象先按json tag序列化，保证与json编码的字段一致
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	var obj interface{}
+	if err = decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj, err = convertJsonNumber(obj); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	encoder := msgpack.NewEncoder(buf)
+	encoder.UseCompactInts(true)
+	// 按key排序，保证相同对象编码结果一致
+	encoder.SetSortMapKeys(true)
+	if err = encoder.Encode(obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// convertJsonNumber 整数转换为int64，其它数字转换为float64，避免json.Number被编码为字符串
+func convertJsonNumber(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Float64()
+	case []interface{}:
+		for i, item := range val {
+			converted, err := convertJsonNumber(item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = converted
+		}
+	case map[string]interface{}:
+		for k, item := range val {
+			converted, err := convertJsonNumber(item)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = converted
+		}
+	}
+	return v, nil
+}
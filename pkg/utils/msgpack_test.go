@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// decodeMsgpack 使用msgpack库解码，数字统一转换为float64，与json解码的结果比较
+func decodeMsgpack(t *testing.T, data []byte) interface{} {
+	var obj interface{}
+	if err := msgpack.Unmarshal(data, &obj); err != nil {
+		t.Fatalf("decode msgpack error: %v", err)
+	}
+	return normalizeNumber(obj)
+}
+
+func normalizeNumber(v interface{}) interface{} {
+	switch val := v.(type) {
+	case int8:
+		return float64(val)
+	case int16:
+		return float64(val)
+	case int32:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case uint8:
+		return float64(val)
+	case uint16:
+		return float64(val)
+	case uint32:
+		return float64(val)
+	case uint64:
+		return float64(val)
+	case []interface{}:
+		for i := range val {
+			val[i] = normalizeNumber(val[i])
+		}
+	case map[string]interface{}:
+		for k := range val {
+			val[k] = normalizeNumber(val[k])
+		}
+	}
+	return v
+}
+
+func TestMarshalMsgpack(t *testing.T) {
+	longList := make([]interface{}, 70000)
+	for i := range longList {
+		longList[i] = i
+	}
+	bigMap := make(map[string]interface{})
+	for i := 0; i < 20; i++ {
+		bigMap[strings.Repeat("k", i+1)] = i
+	}
+	cases := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "nil", v: nil},
+		{name: "bool", v: true},
+		{name: "small ints", v: []int{0, 1, 127, -1, -32, -33, -128}},
+		{name: "large ints", v: []int64{128, 255, 65535, -32769, math.MaxInt32 + 1, math.MinInt32 - 1}},
+		{name: "float", v: []float64{1.5, -0.25, 1e20}},
+		{name: "strings", v: []string{"", "short", strings.Repeat("a", 31), strings.Repeat("b", 255), strings.Repeat("c", 70000)}},
+		{name: "long array", v: longList},
+		{name: "map16", v: bigMap},
+		{name: "response", v: &TResponse{ResType: RequestType, RequestId: "1", Data: &Response{Code: "Success", Data: map[string]interface{}{"a": []interface{}{1, "b", nil}}}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := MarshalMsgpack(c.v)
+			if err != nil {
+				t.Fatalf("MarshalMsgpack() error = %v", err)
+			}
+			jsonData, _ := json.Marshal(c.v)
+			var want interface{}
+			json.Unmarshal(jsonData, &want)
+			if got := decodeMsgpack(t, data); !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMarshalMsgpackStable(t *testing.T) {
+	v := map[string]interface{}{"b": 1, "a": 2, "c": map[string]interface{}{"z": 1, "y": 2}}
+	first, err := MarshalMsgpack(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		data, _ := MarshalMsgpack(v)
+		if !bytes.Equal(first, data) {
+			t.Fatalf("encoding is not stable")
+		}
+	}
+}
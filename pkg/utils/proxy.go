@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyFunc 根据请求地址返回使用的代理，返回nil表示直连，http.Transport与websocket.Dialer共用
+type ProxyFunc func(*http.Request) (*url.URL, error)
+
+// ProxyOptions agent访问server使用的代理配置
+type ProxyOptions struct {
+	// ProxyUrl 代理地址，支持http（CONNECT）与socks5，为空时使用环境变量HTTPS_PROXY、HTTP_PROXY、NO_PROXY
+	ProxyUrl string
+	// NoProxy 不走代理的地址列表，逗号分隔，格式同NO_PROXY环境变量
+	NoProxy string
+	// Username、Password 代理认证信息，会覆盖代理地址中的认证信息
+	Username string
+	Password string
+}
+
+// NewProxyFunc websocket.Dialer只支持http与socks5代理，其他协议的代理在启动时返回错误
+func NewProxyFunc(opts *ProxyOptions) (ProxyFunc, error) {
+	var proxyConfig *httpproxy.Config
+	if opts.ProxyUrl == "" {
+		proxyConfig = httpproxy.FromEnvironment()
+		if opts.NoProxy != "" {
+			proxyConfig.NoProxy = opts.NoProxy
+		}
+	} else {
+		proxyConfig = &httpproxy.Config{
+			HTTPProxy:  opts.ProxyUrl,
+			HTTPSProxy: opts.ProxyUrl,
+			NoProxy:    opts.NoProxy,
+		}
+	}
+	var err error
+	if proxyConfig.HTTPProxy, err = completeProxyUrl(proxyConfig.HTTPProxy, opts); err != nil {
+		return nil, err
+	}
+	if proxyConfig.HTTPSProxy, err = completeProxyUrl(proxyConfig.HTTPSProxy, opts); err != nil {
+		return nil, err
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+// completeProxyUrl 检查代理协议并设置认证信息，未指定协议时与环境变量的处理一致，使用http
+func completeProxyUrl(proxy string, opts *ProxyOptions) (string, error) {
+	if proxy == "" {
+		return "", nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	proxyUrl, err := url.Parse(proxy)
+	if err != nil {
+		return "", fmt.Errorf("parse proxy url error: %v", err)
+	}
+	switch proxyUrl.Scheme {
+	case "http", "socks5":
+	default:
+		return "", fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+	}
+	if opts.Username != "" {
+		proxyUrl.User = url.UserPassword(opts.Username, opts.Password)
+	}
+	return proxyUrl.String(), nil
+}
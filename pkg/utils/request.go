@@ -5,4 +5,9 @@ type Request struct {
 	Action    string      `json:"action"`
 	RequestId string      `json:"request_id"`
 	Params    interface{} `json:"params"`
+	// Timeout 请求超时时间，单位秒，为0时不超时
+	Timeout int `json:"timeout"`
+	// User、Groups 发起请求的kubespace用户，agent以该用户身份访问apiserver
+	User   string   `json:"user"`
+	Groups []string `json:"groups"`
 }
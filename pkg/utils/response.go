@@ -10,6 +10,10 @@ const (
 	WatchType   = "watch"
 	ExecType    = "exec"
 	LogType     = "log"
+	// HandshakeType 连接建立后agent发送的第一条消息
+	HandshakeType = "handshake"
+	// StatsType agent定期发送的请求队列统计信息
+	StatsType = "stats"
 
 	AddEvent    = "add"
 	UpdateEvent = "update"
@@ -40,6 +44,17 @@ type Response struct {
 	Code string      `json:"code"`
 	Msg  string      `json:"msg"`
 	Data interface{} `json:"data"`
+	// Error 请求失败时的结构化错误信息
+	Error *Error `json:"error,omitempty"`
+	// List List请求过滤后的总数及分页信息
+	List *ListMeta `json:"list,omitempty"`
+}
+
+// ListMeta Total为过滤后的对象总数，Continue不为空时表示还有下一页，Remaining为剩余的对象数
+type ListMeta struct {
+	Total     int    `json:"total"`
+	Continue  string `json:"continue,omitempty"`
+	Remaining int    `json:"remaining"`
 }
 
 type WatchResponse struct {
@@ -52,6 +67,23 @@ type TResponse struct {
 	ResType   string      `json:"res_type"`
 	RequestId string      `json:"request_id"`
 	Data      interface{} `json:"data"`
+	Chunk     *Chunk      `json:"chunk,omitempty"`
+}
+
+const (
+	// ChunkEncodingJson 分片拼接后为json文本消息
+	ChunkEncodingJson = "json"
+	// ChunkEncodingBinary 分片拼接后为二进制帧
+	ChunkEncodingBinary = "binary"
+)
+
+// Chunk 大响应拆分后的分片信息，Data为分片数据，server按Stream区分分片流、按Seq顺序拼接，Final为最后一个分片
+type Chunk struct {
+	// Stream 分片流id，每次发送都不同，重发的响应不会与之前未发送完的分片混在一起
+	Stream   string `json:"stream"`
+	Seq      int    `json:"seq"`
+	Final    bool   `json:"final"`
+	Encoding string `json:"encoding"`
 }
 
 func (resp *TResponse) Serializer() ([]byte, error) {
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TLSOptions agent连接server时使用的tls配置，websocket与http客户端共用
+type TLSOptions struct {
+	// CAFile server证书的CA，为空时使用系统根证书
+	CAFile string
+	// CertFile、KeyFile 客户端证书，用于mTLS认证
+	CertFile string
+	KeyFile  string
+	// PinnedPublicKeys server证书公钥的sha256摘要（base64编码），格式同curl --pinnedpubkey，如 sha256//xxx
+	PinnedPublicKeys []string
+	// InsecureSkipVerify 不校验server证书，仅用于测试环境
+	InsecureSkipVerify bool
+}
+
+func NewTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if opts.CAFile != "" {
+		caData, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file %s error: %v", opts.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no valid certificate found in ca file %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be specified together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if len(opts.PinnedPublicKeys) > 0 {
+		pins := make(map[string]struct{})
+		for _, pin := range opts.PinnedPublicKeys {
+			pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256//")
+			if pin == "" {
+				continue
+			}
+			if _, err := base64.StdEncoding.DecodeString(pin); err != nil {
+				return nil, fmt.Errorf("invalid pinned public key %s: %v", pin, err)
+			}
+			pins[pin] = struct{}{}
+		}
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			return verifyPinnedPublicKey(rawCerts, verifiedChains, pins)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// verifyPinnedPublicKey 校验通过的证书链中任意一个证书的公钥匹配即认为通过，
+// 不校验server证书时没有可信的证书链，只匹配server证书本身，避免对端在链中附带被pin的CA证书
+func verifyPinnedPublicKey(rawCerts [][]byte, verifiedChains [][]*x509.Certificate, pins map[string]struct{}) error {
+	var certs []*x509.Certificate
+	if len(verifiedChains) > 0 {
+		for _, chain := range verifiedChains {
+			certs = append(certs, chain...)
+		}
+	} else if len(rawCerts) > 0 {
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if _, ok := pins[base64.StdEncoding.EncodeToString(sum[:])]; ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("server certificate public key does not match any pinned key")
+}
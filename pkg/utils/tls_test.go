@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func pinOf(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifyPinnedPublicKey(t *testing.T) {
+	ca, caKey := newTestCert(t, "ca", nil, nil)
+	leaf, _ := newTestCert(t, "server", ca, caKey)
+	other, _ := newTestCert(t, "other", nil, nil)
+
+	cases := []struct {
+		name           string
+		rawCerts       [][]byte
+		verifiedChains [][]*x509.Certificate
+		pins           []string
+		wantErr        bool
+	}{
+		{
+			name:     "skip verify leaf pinned",
+			rawCerts: [][]byte{leaf.Raw, ca.Raw},
+			pins:     []string{pinOf(leaf)},
+		},
+		{
+			name:     "skip verify pinned ca appended by peer",
+			rawCerts: [][]byte{other.Raw, ca.Raw},
+			pins:     []string{pinOf(ca)},
+			wantErr:  true,
+		},
+		{
+			name:           "verified chain ca pinned",
+			rawCerts:       [][]byte{leaf.Raw},
+			verifiedChains: [][]*x509.Certificate{{leaf, ca}},
+			pins:           []string{pinOf(ca)},
+		},
+		{
+			name:           "verified chain not pinned",
+			rawCerts:       [][]byte{leaf.Raw, other.Raw},
+			verifiedChains: [][]*x509.Certificate{{leaf, ca}},
+			pins:           []string{pinOf(other)},
+			wantErr:        true,
+		},
+		{
+			name:    "no certificates",
+			pins:    []string{pinOf(leaf)},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pins := make(map[string]struct{})
+			for _, pin := range c.pins {
+				pins[pin] = struct{}{}
+			}
+			err := verifyPinnedPublicKey(c.rawCerts, c.verifiedChains, pins)
+			if (err != nil) != c.wantErr {
+				t.Errorf("verifyPinnedPublicKey() error = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfigPins(t *testing.T) {
+	cases := []struct {
+		name    string
+		opts    *TLSOptions
+		wantErr bool
+		wantPin bool
+	}{
+		{name: "no pins", opts: &TLSOptions{}},
+		{name: "valid pin", opts: &TLSOptions{PinnedPublicKeys: []string{"sha256//" + base64.StdEncoding.EncodeToString(make([]byte, 32))}}, wantPin: true},
+		{name: "invalid pin", opts: &TLSOptions{PinnedPublicKeys: []string{"sha256//not base64!"}}, wantErr: true},
+		{name: "cert without key", opts: &TLSOptions{CertFile: "client.crt"}, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tlsConfig, err := NewTLSConfig(c.opts)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("NewTLSConfig() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if err == nil && (tlsConfig.VerifyPeerCertificate != nil) != c.wantPin {
+				t.Errorf("VerifyPeerCertificate set = %v, want %v", tlsConfig.VerifyPeerCertificate != nil, c.wantPin)
+			}
+		})
+	}
+}
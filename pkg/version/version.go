@@ -0,0 +1,31 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// 以下变量在编译时通过 -ldflags "-X github.com/kubespace/agent/pkg/version.Version=xxx" 注入
+var (
+	Version   = "dev"
+	GitCommit = ""
+	BuildDate = ""
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+}
@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+	"github.com/kubespace/agent/pkg/utils"
+	"k8s.io/klog"
+)
+
+const (
+	// FeatureChunked 超过大小限制的响应拆分为多个分片发送
+	FeatureChunked = "chunked"
+
+	// FrameChunk 分片帧：类型(1字节) + 序号(4字节) + 是否最后一片(1字节) + 分片流id长度(1字节) + 分片流id + 分片数据
+	FrameChunk byte = 4
+
+	DefaultMaxMessageSize = 1024 * 1024
+	// chunkEnvelopeSize 分片json外层结构预留的大小
+	chunkEnvelopeSize = 512
+)
+
+// chunkStreamId 分片流id为request_id加发送序号，发送失败的响应重发时使用新的id，
+// server丢弃未完成的旧分片流，不会与重发的分片重复拼接
+func (ws *WebSocket) chunkStreamId(requestId string) string {
+	return fmt.Sprintf("%s/%d", requestId, atomic.AddUint32(&ws.chunkStreams, 1))
+}
+
+// sendChunked 将编码后的响应按MaxMessageSize拆分发送，server按seq顺序拼接同一分片流的数据后，
+// 得到的即是未拆分时的完整消息（json文本或二进制帧），再按正常消息解析
+func (ws *WebSocket) sendChunked(resp *utils.TResponse, messageType int, data []byte) error {
+	stream := ws.chunkStreamId(resp.RequestId)
+	binaryChunk := ws.negotiated.enabled(FeatureBinaryFrames) && len(stream) <= 255
+	chunkSize := ws.MaxMessageSize - chunkEnvelopeSize
+	if !binaryChunk {
+		// json中[]byte为base64编码，体积增加1/3
+		chunkSize = chunkSize * 3 / 4
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultMaxMessageSize
+	}
+	encoding := utils.ChunkEncodingJson
+	if messageType == websocket.BinaryMessage {
+		encoding = utils.ChunkEncodingBinary
+	}
+	total := (len(data) + chunkSize - 1) / chunkSize
+	klog.V(1).Infof("response %s size %d exceeds %d, send in %d chunks", resp.RequestId, len(data), ws.MaxMessageSize, total)
+	for seq := 0; seq < total; seq++ {
+		end := (seq + 1) * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		part := data[seq*chunkSize : end]
+		final := seq == total-1
+		var err error
+		if binaryChunk {
+			header := make([]byte, 7)
+			header[0] = FrameChunk
+			binary.BigEndian.PutUint32(header[1:5], uint32(seq))
+			if final {
+				header[5] = 1
+			}
+			header[6] = byte(len(stream))
+			frame := make([]byte, 0, len(header)+len(stream)+len(part))
+			frame = append(frame, header...)
+			frame = append(frame, stream...)
+			frame = append(frame, part...)
+			err = ws.writeMessage(websocket.BinaryMessage, frame)
+		} else {
+			chunkResp := &utils.TResponse{
+				ResType:   resp.ResType,
+				RequestId: resp.RequestId,
+				Data:      part,
+				Chunk:     &utils.Chunk{Stream: stream, Seq: seq, Final: final, Encoding: encoding},
+			}
+			var chunkMsg []byte
+			chunkMsg, err = chunkResp.Serializer()
+			if err != nil {
+				return err
+			}
+			err = ws.writeMessage(websocket.TextMessage, chunkMsg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
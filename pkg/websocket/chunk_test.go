@@ -0,0 +1,253 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kubespace/agent/pkg/utils"
+)
+
+type testMessage struct {
+	messageType int
+	data        []byte
+}
+
+// startTestServer 启动websocket server，server收到的消息写入返回的channel
+func startTestServer(t *testing.T) (*httptest.Server, <-chan testMessage) {
+	messages := make(chan testMessage, 100)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- testMessage{messageType: messageType, data: data}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, messages
+}
+
+// newTestServer 启动websocket server并返回已连接的客户端
+func newTestServer(t *testing.T) (*websocket.Conn, <-chan testMessage) {
+	server, messages := startTestServer(t)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial test server error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, messages
+}
+
+func newTestWebSocket(t *testing.T, features []string) (*WebSocket, <-chan testMessage) {
+	conn, messages := newTestServer(t)
+	ws := &WebSocket{
+		Conn:           conn,
+		Keepalive:      &KeepaliveOptions{WriteTimeout: 5 * time.Second},
+		negotiated:     &negotiatedFeatures{},
+		connState:      newConnState(),
+		outbox:         newOutbox(nil),
+		MaxMessageSize: DefaultMaxMessageSize,
+	}
+	ws.negotiated.set(features)
+	return ws, messages
+}
+
+func receiveMessage(t *testing.T, messages <-chan testMessage) testMessage {
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("test server connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("receive message timeout")
+	}
+	return testMessage{}
+}
+
+// decodeChunk 解析分片消息，返回序号、是否最后一片、分片流id及分片数据
+func decodeChunk(t *testing.T, msg testMessage) (int, bool, string, []byte) {
+	if msg.messageType == websocket.BinaryMessage {
+		if msg.data[0] != FrameChunk {
+			t.Fatalf("frame type = %d, want %d", msg.data[0], FrameChunk)
+		}
+		seq := int(binary.BigEndian.Uint32(msg.data[1:5]))
+		idLen := int(msg.data[6])
+		return seq, msg.data[5] == 1, string(msg.data[7 : 7+idLen]), msg.data[7+idLen:]
+	}
+	chunk := &struct {
+		RequestId string       `json:"request_id"`
+		Data      string       `json:"data"`
+		Chunk     *utils.Chunk `json:"chunk"`
+	}{}
+	if err := json.Unmarshal(msg.data, chunk); err != nil {
+		t.Fatalf("decode json chunk error: %v", err)
+	}
+	if chunk.Chunk == nil {
+		t.Fatalf("chunk info not found in %s", string(msg.data))
+	}
+	if !strings.HasPrefix(chunk.Chunk.Stream, chunk.RequestId+"/") {
+		t.Errorf("chunk stream %q not for request %q", chunk.Chunk.Stream, chunk.RequestId)
+	}
+	part, err := base64.StdEncoding.DecodeString(chunk.Data)
+	if err != nil {
+		t.Fatalf("decode chunk data error: %v", err)
+	}
+	return chunk.Chunk.Seq, chunk.Chunk.Final, chunk.Chunk.Stream, part
+}
+
+func TestSendChunked(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 300)
+	cases := []struct {
+		name           string
+		features       []string
+		requestId      string
+		messageType    int
+		maxMessageSize int
+		// wantChunks 期望的分片数，wantType 分片消息的类型
+		wantChunks int
+		wantType   int
+	}{
+		{
+			name:           "binary chunks",
+			features:       []string{FeatureBinaryFrames, FeatureChunked},
+			requestId:      "req-1",
+			messageType:    websocket.BinaryMessage,
+			maxMessageSize: chunkEnvelopeSize + 1000,
+			wantChunks:     3,
+			wantType:       websocket.BinaryMessage,
+		},
+		{
+			name:           "json chunks without binary frames",
+			features:       []string{FeatureChunked},
+			requestId:      "req-2",
+			messageType:    websocket.TextMessage,
+			maxMessageSize: chunkEnvelopeSize + 1000,
+			wantChunks:     4,
+			wantType:       websocket.TextMessage,
+		},
+		{
+			name:           "long request id falls back to json",
+			features:       []string{FeatureBinaryFrames, FeatureChunked},
+			requestId:      strings.Repeat("r", 256),
+			messageType:    websocket.BinaryMessage,
+			maxMessageSize: chunkEnvelopeSize + 1000,
+			wantChunks:     4,
+			wantType:       websocket.TextMessage,
+		},
+		{
+			name:           "single chunk",
+			features:       []string{FeatureBinaryFrames, FeatureChunked},
+			requestId:      "req-3",
+			messageType:    websocket.TextMessage,
+			maxMessageSize: chunkEnvelopeSize + len(data),
+			wantChunks:     1,
+			wantType:       websocket.BinaryMessage,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws, messages := newTestWebSocket(t, c.features)
+			ws.MaxMessageSize = c.maxMessageSize
+			resp := &utils.TResponse{ResType: utils.RequestType, RequestId: c.requestId}
+			if err := ws.sendChunked(resp, c.messageType, data); err != nil {
+				t.Fatalf("sendChunked() error = %v", err)
+			}
+			var got []byte
+			var stream string
+			for i := 0; i < c.wantChunks; i++ {
+				msg := receiveMessage(t, messages)
+				if msg.messageType != c.wantType {
+					t.Fatalf("chunk %d message type = %d, want %d", i, msg.messageType, c.wantType)
+				}
+				seq, final, chunkStream, part := decodeChunk(t, msg)
+				if seq != i {
+					t.Errorf("chunk seq = %d, want %d", seq, i)
+				}
+				if final != (i == c.wantChunks-1) {
+					t.Errorf("chunk %d final = %v", i, final)
+				}
+				if i == 0 {
+					stream = chunkStream
+				}
+				if chunkStream != stream || !strings.HasPrefix(chunkStream, c.requestId+"/") {
+					t.Errorf("chunk %d stream = %q, want %q of request %q", i, chunkStream, stream, c.requestId)
+				}
+				got = append(got, part...)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("reassembled data length %d, want %d", len(got), len(data))
+			}
+		})
+	}
+}
+
+func TestSendResponseChunkedNegotiation(t *testing.T) {
+	cases := []struct {
+		name     string
+		features []string
+		// wantMessages server收到的消息数，未协商chunked时大响应整条发送
+		wantMessages int
+	}{
+		{name: "chunked negotiated", features: []string{FeatureChunked}, wantMessages: 3},
+		{name: "chunked not negotiated", wantMessages: 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws, messages := newTestWebSocket(t, c.features)
+			ws.MaxMessageSize = chunkEnvelopeSize + 1000
+			resp := &utils.TResponse{ResType: utils.RequestType, RequestId: "1", Data: strings.Repeat("a", 2000)}
+			if err := ws.sendResponse(resp); err != nil {
+				t.Fatalf("sendResponse() error = %v", err)
+			}
+			for i := 0; i < c.wantMessages; i++ {
+				receiveMessage(t, messages)
+			}
+			select {
+			case msg := <-messages:
+				t.Errorf("unexpected extra message %d bytes", len(msg.data))
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestSendChunkedResendNewStream(t *testing.T) {
+	ws, messages := newTestWebSocket(t, []string{FeatureBinaryFrames, FeatureChunked})
+	ws.MaxMessageSize = chunkEnvelopeSize + 1000
+	data := bytes.Repeat([]byte("a"), 1500)
+	resp := &utils.TResponse{ResType: utils.RequestType, RequestId: "1"}
+	// 重发的响应使用新的分片流，不会与之前发送的分片重复
+	streams := make(map[string]int)
+	for i := 0; i < 2; i++ {
+		if err := ws.sendChunked(resp, websocket.BinaryMessage, data); err != nil {
+			t.Fatalf("sendChunked() error = %v", err)
+		}
+		for j := 0; j < 2; j++ {
+			seq, _, stream, _ := decodeChunk(t, receiveMessage(t, messages))
+			if seq != j {
+				t.Errorf("chunk seq = %d, want %d", seq, j)
+			}
+			streams[stream] += 1
+		}
+	}
+	if len(streams) != 2 {
+		t.Errorf("chunk streams = %v, want 2 streams", streams)
+	}
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/kubespace/agent/pkg/utils"
+)
+
+const (
+	// FeatureCompression 支持websocket permessage-deflate压缩
+	FeatureCompression = "permessage-deflate"
+	// FeatureBinaryFrames exec/log流数据使用二进制帧发送，不再base64编码后包装在json中
+	FeatureBinaryFrames = "binary-frames"
+	// FeatureMsgpack 普通响应使用MessagePack编码，以二进制帧发送
+	FeatureMsgpack = "msgpack"
+)
+
+// 二进制帧的第一个字节为帧类型
+const (
+	// FrameExec、FrameLog 流数据帧：类型(1字节) + request_id长度(1字节) + request_id + 原始数据
+	FrameExec byte = 1
+	FrameLog  byte = 2
+	// FrameMsgpack 类型(1字节) + MessagePack编码的TResponse
+	FrameMsgpack byte = 3
+)
+
+// HandshakeAction server回复agent handshake的消息，params中为server同意使用的特性
+const HandshakeAction = "handshake"
+
+type HandshakeReplyParams struct {
+	Features []string `json:"features"`
+}
+
+// negotiatedFeatures 与当前连接的server协商后启用的特性，重连后重置
+type negotiatedFeatures struct {
+	mutex    sync.RWMutex
+	features map[string]bool
+}
+
+func (n *negotiatedFeatures) set(features []string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.features = make(map[string]bool)
+	for _, f := range features {
+		n.features[f] = true
+	}
+}
+
+func (n *negotiatedFeatures) enabled(feature string) bool {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.features[feature]
+}
+
+// encodeResponse 根据协商的特性选择响应的编码方式，未协商时与之前一样使用json文本帧
+func (ws *WebSocket) encodeResponse(resp *utils.TResponse) (int, []byte, error) {
+	if resp.ResType == utils.ExecType || resp.ResType == utils.LogType {
+		if ws.negotiated.enabled(FeatureBinaryFrames) && len(resp.RequestId) <= 255 {
+			if payload, ok := streamPayload(resp.Data); ok {
+				frameType := FrameExec
+				if resp.ResType == utils.LogType {
+					frameType = FrameLog
+				}
+				frame := make([]byte, 0, 2+len(resp.RequestId)+len(payload))
+				frame = append(frame, frameType, byte(len(resp.RequestId)))
+				frame = append(frame, resp.RequestId...)
+				frame = append(frame, payload...)
+				return websocket.BinaryMessage, frame, nil
+			}
+		}
+	} else if ws.negotiated.enabled(FeatureMsgpack) {
+		data, err := utils.MarshalMsgpack(resp)
+		if err != nil {
+			return 0, nil, err
+		}
+		return websocket.BinaryMessage, append([]byte{FrameMsgpack}, data...), nil
+	}
+	data, err := resp.Serializer()
+	return websocket.TextMessage, data, err
+}
+
+// streamPayload exec/log的输出及错误信息都以原始字节发送，其他类型的数据仍使用json
+func streamPayload(data interface{}) ([]byte, bool) {
+	if d, ok := data.([]byte); ok {
+		return d, true
+	}
+	return nil, false
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEncodeResponse(t *testing.T) {
+	cases := []struct {
+		name        string
+		features    []string
+		resp        *utils.TResponse
+		messageType int
+		// check 校验编码后的消息
+		check func(t *testing.T, data []byte)
+	}{
+		{
+			name:        "json without negotiation",
+			resp:        &utils.TResponse{ResType: utils.RequestType, RequestId: "1", Data: "ok"},
+			messageType: websocket.TextMessage,
+			check: func(t *testing.T, data []byte) {
+				resp := &utils.TResponse{}
+				if err := json.Unmarshal(data, resp); err != nil || resp.Data != "ok" {
+					t.Errorf("decode json response = %+v, %v", resp, err)
+				}
+			},
+		},
+		{
+			name:        "exec binary frame",
+			features:    []string{FeatureBinaryFrames},
+			resp:        &utils.TResponse{ResType: utils.ExecType, RequestId: "abc", Data: []byte("output")},
+			messageType: websocket.BinaryMessage,
+			check: func(t *testing.T, data []byte) {
+				want := append([]byte{FrameExec, 3}, []byte("abcoutput")...)
+				if !bytes.Equal(data, want) {
+					t.Errorf("frame = %v, want %v", data, want)
+				}
+			},
+		},
+		{
+			name:        "log error bytes",
+			features:    []string{FeatureBinaryFrames},
+			resp:        &utils.TResponse{ResType: utils.LogType, RequestId: "l", Data: []byte("line")},
+			messageType: websocket.BinaryMessage,
+			check: func(t *testing.T, data []byte) {
+				want := append([]byte{FrameLog, 1}, []byte("lline")...)
+				if !bytes.Equal(data, want) {
+					t.Errorf("frame = %v, want %v", data, want)
+				}
+			},
+		},
+		{
+			// 字符串即使是合法的base64也不解码，按json发送
+			name:        "log string not decoded",
+			features:    []string{FeatureBinaryFrames},
+			resp:        &utils.TResponse{ResType: utils.LogType, RequestId: "l", Data: "test"},
+			messageType: websocket.TextMessage,
+			check: func(t *testing.T, data []byte) {
+				resp := &utils.TResponse{}
+				if err := json.Unmarshal(data, resp); err != nil || resp.Data != "test" {
+					t.Errorf("decode json response = %+v, %v", resp, err)
+				}
+			},
+		},
+		{
+			name:        "exec without binary frames",
+			features:    []string{FeatureMsgpack},
+			resp:        &utils.TResponse{ResType: utils.ExecType, RequestId: "abc", Data: []byte("output")},
+			messageType: websocket.TextMessage,
+			check:       func(t *testing.T, data []byte) {},
+		},
+		{
+			name:        "msgpack frame",
+			features:    []string{FeatureMsgpack},
+			resp:        &utils.TResponse{ResType: utils.RequestType, RequestId: "2", Data: map[string]interface{}{"n": 1}},
+			messageType: websocket.BinaryMessage,
+			check: func(t *testing.T, data []byte) {
+				if data[0] != FrameMsgpack {
+					t.Fatalf("frame type = %d, want %d", data[0], FrameMsgpack)
+				}
+				var resp map[string]interface{}
+				if err := msgpack.Unmarshal(data[1:], &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp["request_id"] != "2" || resp["res_type"] != utils.RequestType {
+					t.Errorf("decoded response = %v", resp)
+				}
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws := &WebSocket{negotiated: &negotiatedFeatures{}}
+			ws.negotiated.set(c.features)
+			messageType, data, err := ws.encodeResponse(c.resp)
+			if err != nil {
+				t.Fatalf("encodeResponse() error = %v", err)
+			}
+			if messageType != c.messageType {
+				t.Errorf("message type = %d, want %d", messageType, c.messageType)
+			}
+			c.check(t, data)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// SrvPrefix server-url以该前缀开头时，通过DNS SRV记录解析server地址，如 srv://_kubespace._tcp.example.com
+	SrvPrefix = "srv://"
+
+	DefaultPrimaryProbeInterval = 30 * time.Second
+)
+
+// Endpoints server地址列表，按优先级排序，第一个为主地址
+type Endpoints struct {
+	serverUrl string
+	mutex     sync.RWMutex
+	hosts     []string
+	active    string
+}
+
+// NewEndpoints serverUrl可以是单个地址、逗号分隔的有序地址列表，或者srv://开头的SRV记录名称
+func NewEndpoints(serverUrl string) (*Endpoints, error) {
+	e := &Endpoints{serverUrl: serverUrl}
+	if _, err := e.Resolve(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Resolve 重新解析server地址列表，SRV解析失败时沿用上一次的解析结果
+func (e *Endpoints) Resolve() ([]string, error) {
+	var hosts []string
+	var err error
+	if strings.HasPrefix(e.serverUrl, SrvPrefix) {
+		hosts, err = lookupSrv(strings.TrimPrefix(e.serverUrl, SrvPrefix))
+	} else {
+		for _, host := range strings.Split(e.serverUrl, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) == 0 {
+			err = fmt.Errorf("server url is empty")
+		}
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err != nil {
+		if len(e.hosts) > 0 {
+			klog.Errorf("resolve server endpoints %s error: %v, use last resolved %v", e.serverUrl, err, e.hosts)
+			return e.hosts, nil
+		}
+		return nil, err
+	}
+	e.hosts = hosts
+	return hosts, nil
+}
+
+func lookupSrv(name string) ([]string, error) {
+	// 返回的记录已经按照priority排序，相同priority按weight随机
+	_, addrs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, addr := range addrs {
+		target := strings.TrimSuffix(addr.Target, ".")
+		hosts = append(hosts, net.JoinHostPort(target, strconv.Itoa(int(addr.Port))))
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no srv record found for %s", name)
+	}
+	return hosts, nil
+}
+
+// Primary 返回优先级最高的server地址
+func (e *Endpoints) Primary() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if len(e.hosts) == 0 {
+		return ""
+	}
+	return e.hosts[0]
+}
+
+// Active 返回当前连接的server地址，未连接时为空
+func (e *Endpoints) Active() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.active
+}
+
+func (e *Endpoints) setActive(host string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.active != host {
+		klog.Infof("active server endpoint changed from %q to %q", e.active, host)
+	}
+	e.active = host
+}
+
+// probePrimary 当前连接的不是主地址时，定时探测主地址是否恢复，恢复后关闭当前连接，由重连逻辑切回主地址
+func (ws *WebSocket) probePrimary(stopCh chan struct{}) {
+	ticker := time.NewTicker(ws.PrimaryProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if _, err := ws.Endpoints.Resolve(); err != nil {
+				continue
+			}
+			primary := ws.Endpoints.Primary()
+			if primary == "" || primary == ws.Endpoints.Active() {
+				return
+			}
+			if err := ws.probeEndpoint(primary); err != nil {
+				klog.V(1).Infof("primary server endpoint %s is still unreachable: %v", primary, err)
+				continue
+			}
+			klog.Infof("primary server endpoint %s recovered, switch back from %s", primary, ws.Endpoints.Active())
+			ws.closeConn()
+			return
+		}
+	}
+}
+
+// probeEndpoint 通过http请求探测server地址是否可达，与websocket连接使用相同的tls及代理配置，收到任何http响应都认为可达
+func (ws *WebSocket) probeEndpoint(host string) error {
+	ws.writeMutex.Lock()
+	wsScheme := ws.Url.Scheme
+	ws.writeMutex.Unlock()
+	scheme := "http"
+	if wsScheme == "wss" {
+		scheme = "https"
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: ws.TLSConfig, Proxy: ws.Proxy},
+		Timeout:   ws.Keepalive.WriteTimeout,
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get((&url.URL{Scheme: scheme, Host: host, Path: "/"}).String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEndpointsResolve(t *testing.T) {
+	cases := []struct {
+		name      string
+		serverUrl string
+		hosts     []string
+		primary   string
+		wantErr   bool
+	}{
+		{name: "single", serverUrl: "server:80", hosts: []string{"server:80"}, primary: "server:80"},
+		{name: "ordered list", serverUrl: "a:80, b:80,,c:80", hosts: []string{"a:80", "b:80", "c:80"}, primary: "a:80"},
+		{name: "empty", serverUrl: " , ", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := NewEndpoints(c.serverUrl)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("NewEndpoints() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			hosts, err := e.Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(hosts, c.hosts) {
+				t.Errorf("Resolve() = %v, want %v", hosts, c.hosts)
+			}
+			if e.Primary() != c.primary {
+				t.Errorf("Primary() = %s, want %s", e.Primary(), c.primary)
+			}
+		})
+	}
+}
+
+func TestEndpointsResolveKeepsLastResult(t *testing.T) {
+	e, err := NewEndpoints("a:80,b:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.serverUrl = ""
+	hosts, err := e.Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v, want last resolved hosts", err)
+	}
+	if !reflect.DeepEqual(hosts, []string{"a:80", "b:80"}) {
+		t.Errorf("Resolve() = %v", hosts)
+	}
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/version"
+	"k8s.io/klog"
+)
+
+const (
+	// ProtocolVersion agent与server之间的协议版本，1为每个响应单独建立连接，2为所有消息复用同一连接
+	ProtocolVersion = 2
+
+	// FeatureMultiplex 请求、响应、watch及exec/log数据复用同一条连接
+	FeatureMultiplex = "multiplex"
+	// FeatureKeepalive agent定时发送ping并要求server回复pong
+	FeatureKeepalive = "keepalive"
+)
+
+// CapabilitiesFunc 返回agent注册的resource及其支持的action
+type CapabilitiesFunc func() map[string][]string
+
+type Handshake struct {
+	ProtocolVersion   int                 `json:"protocol_version"`
+	Agent             version.Info        `json:"agent"`
+	KubernetesVersion string              `json:"kubernetes_version"`
+	Endpoint          string              `json:"endpoint"`
+	Capabilities      map[string][]string `json:"capabilities"`
+	Features          []string            `json:"features"`
+	MaxMessageSize    int                 `json:"max_message_size"`
+}
+
+// Features 返回agent支持的传输特性，由server根据该列表协商
+func (ws *WebSocket) Features() []string {
+	features := []string{FeatureMultiplex, FeatureKeepalive, FeatureBinaryFrames, FeatureChunked}
+	if ws.Compression {
+		features = append(features, FeatureCompression)
+	}
+	if ws.CompactEncoding {
+		features = append(features, FeatureMsgpack)
+	}
+	return features
+}
+
+// handleHandshakeReply 启用server同意的特性，agent不支持的特性忽略
+func (ws *WebSocket) handleHandshakeReply(request *utils.Request) {
+	params := &HandshakeReplyParams{}
+	requestParams, _ := json.Marshal(request.Params)
+	if err := json.Unmarshal(requestParams, params); err != nil {
+		klog.Errorf("unserializer handshake reply error: %s", err)
+		return
+	}
+	var features []string
+	for _, f := range params.Features {
+		if utils.Contains(ws.Features(), f) {
+			features = append(features, f)
+		}
+	}
+	ws.negotiated.set(features)
+	klog.Infof("negotiated features with server: %v", features)
+}
+
+// sendHandshake 连接建立后第一条消息，告知server agent版本、k8s版本及支持的能力
+func (ws *WebSocket) sendHandshake() error {
+	handshake := &Handshake{
+		ProtocolVersion:   ProtocolVersion,
+		Agent:             version.Get(),
+		KubernetesVersion: ws.KubernetesVersion,
+		Endpoint:          ws.Endpoints.Active(),
+		Features:          ws.Features(),
+		MaxMessageSize:    ws.MaxMessageSize,
+	}
+	if ws.Capabilities != nil {
+		handshake.Capabilities = ws.Capabilities()
+	}
+	resp := &utils.TResponse{ResType: utils.HandshakeType, Data: handshake}
+	respMsg, err := resp.Serializer()
+	if err != nil {
+		return err
+	}
+	klog.V(1).Infof("send handshake: %s", string(respMsg))
+	return ws.writeMessage(websocket.TextMessage, respMsg)
+}
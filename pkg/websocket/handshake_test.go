@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/version"
+)
+
+func TestFeatures(t *testing.T) {
+	base := []string{FeatureMultiplex, FeatureKeepalive, FeatureBinaryFrames, FeatureChunked}
+	cases := []struct {
+		name            string
+		compression     bool
+		compactEncoding bool
+		want            []string
+	}{
+		{name: "default", want: base},
+		{name: "compression", compression: true, want: append(base[:len(base):len(base)], FeatureCompression)},
+		{name: "msgpack", compactEncoding: true, want: append(base[:len(base):len(base)], FeatureMsgpack)},
+		{
+			name:            "all",
+			compression:     true,
+			compactEncoding: true,
+			want:            append(base[:len(base):len(base)], FeatureCompression, FeatureMsgpack),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws := &WebSocket{Compression: c.compression, CompactEncoding: c.compactEncoding}
+			if got := ws.Features(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Features() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestHandleHandshakeReply(t *testing.T) {
+	cases := []struct {
+		name        string
+		compression bool
+		params      interface{}
+		enabled     []string
+		disabled    []string
+	}{
+		{
+			name:     "enable agreed features",
+			params:   map[string]interface{}{"features": []string{FeatureBinaryFrames, FeatureChunked}},
+			enabled:  []string{FeatureBinaryFrames, FeatureChunked},
+			disabled: []string{FeatureMsgpack, FeatureCompression},
+		},
+		{
+			name:     "ignore features agent not supported",
+			params:   map[string]interface{}{"features": []string{FeatureCompression, FeatureMsgpack, "unknown"}},
+			disabled: []string{FeatureCompression, FeatureMsgpack, "unknown"},
+		},
+		{
+			name:        "compression enabled by agent",
+			compression: true,
+			params:      map[string]interface{}{"features": []string{FeatureCompression}},
+			enabled:     []string{FeatureCompression},
+		},
+		{
+			name:     "invalid params keep previous features",
+			params:   map[string]interface{}{"features": "chunked"},
+			enabled:  []string{FeatureMultiplex},
+			disabled: []string{FeatureChunked},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws := &WebSocket{Compression: c.compression, negotiated: &negotiatedFeatures{}}
+			ws.negotiated.set([]string{FeatureMultiplex})
+			ws.handleHandshakeReply(&utils.Request{Action: HandshakeAction, Params: c.params})
+			for _, f := range c.enabled {
+				if !ws.negotiated.enabled(f) {
+					t.Errorf("feature %s not enabled", f)
+				}
+			}
+			for _, f := range c.disabled {
+				if ws.negotiated.enabled(f) {
+					t.Errorf("feature %s enabled", f)
+				}
+			}
+		})
+	}
+}
+
+func TestSendHandshake(t *testing.T) {
+	cases := []struct {
+		name         string
+		capabilities CapabilitiesFunc
+		want         map[string][]string
+	}{
+		{name: "without capabilities"},
+		{
+			name:         "with capabilities",
+			capabilities: func() map[string][]string { return map[string][]string{"pod": {"list", "get"}} },
+			want:         map[string][]string{"pod": {"list", "get"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws, messages := newTestWebSocket(t, nil)
+			endpoints, err := NewEndpoints("a:80,b:80")
+			if err != nil {
+				t.Fatal(err)
+			}
+			endpoints.setActive("b:80")
+			ws.Endpoints = endpoints
+			ws.KubernetesVersion = "v1.23.5"
+			ws.Capabilities = c.capabilities
+			if err = ws.sendHandshake(); err != nil {
+				t.Fatalf("sendHandshake() error = %v", err)
+			}
+			msg := receiveMessage(t, messages)
+			if msg.messageType != websocket.TextMessage {
+				t.Errorf("message type = %d, want text", msg.messageType)
+			}
+			resp := &struct {
+				ResType string     `json:"res_type"`
+				Data    *Handshake `json:"data"`
+			}{}
+			if err = json.Unmarshal(msg.data, resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.ResType != utils.HandshakeType {
+				t.Errorf("res type = %s, want %s", resp.ResType, utils.HandshakeType)
+			}
+			want := &Handshake{
+				ProtocolVersion:   ProtocolVersion,
+				Agent:             version.Get(),
+				KubernetesVersion: "v1.23.5",
+				Endpoint:          "b:80",
+				Capabilities:      c.want,
+				Features:          ws.Features(),
+				MaxMessageSize:    DefaultMaxMessageSize,
+			}
+			if !reflect.DeepEqual(resp.Data, want) {
+				t.Errorf("handshake = %+v, want %+v", resp.Data, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package websocket
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/klog"
+)
+
+type ConnState string
+
+const (
+	// StateConnecting 未连接或正在重连server
+	StateConnecting ConnState = "connecting"
+	// StateConnected 连接正常，心跳正常
+	StateConnected ConnState = "connected"
+	// StateDegraded 连接仍在，但心跳超时或写入失败，连接可能已经不可用
+	StateDegraded ConnState = "degraded"
+)
+
+const (
+	DefaultPingInterval        = 20 * time.Second
+	DefaultPongTimeout         = 60 * time.Second
+	DefaultWriteTimeout        = 10 * time.Second
+	DefaultReconnectMinBackoff = time.Second
+	DefaultReconnectMaxBackoff = time.Minute
+
+	// stableConnectionDuration 连接保持超过该时间后断开，重连退避从最小间隔重新开始
+	stableConnectionDuration = time.Minute
+)
+
+// KeepaliveOptions 心跳及重连配置
+type KeepaliveOptions struct {
+	// PingInterval 发送ping的间隔
+	PingInterval time.Duration
+	// PongTimeout 读超时时间，超过该时间没有收到任何消息（包括pong）则认为连接已断开
+	PongTimeout time.Duration
+	// WriteTimeout 单次写消息的超时时间
+	WriteTimeout time.Duration
+	// ReconnectMinBackoff、ReconnectMaxBackoff 重连的指数退避区间
+	ReconnectMinBackoff time.Duration
+	ReconnectMaxBackoff time.Duration
+}
+
+func (o *KeepaliveOptions) complete() {
+	if o.PingInterval <= 0 {
+		o.PingInterval = DefaultPingInterval
+	}
+	if o.PongTimeout <= 0 {
+		o.PongTimeout = DefaultPongTimeout
+	}
+	if o.PongTimeout <= o.PingInterval {
+		o.PongTimeout = o.PingInterval * 3
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = DefaultWriteTimeout
+	}
+	if o.ReconnectMinBackoff <= 0 {
+		o.ReconnectMinBackoff = DefaultReconnectMinBackoff
+	}
+	if o.ReconnectMaxBackoff < o.ReconnectMinBackoff {
+		o.ReconnectMaxBackoff = DefaultReconnectMaxBackoff
+		if o.ReconnectMaxBackoff < o.ReconnectMinBackoff {
+			o.ReconnectMaxBackoff = o.ReconnectMinBackoff
+		}
+	}
+}
+
+// connState 连接状态机，状态变更时记录日志
+type connState struct {
+	mutex    sync.RWMutex
+	state    ConnState
+	since    time.Time
+	lastPong time.Time
+}
+
+func newConnState() *connState {
+	return &connState{state: StateConnecting, since: time.Now()}
+}
+
+func (s *connState) get() ConnState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.state
+}
+
+func (s *connState) set(state ConnState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.state == state {
+		return
+	}
+	klog.Infof("server connection state changed from %s to %s", s.state, state)
+	s.state = state
+	s.since = time.Now()
+	if state == StateConnected {
+		s.lastPong = s.since
+	}
+}
+
+// degrade 只有已连接状态才会降级，重连过程中的写失败不改变状态
+func (s *connState) degrade() {
+	if s.get() == StateConnected {
+		s.set(StateDegraded)
+	}
+}
+
+func (s *connState) pong() {
+	s.mutex.Lock()
+	s.lastPong = time.Now()
+	degraded := s.state == StateDegraded
+	s.mutex.Unlock()
+	if degraded {
+		s.set(StateConnected)
+	}
+}
+
+func (s *connState) sinceLastPong() time.Duration {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return time.Since(s.lastPong)
+}
+
+// backoff 带随机抖动的指数退避
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func (b *backoff) next() time.Duration {
+	if b.current < b.min {
+		b.current = b.min
+	} else {
+		b.current *= 2
+		if b.current > b.max {
+			b.current = b.max
+		}
+	}
+	// 在[current/2, current)之间随机，避免大量agent同时重连
+	half := b.current / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (b *backoff) reset() {
+	b.current = 0
+}
+
+func (ws *WebSocket) IsConnected() bool {
+	return ws.connState.get() != StateConnecting
+}
+
+// startKeepalive 为新建立的连接设置读超时及pong处理，并定时发送ping，连接关闭时通过stopCh退出
+func (ws *WebSocket) startKeepalive(conn *websocket.Conn, stopCh chan struct{}) {
+	conn.SetReadDeadline(time.Now().Add(ws.Keepalive.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		ws.connState.pong()
+		return conn.SetReadDeadline(time.Now().Add(ws.Keepalive.PongTimeout))
+	})
+	go func() {
+		ticker := time.NewTicker(ws.Keepalive.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if ws.connState.sinceLastPong() > ws.Keepalive.PingInterval*2 {
+					klog.Warningf("not receive pong from server in %s", ws.connState.sinceLastPong().Round(time.Second))
+					ws.connState.degrade()
+				}
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ws.Keepalive.WriteTimeout))
+				if err != nil {
+					klog.Errorf("write ping to server error: %v", err)
+					ws.connState.degrade()
+				}
+			}
+		}
+	}()
+}
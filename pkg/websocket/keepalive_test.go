@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeepaliveOptionsComplete(t *testing.T) {
+	cases := []struct {
+		name string
+		opts KeepaliveOptions
+		want KeepaliveOptions
+	}{
+		{
+			name: "defaults",
+			want: KeepaliveOptions{
+				PingInterval:        DefaultPingInterval,
+				PongTimeout:         DefaultPongTimeout,
+				WriteTimeout:        DefaultWriteTimeout,
+				ReconnectMinBackoff: DefaultReconnectMinBackoff,
+				ReconnectMaxBackoff: DefaultReconnectMaxBackoff,
+			},
+		},
+		{
+			name: "pong timeout not greater than ping interval",
+			opts: KeepaliveOptions{PingInterval: 30 * time.Second, PongTimeout: 10 * time.Second},
+			want: KeepaliveOptions{
+				PingInterval:        30 * time.Second,
+				PongTimeout:         90 * time.Second,
+				WriteTimeout:        DefaultWriteTimeout,
+				ReconnectMinBackoff: DefaultReconnectMinBackoff,
+				ReconnectMaxBackoff: DefaultReconnectMaxBackoff,
+			},
+		},
+		{
+			name: "max backoff less than min backoff",
+			opts: KeepaliveOptions{ReconnectMinBackoff: 2 * time.Minute, ReconnectMaxBackoff: time.Second},
+			want: KeepaliveOptions{
+				PingInterval:        DefaultPingInterval,
+				PongTimeout:         DefaultPongTimeout,
+				WriteTimeout:        DefaultWriteTimeout,
+				ReconnectMinBackoff: 2 * time.Minute,
+				ReconnectMaxBackoff: 2 * time.Minute,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := c.opts
+			opts.complete()
+			if opts != c.want {
+				t.Errorf("complete() = %+v, want %+v", opts, c.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := &backoff{min: time.Second, max: 8 * time.Second}
+	cases := []struct {
+		name    string
+		reset   bool
+		current time.Duration
+	}{
+		{name: "first", current: time.Second},
+		{name: "second", current: 2 * time.Second},
+		{name: "third", current: 4 * time.Second},
+		{name: "fourth", current: 8 * time.Second},
+		{name: "capped", current: 8 * time.Second},
+		{name: "after reset", reset: true, current: time.Second},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.reset {
+				b.reset()
+			}
+			wait := b.next()
+			if b.current != c.current {
+				t.Errorf("current = %s, want %s", b.current, c.current)
+			}
+			if wait < c.current/2 || wait > c.current {
+				t.Errorf("wait %s not in [%s, %s]", wait, c.current/2, c.current)
+			}
+		})
+	}
+}
+
+func TestConnState(t *testing.T) {
+	cases := []struct {
+		name  string
+		op    func(s *connState)
+		state ConnState
+	}{
+		{name: "initial", op: func(s *connState) {}, state: StateConnecting},
+		{name: "degrade while connecting", op: func(s *connState) { s.degrade() }, state: StateConnecting},
+		{name: "connected", op: func(s *connState) { s.set(StateConnected) }, state: StateConnected},
+		{name: "degrade", op: func(s *connState) { s.degrade() }, state: StateDegraded},
+		{name: "pong recovers", op: func(s *connState) { s.pong() }, state: StateConnected},
+	}
+	s := newConnState()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.op(s)
+			if got := s.get(); got != c.state {
+				t.Errorf("state = %s, want %s", got, c.state)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubespace/agent/pkg/utils"
+	"k8s.io/klog"
+)
+
+const (
+	DefaultOutboxMaxMessages = 1000
+	DefaultOutboxMaxBytes    = 64 * 1024 * 1024
+	DefaultOutboxMaxAge      = 10 * time.Minute
+
+	outboxFileSuffix = ".json"
+)
+
+// OutboxOptions 与server断开期间缓存待发送消息的配置
+type OutboxOptions struct {
+	// MaxMessages、MaxBytes 缓存的最大消息数及总大小，超过后丢弃最早的消息
+	MaxMessages int
+	MaxBytes    int64
+	// MaxAge 消息缓存的最长时间，过期的消息不再发送
+	MaxAge time.Duration
+	// Dir 不为空时消息持久化到该目录，agent重启后仍可发送
+	Dir string
+}
+
+type outboxEntry struct {
+	seq     uint64
+	created time.Time
+	size    int64
+	// data 持久化到磁盘时为空，发送时从文件读取
+	data []byte
+}
+
+// outbox 断开连接期间缓存请求的响应以及重要的watch事件，重连后按顺序重新发送
+type outbox struct {
+	opts    OutboxOptions
+	mutex   sync.Mutex
+	entries []*outboxEntry
+	bytes   int64
+	nextSeq uint64
+}
+
+func newOutbox(opts *OutboxOptions) *outbox {
+	o := &outbox{}
+	if opts != nil {
+		o.opts = *opts
+	}
+	if o.opts.MaxMessages <= 0 {
+		o.opts.MaxMessages = DefaultOutboxMaxMessages
+	}
+	if o.opts.MaxBytes <= 0 {
+		o.opts.MaxBytes = DefaultOutboxMaxBytes
+	}
+	if o.opts.MaxAge <= 0 {
+		o.opts.MaxAge = DefaultOutboxMaxAge
+	}
+	if o.opts.Dir != "" {
+		if err := o.load(); err != nil {
+			klog.Errorf("load outbox from %s error: %v, messages will only be kept in memory", o.opts.Dir, err)
+			o.opts.Dir = ""
+		}
+	}
+	return o
+}
+
+// load 加载上次运行时未发送的消息
+func (o *outbox) load() error {
+	if err := os.MkdirAll(o.opts.Dir, 0700); err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(o.opts.Dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), outboxFileSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), outboxFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		o.entries = append(o.entries, &outboxEntry{seq: seq, created: f.ModTime(), size: f.Size()})
+		o.bytes += f.Size()
+		if seq >= o.nextSeq {
+			o.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(o.entries, func(i, j int) bool { return o.entries[i].seq < o.entries[j].seq })
+	if len(o.entries) > 0 {
+		klog.Infof("loaded %d unsent messages from outbox %s", len(o.entries), o.opts.Dir)
+	}
+	o.evict()
+	return nil
+}
+
+func (o *outbox) path(seq uint64) string {
+	return filepath.Join(o.opts.Dir, fmt.Sprintf("%020d%s", seq, outboxFileSuffix))
+}
+
+// spoolable 只缓存请求的响应以及删除事件，exec/log流数据断开后已经没有意义，
+// 新增和更新事件在重新打开watch后可以通过list恢复
+func spoolable(resp *utils.TResponse) bool {
+	switch resp.ResType {
+	case utils.RequestType:
+		return true
+	case utils.WatchType:
+		if watchResp, ok := resp.Data.(*utils.WatchResponse); ok {
+			return watchResp.Event == utils.DeleteEvent
+		}
+	}
+	return false
+}
+
+// spoolIfDisconnected 未连接时缓存消息并返回true，判断连接状态与入队在同一把锁内，保证与replay不冲突
+func (o *outbox) spoolIfDisconnected(resp *utils.TResponse, connected func() bool) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if connected() {
+		return false
+	}
+	o.pushLocked(resp)
+	return true
+}
+
+func (o *outbox) push(resp *utils.TResponse) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.pushLocked(resp)
+}
+
+func (o *outbox) pushLocked(resp *utils.TResponse) {
+	if !spoolable(resp) {
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		klog.Errorf("serializer outbox message %s error: %v", resp.RequestId, err)
+		return
+	}
+	entry := &outboxEntry{seq: o.nextSeq, created: time.Now(), size: int64(len(data)), data: data}
+	o.nextSeq += 1
+	if o.opts.Dir != "" {
+		if err = ioutil.WriteFile(o.path(entry.seq), data, 0600); err != nil {
+			klog.Errorf("write outbox message %s to disk error: %v", resp.RequestId, err)
+		} else {
+			entry.data = nil
+		}
+	}
+	o.entries = append(o.entries, entry)
+	o.bytes += entry.size
+	klog.V(1).Infof("spool %s message %s, %d messages in outbox", resp.ResType, resp.RequestId, len(o.entries))
+	o.evict()
+}
+
+// evict 丢弃过期以及超过数量、大小限制的最早的消息
+func (o *outbox) evict() {
+	expire := time.Now().Add(-o.opts.MaxAge)
+	dropped := 0
+	for len(o.entries) > 0 {
+		e := o.entries[0]
+		if !e.created.Before(expire) && len(o.entries) <= o.opts.MaxMessages && o.bytes <= o.opts.MaxBytes {
+			break
+		}
+		o.remove(e)
+		o.entries = o.entries[1:]
+		o.bytes -= e.size
+		dropped += 1
+	}
+	if dropped > 0 {
+		klog.Warningf("outbox dropped %d expired or overflowed messages", dropped)
+	}
+}
+
+func (o *outbox) remove(e *outboxEntry) {
+	if e.data == nil && o.opts.Dir != "" {
+		if err := os.Remove(o.path(e.seq)); err != nil && !os.IsNotExist(err) {
+			klog.Errorf("remove outbox file error: %v", err)
+		}
+	}
+}
+
+// take 取出最早的未过期消息，持久化的文件在发送成功调用ack后才删除，发送前agent退出时重启后仍会重发
+func (o *outbox) take() (*outboxEntry, []byte) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.evict()
+	for len(o.entries) > 0 {
+		e := o.entries[0]
+		o.entries = o.entries[1:]
+		o.bytes -= e.size
+		data := e.data
+		if data == nil {
+			var err error
+			data, err = ioutil.ReadFile(o.path(e.seq))
+			if err != nil {
+				klog.Errorf("read outbox message %d error: %v", e.seq, err)
+				o.remove(e)
+				continue
+			}
+		}
+		return e, data
+	}
+	return nil, nil
+}
+
+// ack 消息发送成功后删除持久化的文件
+func (o *outbox) ack(e *outboxEntry) {
+	o.remove(e)
+}
+
+// requeue 发送失败时将消息放回队列头部
+func (o *outbox) requeue(e *outboxEntry) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.entries = append([]*outboxEntry{e}, o.entries...)
+	o.bytes += e.size
+}
+
+// replayOutbox 重连后按顺序发送断开期间缓存的消息
+func (ws *WebSocket) replayOutbox() error {
+	sent := 0
+	for {
+		e, data := ws.outbox.take()
+		if e == nil {
+			break
+		}
+		resp := &utils.TResponse{}
+		if err := json.Unmarshal(data, resp); err != nil {
+			klog.Errorf("unserializer outbox message %d error: %v", e.seq, err)
+			ws.outbox.ack(e)
+			continue
+		}
+		if err := ws.sendResponse(resp); err != nil {
+			ws.outbox.requeue(e)
+			return err
+		}
+		ws.outbox.ack(e)
+		sent += 1
+	}
+	if sent > 0 {
+		klog.Infof("replayed %d spooled messages to server", sent)
+	}
+	return nil
+}
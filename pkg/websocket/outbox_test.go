@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubespace/agent/pkg/utils"
+)
+
+func TestSpoolable(t *testing.T) {
+	cases := []struct {
+		name string
+		resp *utils.TResponse
+		want bool
+	}{
+		{name: "request response", resp: &utils.TResponse{ResType: utils.RequestType}, want: true},
+		{name: "watch delete event", resp: &utils.TResponse{ResType: utils.WatchType, Data: &utils.WatchResponse{Event: utils.DeleteEvent}}, want: true},
+		{name: "watch add event", resp: &utils.TResponse{ResType: utils.WatchType, Data: &utils.WatchResponse{Event: utils.AddEvent}}},
+		{name: "exec data", resp: &utils.TResponse{ResType: utils.ExecType, Data: []byte("ls")}},
+		{name: "log data", resp: &utils.TResponse{ResType: utils.LogType, Data: "line"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := spoolable(c.resp); got != c.want {
+				t.Errorf("spoolable() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// takeAll 按顺序取出outbox中所有消息的request_id
+func takeAll(t *testing.T, o *outbox) []string {
+	var ids []string
+	for {
+		e, data := o.take()
+		if e == nil {
+			return ids
+		}
+		resp := &utils.TResponse{}
+		if err := json.Unmarshal(data, resp); err != nil {
+			t.Fatalf("decode outbox message error: %v", err)
+		}
+		o.ack(e)
+		ids = append(ids, resp.RequestId)
+	}
+}
+
+func pushRequests(o *outbox, ids ...string) {
+	for _, id := range ids {
+		o.push(&utils.TResponse{ResType: utils.RequestType, RequestId: id, Data: "ok"})
+	}
+}
+
+func TestOutboxEvict(t *testing.T) {
+	size := func(id string) int64 {
+		data, _ := json.Marshal(&utils.TResponse{ResType: utils.RequestType, RequestId: id, Data: "ok"})
+		return int64(len(data))
+	}
+	cases := []struct {
+		name string
+		opts OutboxOptions
+		// age 消息入队后经过的时间
+		age  time.Duration
+		want []string
+	}{
+		{name: "within limits", want: []string{"1", "2", "3"}},
+		{name: "max messages", opts: OutboxOptions{MaxMessages: 2}, want: []string{"2", "3"}},
+		{name: "max bytes", opts: OutboxOptions{MaxBytes: size("1") * 2}, want: []string{"2", "3"}},
+		{name: "expired", opts: OutboxOptions{MaxAge: time.Minute}, age: 2 * time.Minute},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := c.opts
+			o := newOutbox(&opts)
+			pushRequests(o, "1", "2", "3")
+			for _, e := range o.entries {
+				e.created = e.created.Add(-c.age)
+			}
+			if got := takeAll(t, o); fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("outbox messages = %v, want %v", got, c.want)
+			}
+			if o.bytes != 0 {
+				t.Errorf("outbox bytes = %d after take all, want 0", o.bytes)
+			}
+		})
+	}
+}
+
+func TestOutboxPersist(t *testing.T) {
+	dir := t.TempDir()
+	o := newOutbox(&OutboxOptions{Dir: dir})
+	pushRequests(o, "1", "2")
+	// 不缓存的消息不写入磁盘
+	o.push(&utils.TResponse{ResType: utils.ExecType, RequestId: "exec", Data: []byte("ls")})
+	for _, e := range o.entries {
+		if e.data != nil {
+			t.Errorf("outbox message %d kept in memory", e.seq)
+		}
+	}
+
+	// 模拟agent重启
+	reloaded := newOutbox(&OutboxOptions{Dir: dir})
+	pushRequests(reloaded, "3")
+	if got, want := takeAll(t, reloaded), []string{"1", "2", "3"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reloaded outbox messages = %v, want %v", got, want)
+	}
+	if got := newOutbox(&OutboxOptions{Dir: dir}).entries; len(got) != 0 {
+		t.Errorf("outbox has %d messages after take all, want 0", len(got))
+	}
+}
+
+func TestOutboxPersistUntilAck(t *testing.T) {
+	dir := t.TempDir()
+	o := newOutbox(&OutboxOptions{Dir: dir})
+	pushRequests(o, "1", "2")
+	// 取出后未确认发送成功时agent退出，重启后仍会重发
+	o.take()
+	e, _ := o.take()
+	o.ack(e)
+	if got, want := takeAll(t, newOutbox(&OutboxOptions{Dir: dir})), []string{"1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reloaded outbox messages = %v, want %v", got, want)
+	}
+}
+
+func TestOutboxRequeue(t *testing.T) {
+	for _, dir := range []string{"", t.TempDir()} {
+		t.Run(fmt.Sprintf("dir=%q", dir), func(t *testing.T) {
+			o := newOutbox(&OutboxOptions{Dir: dir})
+			pushRequests(o, "1", "2")
+			e, _ := o.take()
+			o.requeue(e)
+			if got, want := takeAll(t, o), []string{"1", "2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("outbox messages = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSpoolIfDisconnected(t *testing.T) {
+	cases := []struct {
+		name      string
+		connected bool
+		want      bool
+	}{
+		{name: "connected", connected: true},
+		{name: "disconnected", want: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := newOutbox(nil)
+			resp := &utils.TResponse{ResType: utils.RequestType, RequestId: "1"}
+			if got := o.spoolIfDisconnected(resp, func() bool { return c.connected }); got != c.want {
+				t.Errorf("spoolIfDisconnected() = %v, want %v", got, c.want)
+			}
+			if c.want != (len(o.entries) == 1) {
+				t.Errorf("outbox has %d messages", len(o.entries))
+			}
+		})
+	}
+}
+
+func TestReplayOutbox(t *testing.T) {
+	ws, messages := newTestWebSocket(t, nil)
+	pushRequests(ws.outbox, "1", "2", "3")
+	if err := ws.replayOutbox(); err != nil {
+		t.Fatalf("replayOutbox() error = %v", err)
+	}
+	for _, want := range []string{"1", "2", "3"} {
+		resp := &utils.TResponse{}
+		if err := json.Unmarshal(receiveMessage(t, messages).data, resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.RequestId != want {
+			t.Errorf("replayed request id = %q, want %q", resp.RequestId, want)
+		}
+	}
+
+	// 连接断开时消息放回队列，不会丢失
+	ws.Conn = nil
+	pushRequests(ws.outbox, "4")
+	if err := ws.replayOutbox(); err == nil {
+		t.Fatal("replayOutbox() without connection error = nil")
+	}
+	if got := takeAll(t, ws.outbox); fmt.Sprint(got) != "[4]" {
+		t.Errorf("outbox messages after failed replay = %v, want [4]", got)
+	}
+}
@@ -1,8 +1,10 @@
 package websocket
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/kubespace/agent/pkg/ospserver"
 	"github.com/kubespace/agent/pkg/utils"
@@ -10,6 +12,7 @@ import (
 	"k8s.io/klog"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -22,66 +25,108 @@ type ExecCloseParams struct {
 }
 
 type ApplyResource interface {
-	ApplyYaml(applyParams interface{}) *utils.Response
+	ApplyYaml(ctx context.Context, applyParams interface{}) *utils.Response
 }
 
 type SendResponse func(interface{}, string, string)
 
-type ExecWebSocket struct {
-	ResponseChan chan *utils.TResponse
-	Conn         *websocket.Conn
-	StopChan     chan struct{}
-}
-
+// WebSocket 与server之间只维持一条长连接，请求、响应、watch事件以及exec/log流数据都通过该连接传输，
+// 由request_id区分不同的请求或会话
 type WebSocket struct {
+	// Url 当前连接的server地址，Host随failover切换
 	Url              *url.URL
-	RespUrl          *url.URL
+	Endpoints        *Endpoints
 	Token            string
 	RequestChan      chan *utils.Request
 	ResponseChan     chan *utils.TResponse
 	ExecResponseChan chan *utils.TResponse
 	Conn             *websocket.Conn
-	ExecResponseMap  map[string]*ExecWebSocket
 	OspServer        *ospserver.OspServer
 	ApplyResource    ApplyResource
 	UpdateAgent      bool
+	TLSConfig        *tls.Config
+	Proxy            utils.ProxyFunc
+	Keepalive        *KeepaliveOptions
+	// Compression 是否启用permessage-deflate压缩
+	Compression bool
+	// CompactEncoding 是否支持MessagePack编码响应
+	CompactEncoding bool
+	negotiated      *negotiatedFeatures
+	// MaxMessageSize 单条消息的最大字节数，超过后拆分为多个分片发送
+	MaxMessageSize int
+	outbox         *outbox
+	// chunkStreams 已发送的分片流数量，用于生成分片流id
+	chunkStreams uint32
+	// KubernetesVersion、Capabilities 连接后通过handshake发送给server
+	KubernetesVersion string
+	Capabilities      CapabilitiesFunc
+	// PrimaryProbeInterval 连接到备用地址时探测主地址是否恢复的间隔
+	PrimaryProbeInterval time.Duration
+	connState            *connState
+	// stopKeepalive 关闭当前连接的心跳协程
+	stopKeepalive chan struct{}
+	// reconnectBackoff 跨多次重连保持的退避状态，connectedAt为最近一次连接成功的时间
+	reconnectBackoff *backoff
+	connectedAt      time.Time
+	// gorilla websocket同一时间只允许一个writer，所有写操作都需要加锁
+	writeMutex sync.Mutex
+	// sendMutex 发送新消息时持有读锁，重连后重发缓存的消息时持有写锁，保证缓存的消息先于新消息发送
+	sendMutex sync.RWMutex
 }
 
 func NewWebSocket(
 	url *url.URL,
+	endpoints *Endpoints,
 	token string,
 	requestChan chan *utils.Request,
 	responseChan chan *utils.TResponse,
-	respUrl *url.URL,
 	ospServer *ospserver.OspServer,
 	dynRes ApplyResource,
-	updateAgent bool) *WebSocket {
+	updateAgent bool,
+	tlsConfig *tls.Config,
+	proxy utils.ProxyFunc,
+	keepalive *KeepaliveOptions,
+	outboxOpts *OutboxOptions) *WebSocket {
+	if keepalive == nil {
+		keepalive = &KeepaliveOptions{}
+	}
+	keepalive.complete()
 	return &WebSocket{
 		Url:              url,
+		Endpoints:        endpoints,
 		Token:            token,
 		RequestChan:      requestChan,
 		ResponseChan:     responseChan,
 		ExecResponseChan: make(chan *utils.TResponse, 1000),
-		RespUrl:          respUrl,
-		ExecResponseMap:  make(map[string]*ExecWebSocket),
 		OspServer:        ospServer,
 		ApplyResource:    dynRes,
 		UpdateAgent:      updateAgent,
+		TLSConfig:        tlsConfig,
+		Proxy:            proxy,
+		Keepalive:        keepalive,
+		connState:        newConnState(),
+		reconnectBackoff: &backoff{min: keepalive.ReconnectMinBackoff, max: keepalive.ReconnectMaxBackoff},
+		negotiated:       &negotiatedFeatures{},
+		MaxMessageSize:   DefaultMaxMessageSize,
+		outbox:           newOutbox(outboxOpts),
+
+		PrimaryProbeInterval: DefaultPrimaryProbeInterval,
 	}
 }
 
 func (ws *WebSocket) ReadRequest() {
-	defer ws.Conn.Close()
-
-	ws.reconnectServer()
+	// 使用重连返回的连接读取，closeConn可能在其他协程中清空ws.Conn
+	conn := ws.reconnectServer()
 	for {
-		_, data, err := ws.Conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			klog.Error("read err:", err)
-			ws.Conn.Close()
-			ws.reconnectServer()
+			ws.closeConn()
+			conn = ws.reconnectServer()
 			continue
 		}
+		// 收到任何消息都说明连接可用，延长读超时
+		conn.SetReadDeadline(time.Now().Add(ws.Keepalive.PongTimeout))
 		klog.V(1).Infof("request data: %s", string(data))
 		request := &utils.Request{}
 		err = json.Unmarshal(data, request)
@@ -90,6 +135,8 @@ func (ws *WebSocket) ReadRequest() {
 		} else {
 			if request.Action == CloseExecConn {
 				go ws.handleCloseExecConn(request)
+			} else if request.Action == HandshakeAction {
+				ws.handleHandshakeReply(request)
 			} else {
 				ws.RequestChan <- request
 			}
@@ -97,6 +144,7 @@ func (ws *WebSocket) ReadRequest() {
 	}
 }
 
+// handleCloseExecConn exec数据已经复用主连接发送，不再需要单独关闭会话连接，保留该动作以兼容server
 func (ws *WebSocket) handleCloseExecConn(request *utils.Request) {
 	params := &ExecCloseParams{}
 	requestParams, _ := json.Marshal(request.Params)
@@ -105,25 +153,12 @@ func (ws *WebSocket) handleCloseExecConn(request *utils.Request) {
 
 	if err != nil {
 		klog.Errorf("unserializer request data error: %s", err)
-		res.Code = code.ParamsError
-		res.Msg = err.Error()
+		res = utils.NewParamsErrorResponse(err.Error())
+	} else if params.SessionId == "" {
+		klog.Errorf("request %s not found param session id", request.RequestId)
+		res = utils.NewParamsErrorResponse("params not found session id")
 	} else {
-		sessionId := params.SessionId
-		if sessionId != "" {
-			execResp, _ := ws.ExecResponseMap[sessionId]
-			if execResp != nil {
-				close(execResp.StopChan)
-			} else {
-				klog.Errorf("not found session %s get exec response", sessionId)
-				res.Code = code.ParamsError
-				res.Msg = "not found exec response"
-			}
-			klog.V(1).Infof("close exec session %s success", sessionId)
-		} else {
-			klog.Errorf("request %s not found param session id", request.RequestId)
-			res.Code = code.ParamsError
-			res.Msg = "params not found session id"
-		}
+		klog.V(1).Infof("close exec session %s success", params.SessionId)
 	}
 
 	response := &utils.TResponse{
@@ -134,42 +169,127 @@ func (ws *WebSocket) handleCloseExecConn(request *utils.Request) {
 	ws.ResponseChan <- response
 }
 
-func (ws *WebSocket) reconnectServer() {
-	err := ws.connectServer()
-	if err == nil {
-		return
+func (ws *WebSocket) reconnectServer() *websocket.Conn {
+	ws.connState.set(StateConnecting)
+	b := ws.reconnectBackoff
+	if ws.connectedAt.IsZero() || time.Since(ws.connectedAt) >= stableConnectionDuration {
+		b.reset()
+	} else {
+		// 上次连接建立后很快断开，继续退避，避免server异常时频繁重连
+		wait := b.next()
+		klog.Infof("server connection closed shortly after connected, reconnect after %s", wait.Round(time.Millisecond))
+		time.Sleep(wait)
 	}
-	ticker := time.NewTicker(time.Second * 5)
-	defer ticker.Stop()
-
 	for {
-		select {
-		case <-ticker.C:
-			err := ws.connectServer()
-			if err == nil {
-				return
-			}
+		conn, err := ws.connectServer()
+		if err == nil {
+			ws.connectedAt = time.Now()
+			return conn
 		}
+		wait := b.next()
+		klog.Infof("connect to all server endpoints error: %v, retry after %s", err, wait.Round(time.Millisecond))
+		time.Sleep(wait)
 	}
 }
 
-func (ws *WebSocket) connectServer() error {
-	klog.Info("start connect to server ", ws.Url.String())
-	wsHeader := http.Header{}
-	wsHeader.Add("token", ws.Token)
-	d := &websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: nil, InsecureSkipVerify: true}}
-	conn, _, err := d.Dial(ws.Url.String(), wsHeader)
+// connectServer 按优先级依次尝试连接server地址，连接成功后切换OspServer到相同的地址
+func (ws *WebSocket) connectServer() (*websocket.Conn, error) {
+	hosts, err := ws.Endpoints.Resolve()
 	if err != nil {
-		klog.Infof("connect to server %s error: %v, retry after 5 seconds\n", ws.Url.String(), err)
-		return err
-	} else {
-		klog.Infof("connect to server %s success\n", ws.Url.String())
-		ws.Conn = conn
+		return nil, err
+	}
+	var lastErr error
+	for i, host := range hosts {
+		serverUrl := *ws.Url
+		serverUrl.Host = host
+		conn, err := ws.dialServer(&serverUrl)
+		if err != nil {
+			klog.Infof("connect to server %s error: %v", serverUrl.String(), err)
+			lastErr = err
+			continue
+		}
+		klog.Infof("connect to server %s success\n", serverUrl.String())
+		if err = ws.startSession(&serverUrl, host, conn, i == 0); err != nil {
+			ws.closeConn()
+			lastErr = err
+			continue
+		}
 		if ws.UpdateAgent {
 			ws.updateAgent()
 		}
-		return nil
+		return conn, nil
 	}
+	return nil, lastErr
+}
+
+// startSession 切换到新建立的连接，发送handshake并重发缓存的消息，
+// 期间持有sendMutex写锁，新的消息在缓存的消息全部重发后才发送
+func (ws *WebSocket) startSession(serverUrl *url.URL, host string, conn *websocket.Conn, primary bool) error {
+	ws.sendMutex.Lock()
+	defer ws.sendMutex.Unlock()
+	stopCh := make(chan struct{})
+	ws.writeMutex.Lock()
+	ws.Url = serverUrl
+	ws.Conn = conn
+	ws.stopKeepalive = stopCh
+	ws.writeMutex.Unlock()
+	ws.Endpoints.setActive(host)
+	ws.negotiated.set(nil)
+	if ws.Compression {
+		conn.EnableWriteCompression(true)
+	}
+	if ws.OspServer != nil {
+		ws.OspServer.SetHost(host)
+	}
+	ws.startKeepalive(conn, stopCh)
+	if !primary {
+		go ws.probePrimary(stopCh)
+	}
+	if err := ws.sendHandshake(); err != nil {
+		klog.Errorf("send handshake to server %s error: %v", serverUrl.String(), err)
+		return err
+	}
+	ws.connState.set(StateConnected)
+	if err := ws.replayOutbox(); err != nil {
+		klog.Errorf("replay spooled messages to server %s error: %v", serverUrl.String(), err)
+		return err
+	}
+	return nil
+}
+
+func (ws *WebSocket) dialServer(serverUrl *url.URL) (*websocket.Conn, error) {
+	klog.Info("start connect to server ", serverUrl.String())
+	wsHeader := http.Header{}
+	wsHeader.Add("token", ws.Token)
+	d := &websocket.Dialer{
+		TLSClientConfig:   ws.TLSConfig,
+		HandshakeTimeout:  ws.Keepalive.PongTimeout,
+		Proxy:             ws.Proxy,
+		EnableCompression: ws.Compression,
+	}
+	conn, _, err := d.Dial(serverUrl.String(), wsHeader)
+	return conn, err
+}
+
+// ActiveEndpoint 返回当前连接的server地址
+func (ws *WebSocket) ActiveEndpoint() string {
+	return ws.Endpoints.Active()
+}
+
+func (ws *WebSocket) closeConn() {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+	if ws.stopKeepalive != nil {
+		close(ws.stopKeepalive)
+		ws.stopKeepalive = nil
+	}
+	if ws.Conn != nil {
+		ws.Conn.Close()
+		// 清空连接，断开期间的写入返回未连接错误并进入缓存
+		ws.Conn = nil
+	}
+	ws.Endpoints.setActive("")
+	ws.connState.set(StateConnecting)
 }
 
 func (ws *WebSocket) updateAgent() {
@@ -183,12 +303,13 @@ func (ws *WebSocket) updateAgent() {
 		"yaml": agentYaml,
 	}
 	b, _ := json.Marshal(applyYaml)
-	resp := ws.ApplyResource.ApplyYaml(b)
+	resp := ws.ApplyResource.ApplyYaml(context.Background(), b)
 	if !resp.IsSuccess() {
 		klog.Errorf("apply agent yaml error: %s", resp.Msg)
 	}
 }
 
+// WriteResponse 按顺序发送普通响应，exec数据放到单独的缓存中发送，避免交互式数据被大响应阻塞
 func (ws *WebSocket) WriteResponse() {
 	for {
 		select {
@@ -198,8 +319,7 @@ func (ws *WebSocket) WriteResponse() {
 					// 发送到缓存，不阻塞
 					ws.ExecResponseChan <- resp
 				} else {
-					// 并发发送消息
-					go ws.doSendResponse(resp)
+					ws.doSendResponse(resp)
 				}
 			}
 		}
@@ -211,96 +331,56 @@ func (ws *WebSocket) WriteExecResponse() {
 		select {
 		case resp, ok := <-ws.ExecResponseChan:
 			if ok {
-				//klog.Info(resp)
-				ws.doSendExecResponse(resp)
+				ws.doSendResponse(resp)
 			}
 		}
 	}
 }
 
 func (ws *WebSocket) doSendResponse(resp *utils.TResponse) {
-	respMsg, err := resp.Serializer()
-	if err != nil {
-		klog.Errorf("response %v serializer error: %s", resp, err)
-		return
+	ws.sendMutex.RLock()
+	defer ws.sendMutex.RUnlock()
+	if err := ws.sendResponse(resp); err != nil {
+		klog.Errorf("write response %s error: %v", resp.RequestId, err)
+		ws.connState.degrade()
+		// 写入失败时缓存，重连后重新发送
+		ws.outbox.push(resp)
 	}
+}
 
-	klog.V(1).Info("start connect to server response websocket", ws.Url.String())
-	wsHeader := http.Header{}
-	wsHeader.Add("token", ws.Token)
-
-	d := &websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: nil, InsecureSkipVerify: true}}
-	conn, _, err := d.Dial(ws.RespUrl.String(), wsHeader)
-	defer func() {
-		if conn != nil {
-			conn.Close()
-		}
-	}()
-
+func (ws *WebSocket) sendResponse(resp *utils.TResponse) error {
+	messageType, respMsg, err := ws.encodeResponse(resp)
 	if err != nil {
-		klog.Errorf("connect to server %s error: %v", ws.RespUrl.String(), err)
+		klog.Errorf("response %v serializer error: %s", resp, err)
+		return nil
+	}
+	if ws.MaxMessageSize > 0 && len(respMsg) > ws.MaxMessageSize && ws.negotiated.enabled(FeatureChunked) {
+		err = ws.sendChunked(resp, messageType, respMsg)
 	} else {
-		conn.WriteMessage(websocket.TextMessage, respMsg)
-		klog.V(1).Infof("write response %s success", string(respMsg))
+		err = ws.writeMessage(messageType, respMsg)
+	}
+	if err != nil {
+		return err
 	}
+	klog.V(1).Infof("write response %s type %s success, %d bytes", resp.RequestId, resp.ResType, len(respMsg))
+	return nil
 }
 
-func (ws *WebSocket) doSendExecResponse(resp *utils.TResponse) {
-	execResp, ok := ws.ExecResponseMap[resp.RequestId]
-	if !ok {
-		wsHeader := http.Header{}
-		wsHeader.Add("token", ws.Token)
-
-		d := &websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: nil, InsecureSkipVerify: true}}
-		execWs, _, err := d.Dial(ws.RespUrl.String(), wsHeader)
-		if err != nil {
-			klog.Errorf("connect to server %s error: %v", ws.RespUrl.String(), err)
-			return
-		} else {
-			execResp = &ExecWebSocket{
-				Conn:         execWs,
-				ResponseChan: make(chan *utils.TResponse),
-				StopChan:     make(chan struct{}),
-			}
-			ws.ExecResponseMap[resp.RequestId] = execResp
-			go func() {
-				defer func() {
-					if execResp.Conn != nil {
-						execResp.Conn.Close()
-					}
-					delete(ws.ExecResponseMap, resp.RequestId)
-				}()
-				execStop := false
-				for {
-					select {
-					case resp, ok := <-execResp.ResponseChan:
-						if ok {
-							respMsg, err := resp.Serializer()
-							//klog.Info(string(respMsg))
-							if err != nil {
-								klog.Errorf("response %v serializer error: %s", resp, err)
-								return
-							}
-							execResp.Conn.WriteMessage(websocket.TextMessage, respMsg)
-						}
-					case <-execResp.StopChan:
-						execStop = true
-						break
-					}
-					if execStop {
-						break
-					}
-				}
-				klog.V(1).Infof("exec response session %s finish", resp.RequestId)
-			}()
-		}
+func (ws *WebSocket) writeMessage(messageType int, data []byte) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+	if ws.Conn == nil {
+		return fmt.Errorf("connection to server is not established")
 	}
-	execResp.ResponseChan <- resp
+	ws.Conn.SetWriteDeadline(time.Now().Add(ws.Keepalive.WriteTimeout))
+	return ws.Conn.WriteMessage(messageType, data)
 }
 
+// SendResponse 未连接server时，请求的响应及重要的watch事件进入缓存，其余消息丢弃
 func (ws *WebSocket) SendResponse(resp interface{}, requestId, resType string) {
-	if ws.Conn != nil {
-		tResp := &utils.TResponse{RequestId: requestId, Data: resp, ResType: resType}
-		ws.ResponseChan <- tResp
+	tResp := &utils.TResponse{RequestId: requestId, Data: resp, ResType: resType}
+	if ws.outbox.spoolIfDisconnected(tResp, ws.IsConnected) {
+		return
 	}
+	ws.ResponseChan <- tResp
 }
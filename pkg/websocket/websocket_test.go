@@ -0,0 +1,206 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubespace/agent/pkg/utils"
+	"github.com/kubespace/agent/pkg/utils/code"
+)
+
+func TestSendResponse(t *testing.T) {
+	cases := []struct {
+		name    string
+		state   ConnState
+		resType string
+		data    interface{}
+		// wantSent 进入发送队列，wantSpooled 进入断开期间的缓存
+		wantSent    bool
+		wantSpooled bool
+	}{
+		{name: "connected", state: StateConnected, resType: utils.RequestType, wantSent: true},
+		{name: "degraded connection still sends", state: StateDegraded, resType: utils.ExecType, wantSent: true},
+		{name: "disconnected request response", state: StateConnecting, resType: utils.RequestType, wantSpooled: true},
+		{name: "disconnected watch delete", state: StateConnecting, resType: utils.WatchType, data: &utils.WatchResponse{Event: utils.DeleteEvent}, wantSpooled: true},
+		{name: "disconnected exec data dropped", state: StateConnecting, resType: utils.ExecType},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws := &WebSocket{
+				ResponseChan: make(chan *utils.TResponse, 1),
+				connState:    newConnState(),
+				outbox:       newOutbox(nil),
+			}
+			ws.connState.set(c.state)
+			ws.SendResponse(c.data, "1", c.resType)
+			if sent := len(ws.ResponseChan) == 1; sent != c.wantSent {
+				t.Errorf("sent = %v, want %v", sent, c.wantSent)
+			}
+			if spooled := len(ws.outbox.entries) == 1; spooled != c.wantSpooled {
+				t.Errorf("spooled = %v, want %v", spooled, c.wantSpooled)
+			}
+		})
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	ws, messages := newTestWebSocket(t, nil)
+	ws.ResponseChan = make(chan *utils.TResponse)
+	ws.ExecResponseChan = make(chan *utils.TResponse, 10)
+	go ws.WriteResponse()
+
+	// exec数据进入单独的缓存，普通响应直接通过主连接发送
+	ws.ResponseChan <- &utils.TResponse{ResType: utils.ExecType, RequestId: "exec", Data: "ls"}
+	ws.ResponseChan <- &utils.TResponse{ResType: utils.RequestType, RequestId: "req", Data: "ok"}
+	resp := &utils.TResponse{}
+	if err := json.Unmarshal(receiveMessage(t, messages).data, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RequestId != "req" {
+		t.Errorf("sent request id = %q, want req", resp.RequestId)
+	}
+	select {
+	case execResp := <-ws.ExecResponseChan:
+		if execResp.RequestId != "exec" {
+			t.Errorf("exec request id = %q, want exec", execResp.RequestId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("exec response not routed to exec channel")
+	}
+}
+
+func TestDoSendResponseFailure(t *testing.T) {
+	ws := &WebSocket{
+		Keepalive:  &KeepaliveOptions{},
+		negotiated: &negotiatedFeatures{},
+		connState:  newConnState(),
+		outbox:     newOutbox(nil),
+	}
+	ws.connState.set(StateConnected)
+	ws.doSendResponse(&utils.TResponse{ResType: utils.RequestType, RequestId: "1", Data: "ok"})
+	if state := ws.connState.get(); state != StateDegraded {
+		t.Errorf("state = %s after write failure, want %s", state, StateDegraded)
+	}
+	if len(ws.outbox.entries) != 1 {
+		t.Errorf("outbox has %d messages after write failure, want 1", len(ws.outbox.entries))
+	}
+}
+
+func TestDoSendResponseWaitsForReplay(t *testing.T) {
+	ws, messages := newTestWebSocket(t, nil)
+	// 模拟重连后正在重发缓存的消息
+	ws.sendMutex.Lock()
+	done := make(chan struct{})
+	go func() {
+		ws.doSendResponse(&utils.TResponse{ResType: utils.RequestType, RequestId: "live", Data: "ok"})
+		close(done)
+	}()
+	pushRequests(ws.outbox, "spooled")
+	if err := ws.replayOutbox(); err != nil {
+		t.Fatalf("replayOutbox() error = %v", err)
+	}
+	ws.sendMutex.Unlock()
+	<-done
+	for _, want := range []string{"spooled", "live"} {
+		resp := &utils.TResponse{}
+		if err := json.Unmarshal(receiveMessage(t, messages).data, resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.RequestId != want {
+			t.Errorf("sent request id = %q, want %q", resp.RequestId, want)
+		}
+	}
+}
+
+func TestCloseConn(t *testing.T) {
+	ws, _ := newTestWebSocket(t, nil)
+	ws.Endpoints = &Endpoints{}
+	ws.connState.set(StateConnected)
+	ws.closeConn()
+	if ws.Conn != nil {
+		t.Fatal("connection not cleared after close")
+	}
+	// 关闭后的写入返回错误，响应进入缓存
+	ws.doSendResponse(&utils.TResponse{ResType: utils.RequestType, RequestId: "1", Data: "ok"})
+	if len(ws.outbox.entries) != 1 {
+		t.Errorf("outbox has %d messages after connection closed, want 1", len(ws.outbox.entries))
+	}
+}
+
+func TestConnectServer(t *testing.T) {
+	server, messages := startTestServer(t)
+	serverUrl, _ := url.Parse("ws" + strings.TrimPrefix(server.URL, "http"))
+	endpoints, err := NewEndpoints(serverUrl.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keepalive := &KeepaliveOptions{}
+	keepalive.complete()
+	ws := &WebSocket{
+		Url:        serverUrl,
+		Endpoints:  endpoints,
+		Keepalive:  keepalive,
+		negotiated: &negotiatedFeatures{},
+		connState:  newConnState(),
+		outbox:     newOutbox(nil),
+	}
+	pushRequests(ws.outbox, "1", "2")
+	conn, err := ws.connectServer()
+	if err != nil {
+		t.Fatalf("connectServer() error = %v", err)
+	}
+	defer ws.closeConn()
+	if conn != ws.Conn {
+		t.Error("connectServer() returned connection is not the current connection")
+	}
+	if state := ws.connState.get(); state != StateConnected {
+		t.Errorf("state = %s after connected, want %s", state, StateConnected)
+	}
+	// 先发送handshake，再按顺序重发缓存的消息
+	for _, want := range []string{utils.HandshakeType, "1", "2"} {
+		resp := &utils.TResponse{}
+		if err := json.Unmarshal(receiveMessage(t, messages).data, resp); err != nil {
+			t.Fatal(err)
+		}
+		got := resp.RequestId
+		if resp.ResType == utils.HandshakeType {
+			got = resp.ResType
+		}
+		if got != want {
+			t.Errorf("message = %s %q, want %q", resp.ResType, resp.RequestId, want)
+		}
+	}
+
+	ws.closeConn()
+	if state := ws.connState.get(); state != StateConnecting {
+		t.Errorf("state = %s after closed, want %s", state, StateConnecting)
+	}
+}
+
+func TestHandleCloseExecConn(t *testing.T) {
+	cases := []struct {
+		name     string
+		params   interface{}
+		wantCode string
+	}{
+		{name: "close session", params: map[string]interface{}{"session_id": "s1"}, wantCode: code.Success},
+		{name: "session id not found", params: map[string]interface{}{}, wantCode: code.ParamsError},
+		{name: "invalid params", params: map[string]interface{}{"session_id": 1}, wantCode: code.ParamsError},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ws := &WebSocket{ResponseChan: make(chan *utils.TResponse, 1)}
+			ws.handleCloseExecConn(&utils.Request{RequestId: "r1", Action: CloseExecConn, Params: c.params})
+			resp := <-ws.ResponseChan
+			if resp.RequestId != "r1" || resp.ResType != utils.RequestType {
+				t.Errorf("response = %+v", resp)
+			}
+			if got := resp.Data.(*utils.Response).Code; got != c.wantCode {
+				t.Errorf("code = %s, want %s", got, c.wantCode)
+			}
+		})
+	}
+}